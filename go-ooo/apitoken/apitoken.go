@@ -0,0 +1,101 @@
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// API token scopes
+const (
+	ScopeRead      = "read"      // status endpoints and read-only admin tasks
+	ScopeAnalytics = "analytics" // analytics endpoint
+	ScopeFees      = "fees"      // set global and granular fees
+	ScopeWithdraw  = "withdraw"  // withdraw fees
+	ScopeAdmin     = "admin"     // all other admin tasks, e.g. register, token approval and request intervention
+)
+
+// tokenPrefix identifies go-ooo API tokens, e.g. in secret scanners
+const tokenPrefix = "ooo_"
+
+// AllScopes is every scope. Granted to the service's decryption password
+var AllScopes = []string{ScopeRead, ScopeAnalytics, ScopeFees, ScopeWithdraw, ScopeAdmin}
+
+// Generate returns a new random API token, and the hash to store
+func Generate() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	token = tokenPrefix + hex.EncodeToString(b)
+	return token, Hash(token), nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of the token. Only the hash is stored
+func Hash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// ParseScopes parses a comma separated list of scopes, returning an error for unknown scopes
+func ParseScopes(scopes string) ([]string, error) {
+	var parsed []string
+	seen := make(map[string]bool)
+
+	for _, s := range strings.Split(scopes, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		if !IsValidScope(s) {
+			return nil, errors.New(fmt.Sprintf("unknown scope %s. Valid scopes: %s", s, strings.Join(AllScopes, ", ")))
+		}
+		seen[s] = true
+		parsed = append(parsed, s)
+	}
+
+	if len(parsed) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	return parsed, nil
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope returns true if scopes contains scope
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskScope returns the scope required to run the admin task. Unknown tasks require ScopeAdmin
+func TaskScope(task string) string {
+	switch task {
+	case "list_pending_tokens", "show_provenance", "dry_run_report",
+		"query_fees", "query_granular_fees", "query_fee_config", "query_withdrawable":
+		return ScopeRead
+	case "set_fee", "set_granular_fee":
+		return ScopeFees
+	case "withdraw":
+		return ScopeWithdraw
+	default:
+		return ScopeAdmin
+	}
+}
@@ -0,0 +1,42 @@
+package apitoken_test
+
+import (
+	"strings"
+	"testing"
+
+	"go-ooo/apitoken"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	token, hash, err := apitoken.Generate()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, "ooo_"))
+	require.Equal(t, apitoken.Hash(token), hash)
+	require.NotContains(t, hash, token)
+
+	other, _, err := apitoken.Generate()
+	require.NoError(t, err)
+	require.NotEqual(t, token, other)
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := apitoken.ParseScopes(" Read,fees,read ")
+	require.NoError(t, err)
+	require.Equal(t, []string{apitoken.ScopeRead, apitoken.ScopeFees}, scopes)
+
+	_, err = apitoken.ParseScopes("read,everything")
+	require.ErrorContains(t, err, "unknown scope everything")
+
+	_, err = apitoken.ParseScopes("")
+	require.Error(t, err)
+}
+
+func TestTaskScope(t *testing.T) {
+	require.Equal(t, apitoken.ScopeRead, apitoken.TaskScope("query_fees"))
+	require.Equal(t, apitoken.ScopeFees, apitoken.TaskScope("set_granular_fee"))
+	require.Equal(t, apitoken.ScopeWithdraw, apitoken.TaskScope("withdraw"))
+	require.Equal(t, apitoken.ScopeAdmin, apitoken.TaskScope("register"))
+	require.Equal(t, apitoken.ScopeAdmin, apitoken.TaskScope("something_new"))
+}
@@ -0,0 +1,279 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// request status returned by the Router's getRequestStatus for requests awaiting fulfilment
+const routerRequestStatusRequested = 1
+
+// PriceSourceManual is the price source recorded for operator supplied prices
+const PriceSourceManual = "manual"
+
+// number of blocks after which a fulfilment Tx unknown to the node is considered dropped
+const fulfilTxDroppedBlocks = 50
+
+func requestTaskError(task go_ooo_types.AdminTask, err error) go_ooo_types.AdminTaskResponse {
+	return go_ooo_types.AdminTaskResponse{
+		AdminTask: task,
+		Success:   false,
+		Error:     err.Error(),
+	}
+}
+
+func requestTaskSuccess(task go_ooo_types.AdminTask, result string) go_ooo_types.AdminTaskResponse {
+	return go_ooo_types.AdminTaskResponse{
+		AdminTask: task,
+		Success:   true,
+		Result:    result,
+	}
+}
+
+// findRequestForTask returns the request, if it exists and has not already been fulfilled
+func (o *OoORouterService) findRequestForTask(requestId string) (models.DataRequests, error) {
+	if requestId == "" {
+		return models.DataRequests{}, errors.New("request id required")
+	}
+
+	job, err := o.db.FindByRequestId(requestId)
+	if err != nil {
+		return job, errors.New(fmt.Sprintf("request %s not found: %s", requestId, err.Error()))
+	}
+
+	if job.GetRequestStatus() == models.REQUEST_STATUS_SUCCESS {
+		return job, errors.New(fmt.Sprintf("request %s already fulfilled", requestId))
+	}
+
+	return job, nil
+}
+
+// checkPendingOnChain returns an error if the Router no longer holds the request
+// awaiting fulfilment, e.g. it has already been fulfilled or cancelled
+func (o *OoORouterService) checkPendingOnChain(requestId string) error {
+	reqIdBytes32 := [32]byte{}
+	copy(reqIdBytes32[:], common.FromHex(requestId))
+
+	status, err := o.contractInstance.GetRequestStatus(&bind.CallOpts{Context: o.context}, reqIdBytes32)
+	if err != nil {
+		return err
+	}
+
+	if status != routerRequestStatusRequested {
+		return errors.New(fmt.Sprintf("request %s is not pending on chain. Router status %d", requestId, status))
+	}
+
+	return nil
+}
+
+// checkFulfilTxDropped returns an error if the request has a fulfilment Tx which may still be
+// mined, so that a second Tx is not sent while it is pending. The Tx is considered dropped if it
+// has no receipt and its nonce has been used by another Tx, or if the node has not seen it for
+// fulfilTxDroppedBlocks since it was sent
+func (o *OoORouterService) checkFulfilTxDropped(job models.DataRequests) error {
+	if job.GetRequestStatus() != models.REQUEST_STATUS_TX_SENT || job.GetFulfillTxHash() == "" {
+		return nil
+	}
+
+	txHash := common.HexToHash(job.GetFulfillTxHash())
+
+	_, err := o.client.TransactionReceipt(o.context, txHash)
+	if err == nil {
+		return errors.New(fmt.Sprintf("fulfilment tx %s has been mined. Wait for it to be processed", txHash.Hex()))
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+
+	txNonce := job.GetFulfillTxNonce()
+	tx, _, err := o.client.TransactionByHash(o.context, txHash)
+	txFound := err == nil
+	if txFound {
+		txNonce = tx.Nonce()
+	} else if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+
+	if txNonce > 0 {
+		nonce, err := o.client.NonceAt(o.context, o.oracleAddress, nil)
+		if err != nil {
+			return err
+		}
+		// nonce used by another Tx, so this one can never be mined
+		if nonce > txNonce {
+			return nil
+		}
+	}
+
+	if txFound {
+		return errors.New(fmt.Sprintf("fulfilment tx %s is still pending", txHash.Hex()))
+	}
+
+	currentBlockNum, err := o.client.BlockNumber(o.context)
+	if err != nil {
+		return err
+	}
+
+	if currentBlockNum < job.GetLastFulfillSentBlockNumber()+fulfilTxDroppedBlocks {
+		return errors.New(fmt.Sprintf("fulfilment tx %s not found, but may still be pending. Wait until block %d",
+			txHash.Hex(), job.GetLastFulfillSentBlockNumber()+fulfilTxDroppedBlocks))
+	}
+
+	return nil
+}
+
+// retryRequest puts a request back into the job queue. If a price has already been
+// fetched, the fulfilment Tx is re-sent using that price, otherwise the price is fetched
+func (o *OoORouterService) retryRequest(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	job, err := o.findRequestForTask(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.checkPendingOnChain(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.checkFulfilTxDropped(job)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	status := models.REQUEST_STATUS_INITIALISED
+	if job.GetPriceResult() != "" {
+		status = models.REQUEST_STATUS_DATA_READY_TO_SEND
+	}
+
+	err = o.db.ResetRequest(task.RequestId, status, "retried by operator", false)
+	if err != nil {
+		logger.ErrorWithFields("chain", "retryRequest", "reset request", err.Error(), logger.Fields{
+			"request_id": task.RequestId,
+		})
+		return requestTaskError(task, err)
+	}
+
+	job.RequestStatus = status
+	return requestTaskSuccess(task, fmt.Sprintf("request %s queued for retry with status %s",
+		task.RequestId, job.GetRequestStatusString()))
+}
+
+// refetchRequest discards any previously fetched price, and puts the request back into
+// the job queue so that the price is fetched again
+func (o *OoORouterService) refetchRequest(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	job, err := o.findRequestForTask(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.checkPendingOnChain(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.checkFulfilTxDropped(job)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.db.ResetRequest(task.RequestId, models.REQUEST_STATUS_INITIALISED, "re-fetch forced by operator", true)
+	if err != nil {
+		logger.ErrorWithFields("chain", "refetchRequest", "reset request", err.Error(), logger.Fields{
+			"request_id": task.RequestId,
+		})
+		return requestTaskError(task, err)
+	}
+
+	return requestTaskSuccess(task, fmt.Sprintf("request %s queued for price re-fetch", task.RequestId))
+}
+
+// abandonRequest removes a request from the job queue. No further attempts are made to
+// fulfil it
+func (o *OoORouterService) abandonRequest(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	if task.Reason == "" {
+		return requestTaskError(task, errors.New("reason required"))
+	}
+
+	_, err := o.findRequestForTask(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.db.UpdateRequestStatus(task.RequestId, models.REQUEST_STATUS_ABANDONED, task.Reason)
+	if err != nil {
+		logger.ErrorWithFields("chain", "abandonRequest", "update request status", err.Error(), logger.Fields{
+			"request_id": task.RequestId,
+		})
+		return requestTaskError(task, err)
+	}
+
+	return requestTaskSuccess(task, fmt.Sprintf("request %s abandoned", task.RequestId))
+}
+
+// fulfilRequest sends a fulfilment Tx using an operator supplied price, after checking
+// the request is still awaiting fulfilment on the Router
+func (o *OoORouterService) fulfilRequest(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	price, ok := new(big.Int).SetString(task.Price, 10)
+	if !ok || price.Sign() <= 0 {
+		return requestTaskError(task, errors.New(fmt.Sprintf("invalid price '%s'", task.Price)))
+	}
+
+	job, err := o.findRequestForTask(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.checkPendingOnChain(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.checkFulfilTxDropped(job)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	currentBlockNum, err := o.client.BlockNumber(o.context)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.db.ResetRequest(task.RequestId, models.REQUEST_STATUS_DATA_READY_TO_SEND, "price supplied by operator", false)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	err = o.db.UpdateDataFetched(task.RequestId, price.String(), PriceSourceManual)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	job, err = o.db.FindByRequestId(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	o.sendFulfillmentTx(job, currentBlockNum)
+
+	job, err = o.db.FindByRequestId(task.RequestId)
+	if err != nil {
+		return requestTaskError(task, err)
+	}
+
+	if job.GetRequestStatus() != models.REQUEST_STATUS_TX_SENT {
+		return requestTaskError(task, errors.New(fmt.Sprintf("fulfilment tx not sent: %s", job.GetStatusReason())))
+	}
+
+	resp := requestTaskSuccess(task, fmt.Sprintf("Sent! Tx Hash: %s", job.GetFulfillTxHash()))
+	resp.TxHash = job.GetFulfillTxHash()
+	return resp
+}
@@ -1,16 +1,26 @@
 package chain
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/params"
 	"go-ooo/logger"
 	go_ooo_types "go-ooo/types"
 	"math/big"
+	"strings"
 )
 
 func (o *OoORouterService) ProcessAdminTask(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
 
+	if o.dryRun && isTxTask(task.Task) {
+		return go_ooo_types.AdminTaskResponse{
+			AdminTask: task,
+			Success:   false,
+			Error:     "transactions cannot be sent in dry-run mode",
+		}
+	}
+
 	err := o.RenewTransactOpts()
 	if err != nil {
 		logger.Error("chain", "ProcessAdminTask", "RenewTransactOpts", err.Error())
@@ -37,6 +47,16 @@ func (o *OoORouterService) ProcessAdminTask(task go_ooo_types.AdminTask) go_ooo_
 		return o.queryFees(task)
 	case "query_granular_fees":
 		return o.queryGranularFees(task)
+	case "query_fee_config":
+		return o.queryFeeConfig(task)
+	case "retry_request":
+		return o.retryRequest(task)
+	case "refetch_request":
+		return o.refetchRequest(task)
+	case "abandon_request":
+		return o.abandonRequest(task)
+	case "fulfil_request":
+		return o.fulfilRequest(task)
 	default:
 		return go_ooo_types.AdminTaskResponse{
 			AdminTask: task,
@@ -45,6 +65,16 @@ func (o *OoORouterService) ProcessAdminTask(task go_ooo_types.AdminTask) go_ooo_
 	}
 }
 
+// isTxTask returns true if the admin task sends a Tx
+func isTxTask(task string) bool {
+	switch task {
+	case "register", "set_fee", "set_granular_fee", "withdraw", "fulfil_request":
+		return true
+	default:
+		return false
+	}
+}
+
 func (o *OoORouterService) registerAsProvider(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
 
 	var resp go_ooo_types.AdminTaskResponse
@@ -70,6 +100,7 @@ func (o *OoORouterService) registerAsProvider(task go_ooo_types.AdminTask) go_oo
 		o.setNextTxNonce(tx.Nonce(), false)
 
 		resp.Result = fmt.Sprintf("Sent! Tx Hash: %s", tx.Hash().String())
+		resp.TxHash = tx.Hash().Hex()
 		resp.Success = true
 	}
 
@@ -105,6 +136,7 @@ func (o *OoORouterService) setGlobalFee(task go_ooo_types.AdminTask) go_ooo_type
 		})
 
 		resp.Result = fmt.Sprintf("Sent! Tx Hash: %s", tx.Hash().String())
+		resp.TxHash = tx.Hash().Hex()
 		resp.Success = true
 		o.setNextTxNonce(tx.Nonce(), false)
 	}
@@ -143,6 +175,7 @@ func (o *OoORouterService) setGranularFee(task go_ooo_types.AdminTask) go_ooo_ty
 		})
 
 		resp.Result = fmt.Sprintf("Sent! Tx Hash: %s", tx.Hash().String())
+		resp.TxHash = tx.Hash().Hex()
 		resp.Success = true
 		o.setNextTxNonce(tx.Nonce(), false)
 	}
@@ -199,6 +232,7 @@ func (o *OoORouterService) withdraw(task go_ooo_types.AdminTask) go_ooo_types.Ad
 		})
 
 		resp.Result = fmt.Sprintf("Sent! Tx Hash: %s", tx.Hash().String())
+		resp.TxHash = tx.Hash().Hex()
 		resp.Success = true
 		o.setNextTxNonce(tx.Nonce(), false)
 	}
@@ -265,3 +299,47 @@ func (o *OoORouterService) queryGranularFees(task go_ooo_types.AdminTask) go_ooo
 
 	return resp
 }
+
+// queryFeeConfig returns the min fee, and the granular fee for each consumer which has sent
+// requests to the provider, plus any consumers in task.ToOrConsumer, as JSON
+func (o *OoORouterService) queryFeeConfig(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	var resp go_ooo_types.AdminTaskResponse
+	resp.AdminTask = task
+
+	consumers, err := o.db.GetConsumers(o.oracleAddress.Hex())
+	if err != nil {
+		logger.Error("chain", "queryFeeConfig", "get consumers", err.Error())
+		resp.Error = err.Error()
+		return resp
+	}
+
+	for _, c := range strings.Split(task.ToOrConsumer, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !common.IsHexAddress(c) {
+			resp.Error = fmt.Sprintf("invalid consumer address %s", c)
+			return resp
+		}
+		consumers = append(consumers, c)
+	}
+
+	fees, err := o.GetProviderFees(consumers)
+	if err != nil {
+		logger.Error("chain", "queryFeeConfig", "send query", err.Error())
+		resp.Error = err.Error()
+		return resp
+	}
+
+	result, err := json.Marshal(fees)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	resp.Result = string(result)
+	resp.Success = true
+
+	return resp
+}
@@ -0,0 +1,22 @@
+package chain
+
+import (
+	"go-ooo/ooo_api"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// Backend is the chain client used by the OoO Router service. It is satisfied by
+// *ethclient.Client, and by go-ethereum's simulated backend client for testing
+type Backend interface {
+	bind.ContractBackend
+	ethereum.BlockNumberReader
+	ethereum.TransactionReader
+	ethereum.ChainStateReader
+}
+
+// PriceQuerier fetches the price for a request's endpoint. It is satisfied by *ooo_api.OOOApi
+type PriceQuerier interface {
+	RouteQuery(endpoint string, requestId string) (ooo_api.PriceResult, error)
+}
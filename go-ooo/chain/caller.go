@@ -1,6 +1,10 @@
 package chain
 
 import (
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/params"
 	"go-ooo/logger"
 	"math/big"
@@ -57,3 +61,27 @@ func (o *OoORouterService) RenewTransactOpts() error {
 
 	return nil
 }
+
+// GetTxOutcome returns whether the Tx has been mined with enough block confirmations, and
+// if so, whether it was successful, the block it was mined in and the gas used
+func (o *OoORouterService) GetTxOutcome(txHash string) (confirmed bool, success bool, blockNumber uint64, gasUsed uint64, err error) {
+	receipt, err := o.client.TransactionReceipt(o.context, common.HexToHash(txHash))
+	if errors.Is(err, ethereum.NotFound) {
+		return false, false, 0, 0, nil
+	}
+	if err != nil {
+		return false, false, 0, 0, err
+	}
+
+	currentBlockNum, err := o.client.BlockNumber(o.context)
+	if err != nil {
+		return false, false, 0, 0, err
+	}
+
+	blockNumber = receipt.BlockNumber.Uint64()
+	if currentBlockNum-blockNumber < o.cfg.Jobs.WaitConfirmations {
+		return false, false, blockNumber, 0, nil
+	}
+
+	return true, receipt.Status == types.ReceiptStatusSuccessful, blockNumber, receipt.GasUsed, nil
+}
@@ -2,7 +2,6 @@ package chain
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"math/big"
 	"strings"
 	"time"
@@ -12,8 +11,7 @@ import (
 	"go-ooo/logger"
 	"go-ooo/ooo_api"
 	"go-ooo/ooo_router"
-	"go-ooo/utils"
-	"go-ooo/utils/walletworker"
+	"go-ooo/signer"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -21,13 +19,12 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/event"
 )
 
 type OoORouterService struct {
 	contractAddress  common.Address
-	client           *ethclient.Client
+	client           Backend
 	contractInstance *ooo_router.OooRouter
 	context          context.Context
 	cfg              *config.Config
@@ -39,12 +36,12 @@ type OoORouterService struct {
 	logRequestFulfilledHash common.Hash
 	contractAbi             abi.ABI
 
-	oracleAddress    common.Address
-	oraclePrivateKey *ecdsa.PrivateKey
+	oracleAddress common.Address
+	signer        signer.Signer
 
 	db *database.DB
 
-	oooApi *ooo_api.OOOApi
+	oooApi PriceQuerier
 
 	watchOpts            *bind.WatchOpts
 	chanDataRequests     chan *ooo_router.OooRouterDataRequested
@@ -59,11 +56,13 @@ type OoORouterService struct {
 	subscriptionRf event.Subscription
 
 	prevTxNonce uint64
+
+	dryRun bool
 }
 
-func NewOoORouter(ctx context.Context, cfg *config.Config, client *ethclient.Client,
+func NewOoORouter(ctx context.Context, cfg *config.Config, client Backend,
 	contractInstance *ooo_router.OooRouter, contractAddress common.Address,
-	oraclePrivateKey []byte, db *database.DB, oooApi *ooo_api.OOOApi) (*OoORouterService, error) {
+	oracleSigner signer.Signer, db *database.DB, oooApi PriceQuerier) (*OoORouterService, error) {
 
 	logDataRequestedHash := crypto.Keccak256Hash([]byte("DataRequested(address,address,uint256,bytes32,bytes32)"))
 	logRequestFulfilledHash := crypto.Keccak256Hash([]byte("RequestFulfilled(address,address,bytes32,uint256)"))
@@ -73,28 +72,13 @@ func NewOoORouter(ctx context.Context, cfg *config.Config, client *ethclient.Cli
 		return nil, err
 	}
 
-	oraclePrivateKeyECDSA, err := crypto.HexToECDSA(utils.RemoveHexPrefix(string(oraclePrivateKey)))
-	if err != nil {
-		return nil, err
-	}
-
-	oraclePublicKey := oraclePrivateKeyECDSA.Public()
-
-	ECDSAoraclePublicKey, err := crypto.UnmarshalPubkey(crypto.FromECDSAPub(oraclePublicKey.(*ecdsa.PublicKey)))
-	if err != nil || ECDSAoraclePublicKey == nil {
-		return nil, err
-	}
-	_, oracleAddressStr := walletworker.GenerateAddress(ECDSAoraclePublicKey)
-	oracleAddress := common.HexToAddress(oracleAddressStr)
+	oracleAddress := oracleSigner.Address()
 
 	logger.InfoWithFields("chain", "NewOoORouter", "", "set our wallet address", logger.Fields{
-		"address": oracleAddressStr,
+		"address": oracleAddress.Hex(),
 	})
 
-	transactOpts, err := bind.NewKeyedTransactorWithChainID(oraclePrivateKeyECDSA, big.NewInt(cfg.Chain.NetworkId))
-	if err != nil {
-		return nil, err
-	}
+	transactOpts := signer.NewTransactor(oracleSigner, big.NewInt(cfg.Chain.NetworkId))
 
 	nonce, err := client.PendingNonceAt(ctx, oracleAddress)
 	if err != nil {
@@ -108,7 +92,7 @@ func NewOoORouter(ctx context.Context, cfg *config.Config, client *ethclient.Cli
 	transactOpts.GasLimit = cfg.Chain.GasLimit // in units
 	transactOpts.Context = ctx
 
-	callOpts := &bind.CallOpts{From: common.HexToAddress(oracleAddressStr), Context: ctx}
+	callOpts := &bind.CallOpts{From: oracleAddress, Context: ctx}
 
 	// fromBlock - set first to 0
 	initialFromBlock := uint64(0)
@@ -153,7 +137,7 @@ func NewOoORouter(ctx context.Context, cfg *config.Config, client *ethclient.Cli
 		callOpts:                callOpts,
 		db:                      db,
 		oooApi:                  oooApi,
-		oraclePrivateKey:        oraclePrivateKeyECDSA,
+		signer:                  oracleSigner,
 		watchOpts:               watchOpts,
 		chanDataRequests:        chanDataRequests,
 		chanRequestFulfilled:    chanRequestFulfilled,
@@ -331,13 +315,23 @@ func (o *OoORouterService) RunEventWatchers() {
 			o.processIncomingRequests(ev)
 		case ev := <-o.chanRequestFulfilled:
 			o.processIncomingFulfilments(ev)
-		case subErr := <-o.subscriptionDr.Err():
+		case subErr, ok := <-o.subscriptionDr.Err():
+			if !ok {
+				// closed by Unsubscribe during Shutdown
+				logger.Info("chain", "RunEventWatchers", "", "DataRequested subscription closed")
+				return
+			}
 			if subErr != nil {
 				logger.Error("chain", "RunEventWatchers", "DataRequested subscription connection error", subErr.Error())
 				o.subscribeToDataRequested(me)
 			}
 
-		case subErr := <-o.subscriptionRf.Err():
+		case subErr, ok := <-o.subscriptionRf.Err():
+			if !ok {
+				// closed by Unsubscribe during Shutdown
+				logger.Info("chain", "RunEventWatchers", "", "RequestFulfilled subscription closed")
+				return
+			}
 			if subErr != nil {
 				logger.Error("chain", "RunEventWatchers", "RequestFulfilled subscription connection error", subErr.Error())
 				o.subscribeToRequestFulfilled(me)
@@ -410,6 +404,14 @@ func (o *OoORouterService) processIncomingFulfilments(event *ooo_router.OooRoute
 			"request_id": requestId,
 		})
 
+	// the fulfilment was sent by the production node, so is only recorded for comparison. The
+	// request is left as priced by the job queue
+	if o.dryRun {
+		o.recordShadowOnChainResult(event.Consumer, requestId, event.RequestedData, event.Raw.TxHash.Hex(), event.Raw.BlockNumber)
+		o.setLastBlockNumber(event.Raw.BlockNumber)
+		return
+	}
+
 	gasPrice, gasUsed := o.processGasUsage(event.Raw)
 	// check status and if requests already exists
 	reqDbRes, _ := o.db.FindByRequestId(requestId)
@@ -441,6 +443,28 @@ func (o *OoORouterService) processIncomingFulfilments(event *ooo_router.OooRoute
 
 }
 
+// GetTxGasUsage returns the gas used by a mined Tx, and the price paid per gas. ethereum.NotFound
+// is returned if the Tx has not been mined, for example because it was replaced
+func (o *OoORouterService) GetTxGasUsage(txHash string) (uint64, uint64, error) {
+	hash := common.HexToHash(txHash)
+
+	txRec, err := o.client.TransactionReceipt(o.context, hash)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if txRec.EffectiveGasPrice != nil && txRec.EffectiveGasPrice.Sign() > 0 {
+		return txRec.GasUsed, txRec.EffectiveGasPrice.Uint64(), nil
+	}
+
+	tx, _, err := o.client.TransactionByHash(o.context, hash)
+	if err != nil {
+		return txRec.GasUsed, 0, err
+	}
+
+	return txRec.GasUsed, tx.GasPrice().Uint64(), nil
+}
+
 func (o *OoORouterService) processGasUsage(evLog types.Log) (uint64, uint64) {
 	gasPrice := uint64(0)
 	gasUsed := uint64(0)
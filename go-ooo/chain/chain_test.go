@@ -0,0 +1,413 @@
+package chain_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-ooo/chain"
+	"go-ooo/database/models"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestAndFulfil(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+	defer h.stopService()
+
+	requestId := h.requestData("ETH.USD.PR.AVC.24H")
+	req := h.fulfil(requestId)
+
+	require.Equal(t, "ETH.USD.PR.AVC.24H", req.GetEndpointDecoded())
+	require.Equal(t, "1000000000000000000", req.GetPriceResult())
+	require.Equal(t, "fake", req.GetPriceSource())
+	require.NotEmpty(t, req.GetFulfillTxHash())
+	require.Equal(t, models.JOB_STATUS_SUCCESS, req.GetJobStatus())
+
+	require.Equal(t, "1000000000000000000", h.consumerPrice().String())
+	require.Equal(t, uint8(0), h.requestStatusOnChain(requestId))
+}
+
+func TestApiErrorRetried(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+	defer h.stopService()
+
+	h.api.set("", errFakeApi)
+
+	requestId := h.requestData("BTC.USD.PR.AVC.24H")
+	h.waitForStatus(requestId, models.REQUEST_STATUS_INITIALISED)
+
+	h.commit(1)
+	h.processJobs()
+	req := h.waitForStatus(requestId, models.REQUEST_STATUS_API_ERROR)
+	require.Equal(t, errFakeApi.Error(), req.GetStatusReason())
+
+	// next run re-fetches the data
+	h.api.set("2000000000000000000", nil)
+	h.processJobs()
+	h.waitForStatus(requestId, models.REQUEST_STATUS_DATA_READY_TO_SEND)
+
+	h.processJobs()
+	h.waitForStatus(requestId, models.REQUEST_STATUS_TX_SENT)
+	h.commit(1)
+	h.waitForStatus(requestId, models.REQUEST_STATUS_SUCCESS)
+
+	require.Equal(t, "2000000000000000000", h.consumerPrice().String())
+}
+
+func TestFulfilReverts(t *testing.T) {
+	h := newHarness(t)
+
+	// the Router requires at least 400,000 gas left before calling the consumer
+	h.cfg.Chain.GasLimit = 300000
+
+	h.startService()
+	defer h.stopService()
+
+	requestId := h.requestData("ETH.USD.PR.AVC.24H")
+	h.waitForStatus(requestId, models.REQUEST_STATUS_INITIALISED)
+
+	h.commit(1)
+	h.processJobs()
+	h.waitForStatus(requestId, models.REQUEST_STATUS_DATA_READY_TO_SEND)
+
+	h.processJobs()
+	req := h.waitForStatus(requestId, models.REQUEST_STATUS_TX_SENT)
+	revertedTx := req.GetFulfillTxHash()
+
+	// mine the reverted Tx, and wait long enough for it to be checked
+	h.commit(3)
+	h.processJobs()
+
+	var failed []models.FailedFulfilment
+	require.NoError(t, h.db.Where("request_id = ?", requestId).Find(&failed).Error)
+	require.Len(t, failed, 1)
+	require.Equal(t, revertedTx, failed[0].GetTxHash())
+	require.Equal(t, "tx reverted", failed[0].GetFailReason())
+	require.NotZero(t, failed[0].GetGasUsed())
+	require.NotZero(t, failed[0].GetGasPrice())
+
+	gasUsed, gasPrice, err := h.service.GetTxGasUsage(revertedTx)
+	require.NoError(t, err)
+	require.Equal(t, failed[0].GetGasUsed(), gasUsed)
+	require.Equal(t, failed[0].GetGasPrice(), gasPrice)
+
+	// fulfilment is re-sent, and the request is still pending on chain
+	req = h.waitForStatus(requestId, models.REQUEST_STATUS_TX_SENT)
+	require.NotEqual(t, revertedTx, req.GetFulfillTxHash())
+	require.Equal(t, uint8(1), h.requestStatusOnChain(requestId))
+	require.Equal(t, "0", h.consumerPrice().String())
+}
+
+func TestReorg(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+	defer h.stopService()
+
+	parent, err := h.client.HeaderByNumber(h.ctx, nil)
+	require.NoError(t, err)
+
+	requestId := h.requestData("ETH.USD.PR.AVC.24H")
+	req := h.waitForStatus(requestId, models.REQUEST_STATUS_INITIALISED)
+
+	reqTx := common.HexToHash(req.GetRequestTxHash())
+	before, err := h.client.TransactionReceipt(h.ctx, reqTx)
+	require.NoError(t, err)
+
+	// replace the block containing the request with a longer side chain. The request Tx
+	// is returned to the Tx pool and mined again on the new chain
+	require.NoError(t, h.backend.Fork(parent.Hash()))
+	h.commit(2)
+
+	after, err := h.client.TransactionReceipt(h.ctx, reqTx)
+	require.NoError(t, err)
+	require.NotEqual(t, before.BlockHash, after.BlockHash)
+
+	// the request is still fulfilled once on the new chain
+	h.fulfil(requestId)
+
+	var count int64
+	require.NoError(t, h.db.Model(&models.DataRequests{}).Where("request_id = ?", requestId).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+	require.Equal(t, uint8(0), h.requestStatusOnChain(requestId))
+}
+
+func TestRestart(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+
+	first := h.requestData("ETH.USD.PR.AVC.24H")
+	h.fulfil(first)
+
+	h.stopService()
+
+	// requests made while the service is down are picked up from the event history
+	second := h.requestData("BTC.USD.PR.AVC.24H")
+	h.commit(2)
+
+	h.startService()
+	defer h.stopService()
+
+	h.fulfil(second)
+
+	// the first request is not processed again
+	require.Equal(t, models.REQUEST_STATUS_SUCCESS, h.getRequest(first).GetRequestStatus())
+	require.Equal(t, 2, h.api.calls)
+}
+
+func TestAdminFulfilRequest(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+	defer h.stopService()
+
+	h.api.set("", errFakeApi)
+
+	requestId := h.requestData("BTC.USD.PR.AVC.24H")
+	h.waitForStatus(requestId, models.REQUEST_STATUS_INITIALISED)
+	h.commit(1)
+	h.processJobs()
+	h.waitForStatus(requestId, models.REQUEST_STATUS_API_ERROR)
+
+	task := go_ooo_types.AdminTask{Task: "fulfil_request", RequestId: requestId, Price: "abc"}
+	resp := h.service.ProcessAdminTask(task)
+	require.False(t, resp.Success)
+
+	task.Price = "5000000000000000000"
+	resp = h.service.ProcessAdminTask(task)
+	require.True(t, resp.Success, resp.Error)
+	require.NotEmpty(t, resp.TxHash)
+
+	confirmed, _, _, _, err := h.service.GetTxOutcome(resp.TxHash)
+	require.NoError(t, err)
+	require.False(t, confirmed)
+
+	h.commit(1)
+	req := h.waitForStatus(requestId, models.REQUEST_STATUS_SUCCESS)
+	require.Equal(t, chain.PriceSourceManual, req.GetPriceSource())
+	require.Equal(t, "5000000000000000000", h.consumerPrice().String())
+
+	h.commit(int(h.cfg.Jobs.WaitConfirmations))
+	confirmed, success, blockNumber, gasUsed, err := h.service.GetTxOutcome(resp.TxHash)
+	require.NoError(t, err)
+	require.True(t, confirmed)
+	require.True(t, success)
+	require.NotZero(t, blockNumber)
+	require.NotZero(t, gasUsed)
+
+	// no longer pending
+	resp = h.service.ProcessAdminTask(task)
+	require.False(t, resp.Success)
+	require.Contains(t, resp.Error, "already fulfilled")
+}
+
+func TestAdminAbandonRetryAndRefetch(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+	defer h.stopService()
+
+	requestId := h.requestData("ETH.USD.PR.AVC.24H")
+	h.waitForStatus(requestId, models.REQUEST_STATUS_INITIALISED)
+
+	resp := h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: "abandon_request", RequestId: requestId})
+	require.False(t, resp.Success)
+	require.Equal(t, "reason required", resp.Error)
+
+	resp = h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: "abandon_request", RequestId: requestId, Reason: "testing"})
+	require.True(t, resp.Success, resp.Error)
+
+	req := h.getRequest(requestId)
+	require.Equal(t, models.REQUEST_STATUS_ABANDONED, req.GetRequestStatus())
+	require.Equal(t, models.JOB_STATUS_FAIL, req.GetJobStatus())
+	require.Equal(t, "testing", req.GetStatusReason())
+
+	// abandoned requests are not processed
+	h.commit(1)
+	h.processJobs()
+	require.Equal(t, models.REQUEST_STATUS_ABANDONED, h.getRequest(requestId).GetRequestStatus())
+
+	resp = h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: "retry_request", RequestId: requestId})
+	require.True(t, resp.Success, resp.Error)
+	require.Equal(t, models.JOB_STATUS_PENDING, h.getRequest(requestId).GetJobStatus())
+
+	h.processJobs()
+	h.waitForStatus(requestId, models.REQUEST_STATUS_DATA_READY_TO_SEND)
+
+	// re-fetch discards the price
+	h.api.set("3000000000000000000", nil)
+	resp = h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: "refetch_request", RequestId: requestId})
+	require.True(t, resp.Success, resp.Error)
+	req = h.getRequest(requestId)
+	require.Equal(t, models.REQUEST_STATUS_INITIALISED, req.GetRequestStatus())
+	require.Empty(t, req.GetPriceResult())
+
+	req = h.fulfil(requestId)
+	require.Equal(t, "3000000000000000000", req.GetPriceResult())
+	require.Equal(t, "3000000000000000000", h.consumerPrice().String())
+}
+
+func TestAdminQueryFeeConfig(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+	defer h.stopService()
+
+	requestId := h.requestData("ETH.USD.PR.AVC.24H")
+	h.fulfil(requestId)
+
+	resp := h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: "set_granular_fee", FeeOrAmount: 2 * testFee, ToOrConsumer: h.consumerAddress.Hex()})
+	require.True(t, resp.Success, resp.Error)
+	h.commit(1)
+
+	// consumers are read from the database, and can be added in ToOrConsumer
+	other := common.HexToAddress("0x0000000000000000000000000000000000000123")
+	resp = h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: "query_fee_config", ToOrConsumer: other.Hex()})
+	require.True(t, resp.Success, resp.Error)
+
+	var fees go_ooo_types.ProviderFees
+	require.NoError(t, json.Unmarshal([]byte(resp.Result), &fees))
+	require.Equal(t, uint64(testFee), fees.MinFee)
+	require.Equal(t, map[string]uint64{
+		h.consumerAddress.Hex(): 2 * testFee,
+		other.Hex():             testFee,
+	}, fees.GranularFees)
+
+	resp = h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: "query_fee_config", ToOrConsumer: "invalid"})
+	require.False(t, resp.Success)
+}
+
+func TestDryRun(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+	defer h.stopService()
+
+	// a dry-run node for the same provider, alongside the production node
+	dryDb := h.newDb()
+	dry := h.newService(dryDb, true)
+	defer dry.Shutdown()
+
+	dryStatus := func(requestId string) int {
+		req, _ := dryDb.FindByRequestId(requestId)
+		return req.GetRequestStatus()
+	}
+
+	nonce, err := h.client.PendingNonceAt(h.ctx, h.provider)
+	require.NoError(t, err)
+
+	requestId := h.requestData("ETH.USD.PR.AVC.24H")
+	require.Eventually(t, func() bool {
+		return dryStatus(requestId) == models.REQUEST_STATUS_INITIALISED
+	}, 10*time.Second, 50*time.Millisecond)
+
+	h.commit(int(h.cfg.Jobs.WaitConfirmations))
+	dry.ProcessPendingJobQueue()
+	require.Eventually(t, func() bool {
+		return dryStatus(requestId) == models.REQUEST_STATUS_DATA_READY_TO_SEND
+	}, 10*time.Second, 50*time.Millisecond)
+
+	dry.ProcessPendingJobQueue()
+	require.Eventually(t, func() bool {
+		return dryStatus(requestId) == models.REQUEST_STATUS_DRY_RUN
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// no Tx was sent, and the request is still pending on chain
+	h.commit(1)
+	after, err := h.client.PendingNonceAt(h.ctx, h.provider)
+	require.NoError(t, err)
+	require.Equal(t, nonce, after)
+	require.Equal(t, uint8(1), h.requestStatusOnChain(requestId))
+
+	shadows, err := dryDb.GetShadowFulfilments()
+	require.NoError(t, err)
+	require.Len(t, shadows, 1)
+	require.Equal(t, requestId, shadows[0].GetRequestId())
+	require.Equal(t, "1000000000000000000", shadows[0].GetPrice())
+	require.NotEmpty(t, shadows[0].GetTxData())
+	require.Empty(t, shadows[0].GetOnChainTxHash())
+
+	// the production node fulfils the request
+	prodReq := h.fulfil(requestId)
+
+	// the dry-run node records the on-chain result in the shadow table only
+	require.Eventually(t, func() bool {
+		shadows, _ = dryDb.GetShadowFulfilments()
+		return len(shadows) == 1 && shadows[0].GetOnChainTxHash() == prodReq.GetFulfillTxHash()
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, "1000000000000000000", shadows[0].GetOnChainPrice())
+
+	dryReq, err := dryDb.FindByRequestId(requestId)
+	require.NoError(t, err)
+	require.Equal(t, models.REQUEST_STATUS_DRY_RUN, dryReq.GetRequestStatus())
+	require.Empty(t, dryReq.GetFulfillTxHash())
+	require.Zero(t, dryReq.GetFulfillGasUsed())
+}
+
+func TestAdminRetrySentTx(t *testing.T) {
+	h := newHarness(t)
+	h.startService()
+	defer h.stopService()
+
+	retry := func(requestId string) go_ooo_types.AdminTaskResponse {
+		return h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: "retry_request", RequestId: requestId})
+	}
+
+	requestId := h.requestData("ETH.USD.PR.AVC.24H")
+	h.waitForStatus(requestId, models.REQUEST_STATUS_INITIALISED)
+	h.commit(1)
+	h.processJobs()
+	h.waitForStatus(requestId, models.REQUEST_STATUS_DATA_READY_TO_SEND)
+	h.processJobs()
+	req := h.waitForStatus(requestId, models.REQUEST_STATUS_TX_SENT)
+
+	// the fulfilment Tx is in the Tx pool, so a second is not sent
+	resp := retry(requestId)
+	require.False(t, resp.Success)
+	require.Contains(t, resp.Error, "is still pending")
+
+	for _, task := range []string{"refetch_request", "fulfil_request"} {
+		resp = h.service.ProcessAdminTask(go_ooo_types.AdminTask{Task: task, RequestId: requestId, Price: "1"})
+		require.False(t, resp.Success, task)
+		require.Contains(t, resp.Error, "is still pending", task)
+	}
+
+	// mined, but not yet processed
+	second := h.requestData("BTC.USD.PR.AVC.24H")
+	h.waitForStatus(second, models.REQUEST_STATUS_INITIALISED)
+	h.waitForStatus(requestId, models.REQUEST_STATUS_SUCCESS)
+	require.NoError(t, h.db.UpdateFulfillmentSent(second, req.GetFulfillTxHash(), req.GetFulfillTxNonce(), 0))
+	require.NoError(t, h.db.UpdateRequestStatus(second, models.REQUEST_STATUS_TX_SENT, ""))
+
+	resp = retry(second)
+	require.False(t, resp.Success)
+	require.Contains(t, resp.Error, "has been mined")
+
+	// a Tx the node has not seen, with an unused nonce, is only considered dropped after a timeout
+	current, err := h.client.BlockNumber(h.ctx)
+	require.NoError(t, err)
+	nextNonce, err := h.client.PendingNonceAt(h.ctx, h.provider)
+	require.NoError(t, err)
+	unknownTx := common.HexToHash("0x01").Hex()
+	require.NoError(t, h.db.UpdateFulfillmentSent(second, unknownTx, nextNonce, current))
+	require.NoError(t, h.db.UpdateRequestStatus(second, models.REQUEST_STATUS_TX_SENT, ""))
+
+	resp = retry(second)
+	require.False(t, resp.Success)
+	require.Contains(t, resp.Error, "not found, but may still be pending")
+
+	h.commit(50)
+	resp = retry(second)
+	require.True(t, resp.Success, resp.Error)
+	require.Equal(t, models.REQUEST_STATUS_INITIALISED, h.getRequest(second).GetRequestStatus())
+
+	// a Tx whose nonce has been used by another Tx is dropped
+	require.NoError(t, h.db.UpdateFulfillmentSent(second, common.HexToHash("0x02").Hex(), req.GetFulfillTxNonce(), current+50))
+	require.NoError(t, h.db.UpdateRequestStatus(second, models.REQUEST_STATUS_TX_SENT, ""))
+
+	resp = retry(second)
+	require.True(t, resp.Success, resp.Error)
+	require.Equal(t, models.REQUEST_STATUS_INITIALISED, h.getRequest(second).GetRequestStatus())
+
+	h.fulfil(second)
+}
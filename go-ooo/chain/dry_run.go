@@ -0,0 +1,105 @@
+package chain
+
+import (
+	"math/big"
+
+	"go-ooo/database/models"
+	"go-ooo/logger"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// SetDryRun enables dry-run mode. Requests are watched and priced as normal, but fulfilment
+// Txs are recorded in the shadow_fulfilments table instead of being sent
+func (o *OoORouterService) SetDryRun(dryRun bool) {
+	o.dryRun = dryRun
+}
+
+func (o *OoORouterService) IsDryRun() bool {
+	return o.dryRun
+}
+
+// recordShadowFulfilment stores the price and the fulfillRequest Tx that would have been sent
+func (o *OoORouterService) recordShadowFulfilment(job models.DataRequests, reqIdBytes32 [32]byte,
+	price *big.Int, signatureBytes []byte, currentBlockNum uint64) {
+
+	requestId := job.GetRequestId()
+
+	txData, err := o.contractAbi.Pack("fulfillRequest", reqIdBytes32, price, signatureBytes)
+
+	if err != nil {
+		logger.ErrorWithFields("chain", "recordShadowFulfilment", "pack tx data",
+			err.Error(),
+			logger.Fields{
+				"request_id": requestId,
+			})
+
+		_ = o.db.UpdateRequestStatus(requestId, models.REQUEST_STATUS_TX_FAILED, err.Error())
+		return
+	}
+
+	shadow := models.ShadowFulfilments{
+		RequestId:       requestId,
+		Consumer:        job.GetConsumer(),
+		EndpointDecoded: job.GetEndpointDecoded(),
+		Price:           price.String(),
+		PriceSource:     job.GetPriceSource(),
+		BlockNumber:     currentBlockNum,
+		TxData:          hexutil.Encode(txData),
+		Signature:       hexutil.Encode(signatureBytes),
+		GasLimit:        o.transactOpts.GasLimit,
+	}
+
+	if o.transactOpts.Nonce != nil {
+		shadow.Nonce = o.transactOpts.Nonce.Uint64()
+	}
+
+	if o.transactOpts.GasPrice != nil {
+		shadow.GasPrice = o.transactOpts.GasPrice.Uint64()
+	}
+
+	err = o.db.InsertShadowFulfilment(shadow)
+
+	if err != nil {
+		logger.ErrorWithFields("chain", "recordShadowFulfilment", "insert shadow fulfilment",
+			err.Error(),
+			logger.Fields{
+				"request_id": requestId,
+			})
+
+		_ = o.db.UpdateRequestStatus(requestId, models.REQUEST_STATUS_TX_FAILED, err.Error())
+		return
+	}
+
+	logger.InfoWithFields("chain", "recordShadowFulfilment", "",
+		"dry-run: fulfill tx recorded, not sent",
+		logger.Fields{
+			"request_id": requestId,
+			"price":      price.String(),
+			"to":         o.contractAddress.Hex(),
+		})
+
+	_ = o.db.UpdateRequestStatus(requestId, models.REQUEST_STATUS_DRY_RUN, "")
+}
+
+// recordShadowOnChainResult stores the production node's fulfilment so that it can be
+// compared with the shadow result
+func (o *OoORouterService) recordShadowOnChainResult(consumer common.Address, requestId string,
+	price *big.Int, txHash string, blockNumber uint64) {
+
+	priceStr := ""
+	if price != nil {
+		priceStr = price.String()
+	}
+
+	err := o.db.UpdateShadowOnChainResult(requestId, consumer.Hex(), priceStr, txHash, blockNumber)
+
+	if err != nil {
+		logger.ErrorWithFields("chain", "recordShadowOnChainResult", "update shadow fulfilment",
+			err.Error(),
+			logger.Fields{
+				"request_id": requestId,
+			})
+	}
+}
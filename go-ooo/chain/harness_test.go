@@ -0,0 +1,311 @@
+package chain_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-ooo/chain"
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	"go-ooo/ooo_api"
+	"go-ooo/ooo_router"
+	"go-ooo/signer"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/stretchr/testify/require"
+)
+
+// contracts compiled by the smart-contracts truffle project
+const bindingsPath = "../../smart-contracts/abigenBindings"
+
+const (
+	simChainId = 1337
+	testFee    = 100000000 // 0.1 xFUND
+)
+
+// fakeApi implements chain.PriceQuerier, returning a fixed price or error
+type fakeApi struct {
+	mu    sync.Mutex
+	price string
+	err   error
+	calls int
+}
+
+func (f *fakeApi) RouteQuery(endpoint string, requestId string) (ooo_api.PriceResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return ooo_api.PriceResult{}, f.err
+	}
+	return ooo_api.PriceResult{Price: f.price, Source: "fake"}, nil
+}
+
+func (f *fakeApi) set(price string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.price = price
+	f.err = err
+}
+
+// harness runs an OoORouterService against a simulated chain, with the Router, a mock
+// xFUND token and a mock consumer deployed
+type harness struct {
+	t       *testing.T
+	ctx     context.Context
+	backend *simulated.Backend
+	client  simulated.Client
+	cfg     *config.Config
+	db      *database.DB
+	api     *fakeApi
+
+	ownerKey    *ecdsa.PrivateKey
+	providerKey *ecdsa.PrivateKey
+	provider    common.Address
+
+	routerAddress   common.Address
+	consumerAddress common.Address
+	router          *ooo_router.OooRouter
+	consumer        *bind.BoundContract
+
+	service *chain.OoORouterService
+}
+
+func newHarness(t *testing.T) *harness {
+	logger.SetLogLevel("fatal")
+
+	ownerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	providerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	eth := new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(ownerKey.PublicKey):    {Balance: eth},
+		crypto.PubkeyToAddress(providerKey.PublicKey): {Balance: eth},
+	})
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+
+	cfg := config.DefaultConfig()
+	cfg.Chain.NetworkId = simChainId
+	cfg.Chain.GasLimit = 500000
+	cfg.Jobs.WaitConfirmations = 1
+	cfg.Database.Dialect = "sqlite"
+	cfg.Database.Storage = filepath.Join(t.TempDir(), "go-ooo.sqlite")
+
+	db, err := database.NewDb(cfg)
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+
+	h := &harness{
+		t:           t,
+		ctx:         context.Background(),
+		backend:     backend,
+		client:      backend.Client(),
+		cfg:         cfg,
+		db:          db,
+		api:         &fakeApi{price: "1000000000000000000"},
+		ownerKey:    ownerKey,
+		providerKey: providerKey,
+		provider:    crypto.PubkeyToAddress(providerKey.PublicKey),
+	}
+
+	h.deployContracts()
+
+	return h
+}
+
+func loadContract(t *testing.T, name string) (abi.ABI, []byte) {
+	abiJson, err := os.ReadFile(filepath.Join(bindingsPath, "abi", name+".abi"))
+	require.NoError(t, err)
+	bin, err := os.ReadFile(filepath.Join(bindingsPath, "bin", name+".bin"))
+	require.NoError(t, err)
+
+	parsed, err := abi.JSON(strings.NewReader(string(abiJson)))
+	require.NoError(t, err)
+
+	return parsed, common.FromHex(strings.TrimSpace(string(bin)))
+}
+
+func (h *harness) transactor(key *ecdsa.PrivateKey) *bind.TransactOpts {
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(simChainId))
+	require.NoError(h.t, err)
+	opts.Context = h.ctx
+	return opts
+}
+
+func (h *harness) deploy(name string, params ...interface{}) (common.Address, *bind.BoundContract) {
+	parsed, bin := loadContract(h.t, name)
+	address, _, contract, err := bind.DeployContract(h.transactor(h.ownerKey), parsed, bin, h.client, params...)
+	require.NoError(h.t, err, name)
+	h.backend.Commit()
+	return address, contract
+}
+
+// transact sends a Tx, mines it and requires that it succeeded
+func (h *harness) transact(contract *bind.BoundContract, key *ecdsa.PrivateKey, method string, params ...interface{}) *types.Receipt {
+	tx, err := contract.Transact(h.transactor(key), method, params...)
+	require.NoError(h.t, err, method)
+	h.backend.Commit()
+
+	receipt, err := h.client.TransactionReceipt(h.ctx, tx.Hash())
+	require.NoError(h.t, err, method)
+	require.Equal(h.t, types.ReceiptStatusSuccessful, receipt.Status, method)
+
+	return receipt
+}
+
+func (h *harness) deployContracts() {
+	supply := new(big.Int).Mul(big.NewInt(1000000), big.NewInt(1e9))
+	tokenAddress, token := h.deploy("MockToken", "xFUND", "xFUND", supply, uint8(9))
+
+	var routerContract *bind.BoundContract
+	h.routerAddress, routerContract = h.deploy("Router", tokenAddress)
+	h.consumerAddress, h.consumer = h.deploy("MockConsumer", h.routerAddress, tokenAddress)
+
+	// fund the consumer, and allow the router to take fees
+	amount := new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e9))
+	h.transact(token, h.ownerKey, "transfer", h.consumerAddress, amount)
+	h.transact(h.consumer, h.ownerKey, "increaseRouterAllowance", amount)
+
+	// register the provider
+	h.transact(routerContract, h.providerKey, "registerAsProvider", big.NewInt(testFee))
+
+	router, err := ooo_router.NewOooRouter(h.routerAddress, h.client)
+	require.NoError(h.t, err)
+	h.router = router
+}
+
+// startService creates a new OoORouterService using the harness DB, processes any
+// historical events and starts the event watchers
+func (h *harness) startService() {
+	h.service = h.newService(h.db, false)
+}
+
+// newService creates and starts an OoORouterService for the provider using db, in dry-run
+// mode if dryRun is true. The caller must shut it down
+func (h *harness) newService(db *database.DB, dryRun bool) *chain.OoORouterService {
+	pk := common.Bytes2Hex(crypto.FromECDSA(h.providerKey))
+	providerSigner, err := signer.NewKeystoreSigner(pk)
+	require.NoError(h.t, err)
+
+	service, err := chain.NewOoORouter(h.ctx, h.cfg, h.client, h.router, h.routerAddress, providerSigner, db, h.api)
+	require.NoError(h.t, err)
+	service.SetDryRun(dryRun)
+
+	service.GetHistoricalEvents()
+	go service.RunEventWatchers()
+
+	return service
+}
+
+// newDb returns a new, migrated sqlite DB, e.g. for a second node
+func (h *harness) newDb() *database.DB {
+	cfg := *h.cfg
+	cfg.Database.Storage = filepath.Join(h.t.TempDir(), "go-ooo.sqlite")
+
+	db, err := database.NewDb(&cfg)
+	require.NoError(h.t, err)
+	require.NoError(h.t, db.Migrate())
+
+	return db
+}
+
+func (h *harness) stopService() {
+	h.service.Shutdown()
+	h.service = nil
+}
+
+func (h *harness) commit(blocks int) {
+	for i := 0; i < blocks; i++ {
+		h.backend.Commit()
+	}
+}
+
+// requestData sends a data request from the consumer and returns the request ID
+func (h *harness) requestData(endpoint string) string {
+	var data [32]byte
+	copy(data[:], endpoint)
+
+	receipt := h.transact(h.consumer, h.ownerKey, "getData", h.provider, big.NewInt(testFee), data)
+
+	for _, l := range receipt.Logs {
+		ev, err := h.router.ParseDataRequested(*l)
+		if err == nil {
+			return common.Bytes2Hex(ev.RequestId[:])
+		}
+	}
+
+	h.t.Fatal("no DataRequested event emitted")
+	return ""
+}
+
+func (h *harness) processJobs() {
+	h.service.ProcessPendingJobQueue()
+}
+
+func (h *harness) getRequest(requestId string) *models.DataRequests {
+	req, _ := h.db.FindByRequestId(requestId)
+	return &req
+}
+
+func (h *harness) waitForStatus(requestId string, status int) *models.DataRequests {
+	require.Eventually(h.t, func() bool {
+		return h.getRequest(requestId).GetRequestStatus() == status
+	}, 10*time.Second, 50*time.Millisecond, "waiting for status %d. Current %d", status,
+		h.getRequest(requestId).GetRequestStatus())
+
+	return h.getRequest(requestId)
+}
+
+func (h *harness) consumerPrice() *big.Int {
+	var out []interface{}
+	err := h.consumer.Call(&bind.CallOpts{Context: h.ctx}, &out, "price")
+	require.NoError(h.t, err)
+	return out[0].(*big.Int)
+}
+
+func (h *harness) requestStatusOnChain(requestId string) uint8 {
+	var reqId [32]byte
+	copy(reqId[:], common.FromHex(requestId))
+	status, err := h.router.GetRequestStatus(&bind.CallOpts{Context: h.ctx}, reqId)
+	require.NoError(h.t, err)
+	return status
+}
+
+// fulfil drives a request from INITIALISED through to SUCCESS
+func (h *harness) fulfil(requestId string) *models.DataRequests {
+	h.waitForStatus(requestId, models.REQUEST_STATUS_INITIALISED)
+
+	// wait for confirmations, then fetch data
+	h.commit(int(h.cfg.Jobs.WaitConfirmations))
+	h.processJobs()
+	h.waitForStatus(requestId, models.REQUEST_STATUS_DATA_READY_TO_SEND)
+
+	// send the fulfilment Tx
+	h.processJobs()
+	h.waitForStatus(requestId, models.REQUEST_STATUS_TX_SENT)
+
+	// mine it, and wait for the RequestFulfilled event
+	h.commit(1)
+	return h.waitForStatus(requestId, models.REQUEST_STATUS_SUCCESS)
+}
+
+var errFakeApi = errors.New("fake api error")
@@ -1,14 +1,14 @@
 package chain
 
 import (
-	"fmt"
+	"errors"
 	"math/big"
 
 	"go-ooo/database/models"
 	"go-ooo/logger"
+	"go-ooo/ooo_api"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	solsha3 "github.com/miguelmota/go-solidity-sha3"
 )
 
@@ -16,8 +16,8 @@ func (o *OoORouterService) ProcessPendingJobQueue() {
 
 	logger.Info("chain", "ProcessPendingJobQueue", "check job queue", "")
 
-	// get pending requests from data_requests table
-	requests, err := o.db.GetPendingJobs()
+	// get pending requests sent to our provider address from data_requests table
+	requests, err := o.db.GetPendingJobsForProvider(o.oracleAddress.Hex())
 
 	if err != nil {
 		logger.Error("chain", "ProcessPendingJobQueue", "get job queue", err.Error())
@@ -163,7 +163,20 @@ func (o *OoORouterService) processFulfillmentFetchData(job models.DataRequests,
 
 	endpoint := job.GetEndpointDecoded()
 
-	price, err := o.oooApi.RouteQuery(endpoint, requestId)
+	result, err := o.oooApi.RouteQuery(endpoint, requestId)
+
+	var rejectedErr *ooo_api.PriceRejectedError
+	if errors.As(err, &rejectedErr) {
+		logger.WarnWithFields("chain", "processFulfillmentFetchData", "run api query",
+			"price rejected by guards",
+			logger.Fields{
+				"request_id": requestId,
+				"reason":     rejectedErr.Reason,
+			})
+
+		_ = o.db.UpdateRequestStatus(requestId, models.REQUEST_STATUS_PRICE_REJECTED, rejectedErr.Reason)
+		return
+	}
 
 	if err != nil {
 		logger.ErrorWithFields("chain", "processFulfillmentFetchData", "run api query",
@@ -176,6 +189,8 @@ func (o *OoORouterService) processFulfillmentFetchData(job models.DataRequests,
 		return
 	}
 
+	price := result.Price
+
 	if price == "" {
 		// no price returned
 		logger.ErrorWithFields("chain", "processFulfillmentFetchData", "api query result",
@@ -194,9 +209,22 @@ func (o *OoORouterService) processFulfillmentFetchData(job models.DataRequests,
 			"request_id": requestId,
 			"endpoint":   job.Endpoint,
 			"price":      price,
+			"source":     result.Source,
 		})
 
-	_ = o.db.UpdateDataFetched(requestId, price)
+	_ = o.db.UpdateDataFetched(requestId, price, result.Source)
+
+	for _, provenance := range result.Provenance {
+		err = o.db.InsertPriceProvenance(provenance)
+		if err != nil {
+			logger.ErrorWithFields("chain", "processFulfillmentFetchData", "insert price provenance",
+				err.Error(),
+				logger.Fields{
+					"request_id": requestId,
+					"source":     provenance.Source,
+				})
+		}
+	}
 
 	return
 }
@@ -227,10 +255,8 @@ func (o *OoORouterService) sendFulfillmentTx(job models.DataRequests, currentBlo
 		solsha3.Address(job.Consumer),
 	)
 
-	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n32%s", hash)
-	msgHash := crypto.Keccak256Hash([]byte(msg))
-
-	signatureBytes, err := crypto.Sign(msgHash.Bytes(), o.oraclePrivateKey)
+	// signs the "\x19Ethereum Signed Message:\n32" prefixed hash
+	signatureBytes, err := o.signer.SignText(hash)
 
 	if err != nil {
 		logger.ErrorWithFields("chain", "sendFulfillmentTx", "sign message",
@@ -246,6 +272,11 @@ func (o *OoORouterService) sendFulfillmentTx(job models.DataRequests, currentBlo
 	// grr - https://ethereum.stackexchange.com/questions/45580/validating-go-ethereum-key-signature-with-ecrecover
 	signatureBytes[64] = uint8(int(signatureBytes[64])) + 27
 
+	if o.dryRun {
+		o.recordShadowFulfilment(job, reqIdBytes32, priceBigInt, signatureBytes, currentBlockNum)
+		return
+	}
+
 	tx, err := o.contractInstance.FulfillRequest(o.transactOpts, reqIdBytes32, priceBigInt, signatureBytes)
 
 	if err != nil {
@@ -267,7 +298,7 @@ func (o *OoORouterService) sendFulfillmentTx(job models.DataRequests, currentBlo
 		})
 
 	_ = o.db.UpdateRequestStatus(requestId, models.REQUEST_STATUS_TX_SENT, "")
-	_ = o.db.UpdateFulfillmentSent(requestId, tx.Hash().Hex(), currentBlockNum)
+	_ = o.db.UpdateFulfillmentSent(requestId, tx.Hash().Hex(), tx.Nonce(), currentBlockNum)
 
 	o.setNextTxNonce(tx.Nonce(), false)
 
@@ -468,8 +499,11 @@ func (o *OoORouterService) processPossiblyStuckSentTx(job models.DataRequests, c
 
 	// Tx has failed - process
 	// used later to store failed fulfill tx history
-	failedGasUsed := job.GetFulfillGasUsed()
+	failedGasUsed := fulfillReceipt.GasUsed
 	failedGasPrice := job.GetFulfillGasPrice()
+	if fulfillReceipt.EffectiveGasPrice != nil {
+		failedGasPrice = fulfillReceipt.EffectiveGasPrice.Uint64()
+	}
 	failReason := "tx reverted" // todo - try to get revert reason from receipt
 
 	// Add fail info to failed Tx history table
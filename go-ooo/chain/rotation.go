@@ -0,0 +1,108 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FeeConfig is a provider's fee configuration on the Router
+type FeeConfig struct {
+	MinFee uint64
+	// granular fees which differ from the min fee, keyed by consumer contract address
+	GranularFees map[string]uint64
+}
+
+// GetFeeConfig reads the provider's min fee, and the granular fee for each of the consumers
+// which differs from the min fee, from the Router. A min fee of zero means the provider is not
+// registered
+func (o *OoORouterService) GetFeeConfig(consumers []string) (FeeConfig, error) {
+	feeConfig := FeeConfig{
+		GranularFees: make(map[string]uint64),
+	}
+
+	fees, err := o.GetProviderFees(consumers)
+	if err != nil {
+		return feeConfig, err
+	}
+	feeConfig.MinFee = fees.MinFee
+
+	for consumer, fee := range fees.GranularFees {
+		// the Router returns the min fee if no granular fee is set
+		if fee != fees.MinFee {
+			feeConfig.GranularFees[consumer] = fee
+		}
+	}
+
+	return feeConfig, nil
+}
+
+// GetProviderFees reads the provider's min fee, and the granular fee for each of the consumers,
+// from the Router. Every consumer is included, since the Router returns the min fee for
+// consumers without a granular fee
+func (o *OoORouterService) GetProviderFees(consumers []string) (go_ooo_types.ProviderFees, error) {
+	fees := go_ooo_types.ProviderFees{
+		GranularFees: make(map[string]uint64),
+	}
+
+	minFee, err := o.contractInstance.GetProviderMinFee(o.callOpts, o.oracleAddress)
+	if err != nil {
+		return fees, err
+	}
+	fees.MinFee = minFee.Uint64()
+
+	for _, consumer := range consumers {
+		address := common.HexToAddress(consumer)
+		fee, err := o.contractInstance.GetProviderGranularFee(o.callOpts, o.oracleAddress, address)
+		if err != nil {
+			return fees, err
+		}
+		fees.GranularFees[address.Hex()] = fee.Uint64()
+	}
+
+	return fees, nil
+}
+
+// GetWithdrawable returns the provider's withdrawable xFUND
+func (o *OoORouterService) GetWithdrawable() (uint64, error) {
+	available, err := o.contractInstance.GetWithdrawableTokens(o.callOpts, o.oracleAddress)
+	if err != nil {
+		return 0, err
+	}
+	return available.Uint64(), nil
+}
+
+// WaitForTx waits until the Tx has been confirmed, and returns an error if it failed or was not
+// confirmed before the timeout
+func (o *OoORouterService) WaitForTx(txHash string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		confirmed, success, blockNumber, _, err := o.GetTxOutcome(txHash)
+		if err != nil {
+			return err
+		}
+
+		if confirmed {
+			if !success {
+				return errors.New(fmt.Sprintf("tx %s failed in block %d", txHash, blockNumber))
+			}
+			logger.InfoWithFields("chain", "WaitForTx", "", "tx confirmed", logger.Fields{
+				"tx_hash": txHash,
+				"block":   blockNumber,
+			})
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return errors.New(fmt.Sprintf("tx %s not confirmed after %s", txHash, timeout))
+		}
+
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,18 @@
+package client
+
+// Erc20Abi is the subset of the ERC20 ABI used to approve and transfer xFUND
+const Erc20Abi = `[
+  {"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+  {"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+  {"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+  {"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+// DefaultConsumerAbi is the ABI of a consumer contract implementing the request function
+// getData(address,uint256,bytes32) and increaseRouterAllowance(uint256), as in the
+// MockConsumer contract
+const DefaultConsumerAbi = `[
+  {"type":"function","name":"getData","stateMutability":"nonpayable","inputs":[{"name":"_dataProvider","type":"address"},{"name":"_fee","type":"uint256"},{"name":"_data","type":"bytes32"}],"outputs":[]},
+  {"type":"function","name":"increaseRouterAllowance","stateMutability":"nonpayable","inputs":[{"name":"_amount","type":"uint256"}],"outputs":[]},
+  {"type":"function","name":"getRouterAddress","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}
+]`
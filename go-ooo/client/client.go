@@ -0,0 +1,342 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"go-ooo/ooo_router"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrTimeout is returned by WaitForFulfilment if the request is not fulfilled in time
+var ErrTimeout = errors.New("timed out waiting for request to be fulfilled")
+
+// DefaultPollInterval is how often WaitForFulfilment checks for the RequestFulfilled event
+const DefaultPollInterval = 2 * time.Second
+
+// Backend is the eth client used by the Client. Satisfied by *ethclient.Client
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+}
+
+// RequestFunc sends the consumer contract's data request Tx. Consumer contracts implement
+// their own request function, which calls ConsumerBase's _requestData
+type RequestFunc func(opts *bind.TransactOpts, provider common.Address, fee *big.Int, data [32]byte) (*types.Transaction, error)
+
+// Request is a data request initialised on the Router
+type Request struct {
+	RequestId   [32]byte
+	Consumer    common.Address
+	Provider    common.Address
+	Fee         *big.Int
+	Endpoint    string
+	TxHash      common.Hash
+	BlockNumber uint64
+}
+
+// Client requests data from OoO providers via a consumer contract deployed using ConsumerBase
+type Client struct {
+	backend         Backend
+	routerAddress   common.Address
+	router          *ooo_router.OooRouter
+	tokenAddress    common.Address
+	token           *bind.BoundContract
+	consumerAddress common.Address
+	consumer        *bind.BoundContract
+	pollInterval    time.Duration
+	fromBlock       uint64
+}
+
+// NewClient returns a Client for the consumer contract. consumerAbi is the JSON ABI of
+// the consumer contract. If empty, DefaultConsumerAbi is used
+func NewClient(ctx context.Context, backend Backend, routerAddress, consumerAddress common.Address,
+	consumerAbi string) (*Client, error) {
+
+	router, err := ooo_router.NewOooRouter(routerAddress, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenAddress, err := router.GetTokenAddress(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+
+	tokenAbi, err := abi.JSON(strings.NewReader(Erc20Abi))
+	if err != nil {
+		return nil, err
+	}
+
+	if consumerAbi == "" {
+		consumerAbi = DefaultConsumerAbi
+	}
+
+	parsedConsumerAbi, err := abi.JSON(strings.NewReader(consumerAbi))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		backend:         backend,
+		routerAddress:   routerAddress,
+		router:          router,
+		tokenAddress:    tokenAddress,
+		token:           bind.NewBoundContract(tokenAddress, tokenAbi, backend, backend, backend),
+		consumerAddress: consumerAddress,
+		consumer:        bind.NewBoundContract(consumerAddress, parsedConsumerAbi, backend, backend, backend),
+		pollInterval:    DefaultPollInterval,
+	}, nil
+}
+
+// SetPollInterval sets how often WaitForFulfilment checks for the RequestFulfilled event
+func (c *Client) SetPollInterval(interval time.Duration) {
+	c.pollInterval = interval
+}
+
+// SetFromBlock sets the first block searched for the consumer's DataRequested events when
+// calculating nonces. Usually the block the consumer contract was deployed in
+func (c *Client) SetFromBlock(block uint64) {
+	c.fromBlock = block
+}
+
+func (c *Client) TokenAddress() common.Address {
+	return c.tokenAddress
+}
+
+func (c *Client) RouterAddress() common.Address {
+	return c.routerAddress
+}
+
+func (c *Client) ConsumerAddress() common.Address {
+	return c.consumerAddress
+}
+
+// ConsumerMethod returns a RequestFunc which calls method(address,uint256,bytes32) on the
+// consumer contract, e.g. getData
+func (c *Client) ConsumerMethod(method string) RequestFunc {
+	return func(opts *bind.TransactOpts, provider common.Address, fee *big.Int, data [32]byte) (*types.Transaction, error) {
+		return c.consumer.Transact(opts, method, provider, fee, data)
+	}
+}
+
+/*
+  xFUND
+*/
+
+// Approve approves spender to transfer amount xFUND from the opts.From account
+func (c *Client) Approve(opts *bind.TransactOpts, spender common.Address, amount *big.Int) (*types.Transaction, error) {
+	return c.token.Transact(opts, "approve", spender, amount)
+}
+
+// FundConsumer transfers amount xFUND from the opts.From account to the consumer contract
+func (c *Client) FundConsumer(opts *bind.TransactOpts, amount *big.Int) (*types.Transaction, error) {
+	return c.token.Transact(opts, "transfer", c.consumerAddress, amount)
+}
+
+// IncreaseRouterAllowance calls the consumer contract's increaseRouterAllowance function,
+// allowing the Router to take amount more xFUND in fees from the consumer
+func (c *Client) IncreaseRouterAllowance(opts *bind.TransactOpts, amount *big.Int) (*types.Transaction, error) {
+	return c.consumer.Transact(opts, "increaseRouterAllowance", amount)
+}
+
+// ConsumerBalance returns the consumer contract's xFUND balance
+func (c *Client) ConsumerBalance(ctx context.Context) (*big.Int, error) {
+	return c.callToken(ctx, "balanceOf", c.consumerAddress)
+}
+
+// RouterAllowance returns the amount of xFUND the Router can take from the consumer contract
+func (c *Client) RouterAllowance(ctx context.Context) (*big.Int, error) {
+	return c.callToken(ctx, "allowance", c.consumerAddress, c.routerAddress)
+}
+
+func (c *Client) callToken(ctx context.Context, method string, params ...interface{}) (*big.Int, error) {
+	var out []interface{}
+	err := c.token.Call(&bind.CallOpts{Context: ctx}, &out, method, params...)
+	if err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+/*
+  Requests
+*/
+
+// ProviderFee returns the fee the provider charges the consumer contract
+func (c *Client) ProviderFee(ctx context.Context, provider common.Address) (*big.Int, error) {
+	return c.router.GetProviderGranularFee(&bind.CallOpts{Context: ctx}, provider, c.consumerAddress)
+}
+
+// Nonce returns the consumer's current request nonce for the provider, calculated from the
+// number of DataRequested events emitted since the SetFromBlock block
+func (c *Client) Nonce(ctx context.Context, provider common.Address) (*big.Int, error) {
+	it, err := c.router.FilterDataRequested(&bind.FilterOpts{Start: c.fromBlock, Context: ctx},
+		[]common.Address{c.consumerAddress}, []common.Address{provider}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer it.Close()
+
+	nonce := int64(0)
+	for it.Next() {
+		nonce++
+	}
+
+	return big.NewInt(nonce), it.Error()
+}
+
+// NextRequestId returns the request ID the Router will generate for the consumer's next
+// request to the provider
+func (c *Client) NextRequestId(ctx context.Context, provider common.Address, endpoint string) ([32]byte, error) {
+	data, err := EncodeEndpoint(endpoint)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	nonce, err := c.Nonce(ctx, provider)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	return RequestId(c.consumerAddress, provider, c.routerAddress, nonce, data), nil
+}
+
+// Request sends a data request for the endpoint to the provider using send, and waits for
+// the Tx to be mined. If fee is nil, the provider's fee for the consumer is paid. The
+// consumer contract must hold enough xFUND, and have approved the Router to take the fee
+func (c *Client) Request(ctx context.Context, opts *bind.TransactOpts, provider common.Address, fee *big.Int,
+	endpoint string, send RequestFunc) (*Request, error) {
+
+	data, err := EncodeEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	providerFee, err := c.ProviderFee(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	if providerFee.Sign() == 0 {
+		return nil, errors.New(fmt.Sprintf("provider %s not registered", provider.Hex()))
+	}
+
+	if fee == nil {
+		fee = providerFee
+	}
+
+	if fee.Cmp(providerFee) < 0 {
+		return nil, errors.New(fmt.Sprintf("fee %s is less than provider fee %s", fee.String(), providerFee.String()))
+	}
+
+	balance, err := c.ConsumerBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if balance.Cmp(fee) < 0 {
+		return nil, errors.New(fmt.Sprintf("consumer xFUND balance %s is less than fee %s", balance.String(), fee.String()))
+	}
+
+	allowance, err := c.RouterAllowance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowance.Cmp(fee) < 0 {
+		return nil, errors.New(fmt.Sprintf("router allowance %s is less than fee %s", allowance.String(), fee.String()))
+	}
+
+	tx, err := send(opts, provider, fee, data)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := bind.WaitMined(ctx, c.backend, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, errors.New(fmt.Sprintf("request tx %s reverted", tx.Hash().Hex()))
+	}
+
+	for _, l := range receipt.Logs {
+		if l.Address != c.routerAddress {
+			continue
+		}
+
+		ev, err := c.router.ParseDataRequested(*l)
+		if err != nil || ev.Consumer != c.consumerAddress || ev.Provider != provider {
+			continue
+		}
+
+		return &Request{
+			RequestId:   ev.RequestId,
+			Consumer:    ev.Consumer,
+			Provider:    ev.Provider,
+			Fee:         ev.Fee,
+			Endpoint:    DecodeEndpoint(ev.Data),
+			TxHash:      tx.Hash(),
+			BlockNumber: receipt.BlockNumber.Uint64(),
+		}, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("no DataRequested event in tx %s", tx.Hash().Hex()))
+}
+
+// WaitForFulfilment waits for the RequestFulfilled event for the request, searching from the
+// block the request was made in. Returns ErrTimeout if the request is not fulfilled in time
+func (c *Client) WaitForFulfilment(ctx context.Context, req *Request, timeout time.Duration) (*ooo_router.OooRouterRequestFulfilled, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		ev, err := c.findFulfilment(ctx, req)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ev != nil {
+			return ev, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, ErrTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) findFulfilment(ctx context.Context, req *Request) (*ooo_router.OooRouterRequestFulfilled, error) {
+	it, err := c.router.FilterRequestFulfilled(&bind.FilterOpts{Start: req.BlockNumber, Context: ctx},
+		[]common.Address{req.Consumer}, []common.Address{req.Provider}, [][32]byte{req.RequestId})
+	if err != nil {
+		return nil, err
+	}
+
+	defer it.Close()
+
+	if it.Next() {
+		return it.Event, nil
+	}
+
+	return nil, it.Error()
+}
@@ -0,0 +1,213 @@
+package client_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ooo/client"
+	"go-ooo/ooo_router"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	solsha3 "github.com/miguelmota/go-solidity-sha3"
+	"github.com/stretchr/testify/require"
+)
+
+// contracts compiled by the smart-contracts truffle project
+const bindingsPath = "../../smart-contracts/abigenBindings"
+
+const (
+	simChainId = 1337
+	testFee    = 100000000 // 0.1 xFUND
+)
+
+type testEnv struct {
+	t        *testing.T
+	ctx      context.Context
+	backend  *simulated.Backend
+	ownerKey *ecdsa.PrivateKey
+	provKey  *ecdsa.PrivateKey
+	provider common.Address
+	router   *ooo_router.OooRouter
+	client   *client.Client
+}
+
+func transactor(t *testing.T, key *ecdsa.PrivateKey) *bind.TransactOpts {
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(simChainId))
+	require.NoError(t, err)
+	return opts
+}
+
+func deploy(t *testing.T, backend *simulated.Backend, key *ecdsa.PrivateKey, name string, params ...interface{}) common.Address {
+	abiJson, err := os.ReadFile(filepath.Join(bindingsPath, "abi", name+".abi"))
+	require.NoError(t, err)
+	bin, err := os.ReadFile(filepath.Join(bindingsPath, "bin", name+".bin"))
+	require.NoError(t, err)
+	parsed, err := abi.JSON(strings.NewReader(string(abiJson)))
+	require.NoError(t, err)
+
+	address, _, _, err := bind.DeployContract(transactor(t, key), parsed, common.FromHex(strings.TrimSpace(string(bin))), backend.Client(), params...)
+	require.NoError(t, err, name)
+	backend.Commit()
+
+	return address
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	ownerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	provKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	eth := new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(ownerKey.PublicKey): {Balance: eth},
+		crypto.PubkeyToAddress(provKey.PublicKey):  {Balance: eth},
+	})
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+
+	supply := new(big.Int).Mul(big.NewInt(1000000), big.NewInt(1e9))
+	tokenAddress := deploy(t, backend, ownerKey, "MockToken", "xFUND", "xFUND", supply, uint8(9))
+	routerAddress := deploy(t, backend, ownerKey, "Router", tokenAddress)
+	consumerAddress := deploy(t, backend, ownerKey, "MockConsumer", routerAddress, tokenAddress)
+
+	router, err := ooo_router.NewOooRouter(routerAddress, backend.Client())
+	require.NoError(t, err)
+
+	_, err = router.RegisterAsProvider(transactor(t, provKey), big.NewInt(testFee))
+	require.NoError(t, err)
+	backend.Commit()
+
+	ctx := context.Background()
+	c, err := client.NewClient(ctx, backend.Client(), routerAddress, consumerAddress, "")
+	require.NoError(t, err)
+	c.SetPollInterval(10 * time.Millisecond)
+
+	require.Equal(t, tokenAddress, c.TokenAddress())
+
+	return &testEnv{
+		t:        t,
+		ctx:      ctx,
+		backend:  backend,
+		ownerKey: ownerKey,
+		provKey:  provKey,
+		provider: crypto.PubkeyToAddress(provKey.PublicKey),
+		router:   router,
+		client:   c,
+	}
+}
+
+// fundConsumer sends xFUND to the consumer, and approves the Router to take fees
+func (e *testEnv) fundConsumer(amount *big.Int) {
+	_, err := e.client.FundConsumer(transactor(e.t, e.ownerKey), amount)
+	require.NoError(e.t, err)
+	_, err = e.client.IncreaseRouterAllowance(transactor(e.t, e.ownerKey), amount)
+	require.NoError(e.t, err)
+	e.backend.Commit()
+}
+
+// send calls the MockConsumer's getData function, and mines the Tx
+func (e *testEnv) send(opts *bind.TransactOpts, provider common.Address, fee *big.Int, data [32]byte) (*types.Transaction, error) {
+	tx, err := e.client.ConsumerMethod("getData")(opts, provider, fee, data)
+	e.backend.Commit()
+	return tx, err
+}
+
+// fulfil sends the provider's fulfilment Tx for the request
+func (e *testEnv) fulfil(req *client.Request, price *big.Int) {
+	hash := solsha3.SoliditySHA3(
+		solsha3.Bytes32(req.RequestId),
+		solsha3.Uint256(price),
+		solsha3.Address(req.Consumer),
+	)
+	msgHash := crypto.Keccak256Hash([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n32%s", hash)))
+	sig, err := crypto.Sign(msgHash.Bytes(), e.provKey)
+	require.NoError(e.t, err)
+	sig[64] += 27
+
+	opts := transactor(e.t, e.provKey)
+	opts.GasLimit = 500000
+	tx, err := e.router.FulfillRequest(opts, req.RequestId, price, sig)
+	require.NoError(e.t, err)
+	e.backend.Commit()
+
+	receipt, err := e.backend.Client().TransactionReceipt(e.ctx, tx.Hash())
+	require.NoError(e.t, err)
+	require.Equal(e.t, types.ReceiptStatusSuccessful, receipt.Status)
+}
+
+func TestEncodeEndpoint(t *testing.T) {
+	data, err := client.EncodeEndpoint("BTC.GBP.PR.AVC.24H")
+	require.NoError(t, err)
+	require.Equal(t, "BTC.GBP.PR.AVC.24H", client.DecodeEndpoint(data))
+	require.Equal(t, byte(0), data[31])
+
+	_, err = client.EncodeEndpoint("")
+	require.Error(t, err)
+
+	_, err = client.EncodeEndpoint(strings.Repeat("A", 33))
+	require.Error(t, err)
+}
+
+func TestRequestAndWait(t *testing.T) {
+	e := newTestEnv(t)
+	e.fundConsumer(big.NewInt(testFee * 10))
+
+	for i := 0; i < 2; i++ {
+		expectedId, err := e.client.NextRequestId(e.ctx, e.provider, "ETH.USD.PR.AVC.24H")
+		require.NoError(t, err)
+
+		req, err := e.client.Request(e.ctx, transactor(t, e.ownerKey), e.provider, nil, "ETH.USD.PR.AVC.24H", e.send)
+		require.NoError(t, err)
+		require.Equal(t, expectedId, req.RequestId)
+		require.Equal(t, "ETH.USD.PR.AVC.24H", req.Endpoint)
+		require.Equal(t, big.NewInt(testFee), req.Fee)
+
+		price := big.NewInt(int64(1000 + i))
+		e.fulfil(req, price)
+
+		ev, err := e.client.WaitForFulfilment(e.ctx, req, time.Second)
+		require.NoError(t, err)
+		require.Equal(t, price, ev.RequestedData)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	e := newTestEnv(t)
+	e.fundConsumer(big.NewInt(testFee))
+
+	req, err := e.client.Request(e.ctx, transactor(t, e.ownerKey), e.provider, nil, "ETH.USD.PR.AVC.24H", e.send)
+	require.NoError(t, err)
+
+	_, err = e.client.WaitForFulfilment(e.ctx, req, 100*time.Millisecond)
+	require.ErrorIs(t, err, client.ErrTimeout)
+}
+
+func TestRequestChecks(t *testing.T) {
+	e := newTestEnv(t)
+
+	// no xFUND
+	_, err := e.client.Request(e.ctx, transactor(t, e.ownerKey), e.provider, nil, "ETH.USD.PR.AVC.24H", e.send)
+	require.ErrorContains(t, err, "consumer xFUND balance")
+
+	e.fundConsumer(big.NewInt(testFee))
+
+	_, err = e.client.Request(e.ctx, transactor(t, e.ownerKey), e.provider, big.NewInt(testFee-1), "ETH.USD.PR.AVC.24H", e.send)
+	require.ErrorContains(t, err, "less than provider fee")
+
+	_, err = e.client.Request(e.ctx, transactor(t, e.ownerKey), common.HexToAddress("0x01"), nil, "ETH.USD.PR.AVC.24H", e.send)
+	require.ErrorContains(t, err, "not registered")
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EncodeEndpoint encodes a data endpoint, e.g. BTC.GBP.PR.AVC.24H, into the bytes32 sent
+// with a data request. The endpoint is right padded with zeroes
+func EncodeEndpoint(endpoint string) ([32]byte, error) {
+	var data [32]byte
+
+	if len(endpoint) == 0 {
+		return data, errors.New("endpoint is empty")
+	}
+
+	if len(endpoint) > 32 {
+		return data, errors.New(fmt.Sprintf("endpoint %s is %d bytes, max 32", endpoint, len(endpoint)))
+	}
+
+	copy(data[:], endpoint)
+
+	return data, nil
+}
+
+// DecodeEndpoint decodes the bytes32 data from a request back into the endpoint string
+func DecodeEndpoint(data [32]byte) string {
+	return string(common.TrimRightZeroes(data[:]))
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	solsha3 "github.com/miguelmota/go-solidity-sha3"
+)
+
+// RequestId generates a request ID in the same way as RequestIdBase.sol's makeRequestId:
+// keccak256(abi.encodePacked(consumer, provider, router, nonce, data))
+func RequestId(consumer, provider, router common.Address, nonce *big.Int, data [32]byte) [32]byte {
+	hash := solsha3.SoliditySHA3(
+		solsha3.Address(consumer),
+		solsha3.Address(provider),
+		solsha3.Address(router),
+		solsha3.Uint256(nonce),
+		solsha3.Bytes32(data),
+	)
+
+	var requestId [32]byte
+	copy(requestId[:], hash)
+
+	return requestId
+}
@@ -3,11 +3,13 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go-ooo/config"
 	go_ooo_types "go-ooo/types"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 	"syscall"
 
@@ -30,50 +32,24 @@ func init() {
 
 func processAdminTask(adminTask go_ooo_types.AdminTask, cfg *config.Config) {
 
-	fmt.Print("Enter your password:	")
-
-	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+	pass, err := readApiPassword()
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	pass := strings.TrimSpace(string(bytePassword))
-
 	fmt.Println("")
 	fmt.Println("attempting to send task", adminTask.Task)
 	fmt.Println("")
 
-	requestJSON, err := json.Marshal(adminTask)
-	if err != nil {
-		fmt.Println("Can't marshal request")
-		return
-	}
-	request := bytes.NewBuffer(requestJSON)
-	url := fmt.Sprintf("http://%s:%s", cfg.Serve.Host, cfg.Serve.Port)
-
-	req, err := http.NewRequest("POST", fmt.Sprint(url, "/admin"), request)
-
-	bearer := "Bearer " + pass
-	req.Header.Add("Authorization", bearer)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-
+	statusCode, status, body, err := postAdminTask(adminTask, cfg, pass)
 	if err != nil {
 		fmt.Println("Something went wrong.")
 		fmt.Println(err.Error())
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		fmt.Println(err.Error())
 		return
 	}
 
-	if resp.StatusCode == 200 {
+	if statusCode == 200 {
 
 		var decodedResponse go_ooo_types.AdminTaskResponse
 		err = json.Unmarshal(body, &decodedResponse)
@@ -90,9 +66,75 @@ func processAdminTask(adminTask go_ooo_types.AdminTask, cfg *config.Config) {
 			fmt.Println("Error   :", decodedResponse.Error)
 		}
 	} else {
-		fmt.Println("Error   :", resp.Status)
+		fmt.Println("Error   :", status)
 		fmt.Println("Message :", string(body))
 	}
 
 	return
 }
+
+// readApiPassword prompts for the decryption password or API token used to authenticate
+// with the HTTP API
+func readApiPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Enter your password:	")
+
+	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(bytePassword)), nil
+}
+
+// postAdminTask sends the task to the admin endpoint, and returns the response status and body
+func postAdminTask(adminTask go_ooo_types.AdminTask, cfg *config.Config, pass string) (int, string, []byte, error) {
+	requestJSON, err := json.Marshal(adminTask)
+	if err != nil {
+		return 0, "", nil, errors.New("Can't marshal request")
+	}
+	request := bytes.NewBuffer(requestJSON)
+	url := apiBaseUrl(cfg)
+
+	req, err := http.NewRequest("POST", fmt.Sprint(url, "/admin"), request)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	bearer := "Bearer " + pass
+	req.Header.Add("Authorization", bearer)
+
+	client, err := apiHttpClient()
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	return resp.StatusCode, resp.Status, body, nil
+}
+
+// sendAdminTask sends the task to the admin endpoint, and returns the decoded response. An
+// error is returned if the task could not be sent or was rejected by the API
+func sendAdminTask(adminTask go_ooo_types.AdminTask, cfg *config.Config, pass string) (go_ooo_types.AdminTaskResponse, error) {
+	var decodedResponse go_ooo_types.AdminTaskResponse
+
+	statusCode, status, body, err := postAdminTask(adminTask, cfg, pass)
+	if err != nil {
+		return decodedResponse, err
+	}
+	if statusCode != 200 {
+		return decodedResponse, errors.New(fmt.Sprintf("%s: %s", status, strings.TrimSpace(string(body))))
+	}
+
+	err = json.Unmarshal(body, &decodedResponse)
+	return decodedResponse, err
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"go-ooo/server"
+	go_ooo_types "go-ooo/types"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// adminRequestCmd represents the admin request command
+var adminRequestCmd = &cobra.Command{
+	Use:   "request",
+	Short: "Manually intervene in the processing of individual requests",
+	Long: `Manually intervene in the processing of individual requests, for example those
+which have ended with the FULFILMENT FAILED status. Each action is recorded in the audit log.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("run one of the sub-commands. See 'go-ooo admin request --help'")
+	},
+}
+
+// adminRequestRetryCmd represents the admin request retry command
+var adminRequestRetryCmd = &cobra.Command{
+	Use:   "retry <request_id>",
+	Short: "Retry a request",
+	Long: `Put a request back into the job queue, and reset its fulfilment attempts. If a
+price has already been fetched, the fulfilment Tx is re-sent using that price. Otherwise,
+the price is fetched. The request must still be pending on the Router.
+
+If a fulfilment Tx has already been sent, the request is only retried once the Tx is known
+to have been dropped: it has not been mined and its nonce has been used by another Tx, or
+the node has not seen it for 50 blocks.
+
+Examples:
+
+  go-ooo admin request retry 0x1234abcd...`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "retry_request"
+		adminTask.RequestId = args[0]
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+// adminRequestRefetchCmd represents the admin request refetch command
+var adminRequestRefetchCmd = &cobra.Command{
+	Use:   "refetch <request_id>",
+	Short: "Discard a request's price and fetch it again",
+	Long: `Discard any price previously fetched for a request, and put it back into the job
+queue so that the price is fetched again. The request must still be pending on the Router,
+and any fulfilment Tx already sent must have been dropped, as for retry.
+
+Examples:
+
+  go-ooo admin request refetch 0x1234abcd...`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "refetch_request"
+		adminTask.RequestId = args[0]
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+// adminRequestAbandonCmd represents the admin request abandon command
+var adminRequestAbandonCmd = &cobra.Command{
+	Use:   "abandon <request_id> <reason>",
+	Short: "Abandon a request",
+	Long: `Mark a request as abandoned, removing it from the job queue. No further attempts
+will be made to fulfil it.
+
+Examples:
+
+  go-ooo admin request abandon 0x1234abcd... "pair delisted"`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "abandon_request"
+		adminTask.RequestId = args[0]
+		adminTask.Reason = strings.Join(args[1:], " ")
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+// adminRequestFulfilCmd represents the admin request fulfil command
+var adminRequestFulfilCmd = &cobra.Command{
+	Use:   "fulfil <request_id> <price>",
+	Short: "Fulfil a request with an operator supplied price",
+	Long: `Send a fulfilment Tx for a request using the given price, after checking that the
+request is still pending on the Router, and that any fulfilment Tx already sent has been
+dropped, as for retry. The price must be the integer value sent to the consumer, i.e.
+multiplied by 10^18.
+
+Examples:
+
+  go-ooo admin request fulfil 0x1234abcd... 3001450000000000000000`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "fulfil_request"
+		adminTask.RequestId = args[0]
+		adminTask.Price = args[1]
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+func init() {
+	adminRequestCmd.AddCommand(adminRequestRetryCmd)
+	adminRequestCmd.AddCommand(adminRequestRefetchCmd)
+	adminRequestCmd.AddCommand(adminRequestAbandonCmd)
+	adminRequestCmd.AddCommand(adminRequestFulfilCmd)
+	adminCmd.AddCommand(adminRequestCmd)
+}
@@ -3,18 +3,20 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/spf13/cobra"
 	"go-ooo/config"
 	"go-ooo/server"
+	"go-ooo/service"
 	go_ooo_types "go-ooo/types"
-	"golang.org/x/term"
 	"io/ioutil"
 	"math/big"
 	"net/http"
+	"os"
 	"strings"
-	"syscall"
+	"time"
 )
 
 var (
@@ -23,6 +25,12 @@ var (
 	aCurrXfundPrice float64
 	aSimGasPrice    uint64
 	aSimXfundFee    float64
+	aPeriod         string
+	aByConsumer     bool
+	aFrom           string
+	aTo             string
+	aFormat         string
+	aOutput         string
 )
 
 // analyticsCmd represents the analytics command
@@ -40,14 +48,6 @@ var analyticsCmd = &cobra.Command{
 			isSim = true
 		}
 
-		currXfundPrice := float64(0)
-		if aCurrXfundPrice > 0 {
-			currXfundPrice = aCurrXfundPrice
-		} else {
-			prices := getXfundPrice()
-			currXfundPrice = prices.Xfund.Eth
-		}
-
 		simXfundFee := float64(0)
 		if aSimXfundFee > 0 {
 			simXfundFee = aSimXfundFee
@@ -56,7 +56,7 @@ var analyticsCmd = &cobra.Command{
 		analyticsTask := go_ooo_types.AnalyticsTask{
 			Consumer:       aConsumer,
 			NumTxs:         aNumTxs,
-			CurrXfundPrice: currXfundPrice,
+			CurrXfundPrice: aCurrXfundPrice,
 			Simulate:       isSim,
 			SuggestFee:     false,
 			SimulationParams: go_ooo_types.AnalyticsSimulationParams{
@@ -80,17 +80,9 @@ var suggestFeeCmd = &cobra.Command{
 			aNumTxs = 100
 		}
 
-		currXfundPrice := float64(0)
-		if aCurrXfundPrice > 0 {
-			currXfundPrice = aCurrXfundPrice
-		} else {
-			prices := getXfundPrice()
-			currXfundPrice = prices.Xfund.Eth
-		}
-
 		analyticsTask := go_ooo_types.AnalyticsTask{
 			NumTxs:         aNumTxs,
-			CurrXfundPrice: currXfundPrice,
+			CurrXfundPrice: aCurrXfundPrice,
 			Simulate:       false,
 			SuggestFee:     true,
 		}
@@ -101,64 +93,139 @@ var suggestFeeCmd = &cobra.Command{
 	},
 }
 
+// reportCmd represents the analytics report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Profitability report by day, week or month",
+	Long: `Report the number of requests fulfilled, fees earned, gas spent, failed fulfilment Txs
+and their cost, and net profit for each day, week or month, and optionally each consumer.
+
+Gas costs and net profit are in the chain's native currency. Fees are converted at the xFUND
+price when each request was fulfilled, unless --xfund-price is set. Periods are in UTC, and
+weeks are ISO weeks starting on Monday.
+
+--from and --to are dates in the format YYYY-MM-DD, and are inclusive. The default is the last
+30 days, 12 weeks or 12 months, up to now.
+
+Examples:
+
+  go-ooo analytics report --period week
+  go-ooo analytics report --period month --by-consumer --format csv --output report.csv
+  go-ooo analytics report --from 2024-01-01 --to 2024-01-31 --consumer 0x12345abcde...`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+
+		analyticsTask := go_ooo_types.AnalyticsTask{
+			Consumer:       aConsumer,
+			CurrXfundPrice: aCurrXfundPrice,
+			Report:         aPeriod,
+			ByConsumer:     aByConsumer,
+			Format:         aFormat,
+		}
+
+		if aFormat != service.ReportFormatJson && aFormat != service.ReportFormatCsv {
+			return errors.New(fmt.Sprintf("invalid format %s. Must be json or csv", aFormat))
+		}
+
+		if aFrom != "" {
+			from, err := time.Parse("2006-01-02", aFrom)
+			if err != nil {
+				return errors.New(fmt.Sprintf("invalid --from date %s. Must be YYYY-MM-DD", aFrom))
+			}
+			analyticsTask.From = from
+		}
+		if aTo != "" {
+			to, err := time.Parse("2006-01-02", aTo)
+			if err != nil {
+				return errors.New(fmt.Sprintf("invalid --to date %s. Must be YYYY-MM-DD", aTo))
+			}
+			// include the whole of the last day
+			analyticsTask.To = to.AddDate(0, 0, 1)
+		}
+
+		pass, err := readApiPassword()
+		fmt.Fprintln(os.Stderr, "")
+		if err != nil {
+			return err
+		}
+
+		statusCode, status, body, err := postAnalyticsTask(analyticsTask, cfg, pass)
+		if err != nil {
+			return err
+		}
+		if statusCode != 200 {
+			return errors.New(fmt.Sprintf("%s: %s", status, strings.TrimSpace(string(body))))
+		}
+
+		out := body
+		if aFormat == service.ReportFormatJson {
+			var decodedResponse go_ooo_types.AnalyticsTaskResponse
+			if err = json.Unmarshal(body, &decodedResponse); err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if err = writeJson(&buf, decodedResponse.Result.Report); err != nil {
+				return err
+			}
+			out = buf.Bytes()
+		}
+
+		if aOutput == "" {
+			fmt.Print(string(out))
+			return nil
+		}
+
+		if err = os.WriteFile(aOutput, out, 0644); err != nil {
+			return err
+		}
+		fmt.Println("report saved to:")
+		fmt.Println(aOutput)
+
+		return nil
+	},
+}
+
 func init() {
 
 	analyticsCmd.PersistentFlags().IntVar(&aNumTxs, "num-txs", 100, "number of Txs to analyse")
-	analyticsCmd.PersistentFlags().Float64Var(&aCurrXfundPrice, "xfund-price", 0.0, "Current xFUND price in ETH")
+	analyticsCmd.PersistentFlags().Float64Var(&aCurrXfundPrice, "xfund-price", 0.0, "xFUND price in ETH. Default is the node's xFUND price, at the time of each fulfilment")
 	analyticsCmd.PersistentFlags().StringVar(&aConsumer, "consumer", "", "filter by consumer contract address")
 	analyticsCmd.Flags().Uint64Var(&aSimGasPrice, "sim-gas-price", 0, "simulated gas prices in gwei")
 	analyticsCmd.Flags().Float64Var(&aSimXfundFee, "sim-xfund-fee", 0.0, "simulated xFUND fee")
 
+	reportCmd.Flags().StringVar(&aPeriod, "period", service.ReportPeriodDay, "report period. day, week or month")
+	reportCmd.Flags().BoolVar(&aByConsumer, "by-consumer", false, "report on each consumer separately within each period")
+	reportCmd.Flags().StringVar(&aFrom, "from", "", "first date to report on, YYYY-MM-DD")
+	reportCmd.Flags().StringVar(&aTo, "to", "", "last date to report on, YYYY-MM-DD. Default today")
+	reportCmd.Flags().StringVar(&aFormat, "format", service.ReportFormatJson, "output format. json or csv")
+	reportCmd.Flags().StringVarP(&aOutput, "output", "o", "", "file to write to. Default stdout")
+
 	analyticsCmd.AddCommand(suggestFeeCmd)
+	analyticsCmd.AddCommand(reportCmd)
 
 	rootCmd.AddCommand(analyticsCmd)
 }
 
 func processAnalyticsTask(task go_ooo_types.AnalyticsTask, cfg *config.Config) {
-	fmt.Print("Enter your password:	")
-
-	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+	pass, err := readApiPassword()
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
 
-	pass := strings.TrimSpace(string(bytePassword))
-
 	fmt.Println("")
 	fmt.Println("attempting to send analytics task")
 	fmt.Println("")
 
-	requestJSON, err := json.Marshal(task)
-	if err != nil {
-		fmt.Println("Can't marshal request")
-		return
-	}
-	request := bytes.NewBuffer(requestJSON)
-	url := fmt.Sprintf("http://%s:%s", cfg.Serve.Host, cfg.Serve.Port)
-
-	req, err := http.NewRequest("POST", fmt.Sprint(url, "/analytics"), request)
-
-	bearer := "Bearer " + pass
-	req.Header.Add("Authorization", bearer)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-
+	statusCode, status, body, err := postAnalyticsTask(task, cfg, pass)
 	if err != nil {
 		fmt.Println("Something went wrong.")
 		fmt.Println(err.Error())
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		fmt.Println(err.Error())
 		return
 	}
 
-	if resp.StatusCode == 200 {
+	if statusCode == 200 {
 		var decodedResponse go_ooo_types.AnalyticsTaskResponse
 		err = json.Unmarshal(body, &decodedResponse)
 		if err != nil {
@@ -181,40 +248,43 @@ func processAnalyticsTask(task go_ooo_types.AnalyticsTask, cfg *config.Config) {
 			fmt.Println(string(prettyJSON.Bytes()))
 		}
 	} else {
-		fmt.Println("Error   :", resp.Status)
+		fmt.Println("Error   :", status)
 		fmt.Println("Body :", string(body))
 	}
 }
 
-func getXfundPrice() *go_ooo_types.CoinGeckoResponse {
-	client := &http.Client{}
-	cgUrl := "https://api.coingecko.com/api/v3/simple/price?ids=xfund&vs_currencies=eth%2Cusd"
-	req, err := http.NewRequest("GET", cgUrl, nil)
+// postAnalyticsTask sends the task to the analytics endpoint, and returns the response status and body
+func postAnalyticsTask(task go_ooo_types.AnalyticsTask, cfg *config.Config, pass string) (int, string, []byte, error) {
+	requestJSON, err := json.Marshal(task)
 	if err != nil {
-		fmt.Println(err)
-		return &go_ooo_types.CoinGeckoResponse{}
+		return 0, "", nil, errors.New("Can't marshal request")
 	}
-	res, err := client.Do(req)
+	request := bytes.NewBuffer(requestJSON)
+	url := apiBaseUrl(cfg)
+
+	req, err := http.NewRequest("POST", fmt.Sprint(url, "/analytics"), request)
 	if err != nil {
-		fmt.Println(err)
-		return &go_ooo_types.CoinGeckoResponse{}
+		return 0, "", nil, err
 	}
 
-	if res.Body != nil {
-		defer res.Body.Close()
-	}
+	bearer := "Bearer " + pass
+	req.Header.Add("Authorization", bearer)
 
-	body, readErr := ioutil.ReadAll(res.Body)
-	if readErr != nil {
-		fmt.Println(readErr)
-		return &go_ooo_types.CoinGeckoResponse{}
+	client, err := apiHttpClient()
+	if err != nil {
+		return 0, "", nil, err
 	}
 
-	cgResp := &go_ooo_types.CoinGeckoResponse{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer resp.Body.Close()
 
-	jsonErr := json.Unmarshal(body, cgResp)
-	if jsonErr != nil {
-		fmt.Println(jsonErr)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", nil, err
 	}
-	return cgResp
+
+	return resp.StatusCode, resp.Status, body, nil
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-ooo/config"
+	"go-ooo/utils/tlsconfig"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	apiNode       string
+	apiCaCert     string
+	apiClientCert string
+	apiClientKey  string
+)
+
+func init() {
+	for _, c := range []*cobra.Command{adminCmd, analyticsCmd, feesCmd, queryCmd, requestsCmd} {
+		c.PersistentFlags().StringVar(&apiNode, "node", "", "URL of the node's HTTP API, e.g. https://10.0.0.1:8445. Default serve.host and serve.port in config")
+		c.PersistentFlags().StringVar(&apiCaCert, "ca-cert", "", "PEM encoded CA bundle used to verify the node's TLS certificate")
+		c.PersistentFlags().StringVar(&apiClientCert, "client-cert", "", "PEM encoded client certificate, if the node requires client certificates")
+		c.PersistentFlags().StringVar(&apiClientKey, "client-key", "", "PEM encoded client certificate key")
+	}
+}
+
+// apiBaseUrl returns the base URL of the node's HTTP API. HTTPS is used if --node is not
+// set and either the node is configured to serve over TLS, or a CA or client certificate
+// has been given
+func apiBaseUrl(cfg *config.Config) string {
+	if apiNode != "" {
+		return strings.TrimSuffix(apiNode, "/")
+	}
+
+	scheme := "http"
+	if cfg.Serve.TlsCert != "" || apiCaCert != "" || apiClientCert != "" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s:%s", scheme, cfg.Serve.Host, cfg.Serve.Port)
+}
+
+// apiHttpClient returns the HTTP client used to send requests to the node's HTTP API
+func apiHttpClient() (*http.Client, error) {
+	tlsConfig, err := tlsconfig.ClientConfig(apiCaCert, apiClientCert, apiClientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-ooo/apitoken"
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	"go-ooo/server"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	atScopes  string
+	atExpires string
+)
+
+// apiTokensCmd represents the api-tokens command
+var apiTokensCmd = &cobra.Command{
+	Use:   "api-tokens",
+	Short: "Manage named API tokens used to access the service's HTTP API",
+	Long: `Manage named API tokens used to access the service's HTTP API. Each token is
+granted one or more scopes:
+
+  read       status endpoints and read-only admin tasks, e.g. query_fees
+  analytics  analytics endpoint
+  fees       set global and granular fees
+  withdraw   withdraw fees
+  admin      all other admin tasks, e.g. register, token approval and request intervention
+
+Tokens are stored hashed, and are only displayed when created. The service's decryption
+password is granted all scopes.
+
+The service does not need to be running, but the database must be accessible.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("run one of the sub-commands. See 'go-ooo api-tokens --help'")
+	},
+}
+
+// apiTokensCreateCmd represents the api-tokens create command
+var apiTokensCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new API token",
+	Long: `Create a new named API token with the given scopes. The token is only displayed
+once, and must be stored securely.
+
+--expires accepts a duration, e.g. 720h or 30d. By default, tokens do not expire.
+
+Examples:
+
+  go-ooo api-tokens create grafana --scopes read,analytics --expires 90d
+  go-ooo api-tokens create treasury --scopes withdraw`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.TrimSpace(args[0])
+		if name == "" || name == "default" {
+			return errors.New(fmt.Sprintf("invalid token name '%s'", name))
+		}
+
+		scopes, err := apitoken.ParseScopes(atScopes)
+		if err != nil {
+			return err
+		}
+
+		var expiresAt *time.Time
+		if atExpires != "" {
+			d, err := parseExpiry(atExpires)
+			if err != nil {
+				return err
+			}
+			t := time.Now().Add(d)
+			expiresAt = &t
+		}
+
+		db, err := apiTokensDb(cmd)
+		if err != nil {
+			return err
+		}
+
+		if _, err = db.FindApiTokenByName(name); err == nil {
+			return errors.New(fmt.Sprintf("token %s already exists", name))
+		}
+
+		token, hash, err := apitoken.Generate()
+		if err != nil {
+			return err
+		}
+
+		err = db.InsertApiToken(name, hash, scopes, expiresAt)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Name    :", name)
+		fmt.Println("Scopes  :", strings.Join(scopes, ","))
+		if expiresAt != nil {
+			fmt.Println("Expires :", expiresAt.UTC().Format(time.RFC3339))
+		} else {
+			fmt.Println("Expires : never")
+		}
+		fmt.Println("Token   :", token)
+		fmt.Println("")
+		fmt.Println("Store the token securely. It will not be displayed again.")
+
+		return nil
+	},
+}
+
+// apiTokensListCmd represents the api-tokens list command
+var apiTokensListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API tokens",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := apiTokensDb(cmd)
+		if err != nil {
+			return err
+		}
+
+		tokens, err := db.GetApiTokens()
+		if err != nil {
+			return err
+		}
+
+		if len(tokens) == 0 {
+			fmt.Println("no API tokens found")
+			return nil
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSCOPES\tSTATUS\tCREATED\tEXPIRES\tLAST USED")
+		for _, t := range tokens {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.GetName(), strings.Join(t.GetScopes(), ","),
+				t.GetStatusString(now), t.CreatedAt.UTC().Format(time.RFC3339), formatOptionalTime(t.ExpiresAt, "never"),
+				formatOptionalTime(t.LastUsedAt, "-"))
+		}
+		return w.Flush()
+	},
+}
+
+// apiTokensRevokeCmd represents the api-tokens revoke command
+var apiTokensRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke an API token",
+	Long: `Revoke an API token. The token can no longer be used to access the HTTP API.
+
+Examples:
+
+  go-ooo api-tokens revoke grafana`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := apiTokensDb(cmd)
+		if err != nil {
+			return err
+		}
+
+		if _, err = db.FindApiTokenByName(args[0]); err != nil {
+			return errors.New(fmt.Sprintf("token %s not found", args[0]))
+		}
+
+		err = db.RevokeApiToken(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("revoked token", args[0])
+		return nil
+	},
+}
+
+func init() {
+	apiTokensCreateCmd.Flags().StringVar(&atScopes, "scopes", apitoken.ScopeRead, "comma separated list of scopes")
+	apiTokensCreateCmd.Flags().StringVar(&atExpires, "expires", "", "time until the token expires, e.g. 720h or 30d")
+
+	apiTokensCmd.AddCommand(apiTokensCreateCmd)
+	apiTokensCmd.AddCommand(apiTokensListCmd)
+	apiTokensCmd.AddCommand(apiTokensRevokeCmd)
+	rootCmd.AddCommand(apiTokensCmd)
+}
+
+func apiTokensDb(cmd *cobra.Command) (*database.DB, error) {
+	cfg := server.GetServerContextFromCmd(cmd).Config
+	logger.SetLogLevel(cfg.Log.Level)
+
+	db, err := database.NewDb(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// ensure the api_tokens table exists if the service has not been run since upgrading
+	return db, db.AutoMigrate(&models.ApiTokens{})
+}
+
+// parseExpiry parses a Go duration, or a number of days, e.g. 30d
+func parseExpiry(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, errors.New(fmt.Sprintf("invalid expiry %s", s))
+	}
+
+	return d, nil
+}
+
+func formatOptionalTime(t *time.Time, empty string) string {
+	if t == nil {
+		return empty
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	"go-ooo/server"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	auTokenId string
+	auTask    string
+	auFrom    string
+	auTo      string
+	auLimit   int
+	auOutput  string
+	auOutFile string
+)
+
+// auditEntry is the exported form of an admin audit log entry
+type auditEntry struct {
+	Id            uint            `json:"id"`
+	Time          time.Time       `json:"time"`
+	TokenId       string          `json:"token_id"`
+	Task          string          `json:"task"`
+	Params        json.RawMessage `json:"params"`
+	Success       bool            `json:"success"`
+	Result        string          `json:"result,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	TxHash        string          `json:"tx_hash,omitempty"`
+	TxStatus      string          `json:"tx_status"`
+	TxBlockNumber uint64          `json:"tx_block_number,omitempty"`
+	TxGasUsed     uint64          `json:"tx_gas_used,omitempty"`
+}
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query and export the admin task audit log",
+	Long: `Query the admin task audit log, which records each admin task submitted to the
+service, the ID of the token used to submit it, the task parameters and result, and the
+on-chain outcome of any Tx sent once confirmed.
+
+--from and --to accept either a date (2006-01-02) or an RFC3339 time.
+
+The service does not need to be running, but the database must be accessible.
+
+Examples:
+
+  go-ooo audit
+  go-ooo audit --task withdraw --from 2024-01-01
+  go-ooo audit --output csv --out audit.csv`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if auOutput != "text" && auOutput != "csv" && auOutput != "json" {
+			return errors.New(fmt.Sprintf("unknown output format %s", auOutput))
+		}
+
+		filter := database.AdminAuditFilter{
+			TokenId: auTokenId,
+			Task:    auTask,
+			Limit:   auLimit,
+		}
+
+		var err error
+		if auFrom != "" {
+			if filter.From, err = parseAuditTime(auFrom); err != nil {
+				return err
+			}
+		}
+		if auTo != "" {
+			if filter.To, err = parseAuditTime(auTo); err != nil {
+				return err
+			}
+		}
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+		cfg := srvCtx.Config
+		logger.SetLogLevel(cfg.Log.Level)
+
+		db, err := database.NewDb(cfg)
+		if err != nil {
+			return err
+		}
+
+		entries, err := db.GetAdminAudit(filter)
+		if err != nil {
+			return err
+		}
+
+		out := io.Writer(os.Stdout)
+		if auOutFile != "" {
+			f, err := os.Create(auOutFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+
+		switch auOutput {
+		case "csv":
+			return writeAuditCsv(out, entries)
+		case "json":
+			exported := make([]auditEntry, 0, len(entries))
+			for _, e := range entries {
+				exported = append(exported, toAuditEntry(e))
+			}
+			return writeJson(out, exported)
+		default:
+			writeAuditText(out, entries)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	auditCmd.Flags().StringVar(&auTokenId, "token", "", "filter by API token ID")
+	auditCmd.Flags().StringVar(&auTask, "task", "", "filter by task, e.g. withdraw")
+	auditCmd.Flags().StringVar(&auFrom, "from", "", "earliest entry time")
+	auditCmd.Flags().StringVar(&auTo, "to", "", "latest entry time")
+	auditCmd.Flags().IntVar(&auLimit, "limit", 0, "maximum number of entries to return")
+	auditCmd.Flags().StringVar(&auOutput, "output", "text", "output format: text, csv or json")
+	auditCmd.Flags().StringVar(&auOutFile, "out", "", "write output to file instead of stdout")
+	rootCmd.AddCommand(auditCmd)
+}
+
+func parseAuditTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return t, errors.New(fmt.Sprintf("invalid time %s. Use 2006-01-02 or RFC3339", s))
+	}
+	return t, nil
+}
+
+func toAuditEntry(e models.AdminAudit) auditEntry {
+	params := json.RawMessage(e.GetParams())
+	if !json.Valid(params) {
+		params = json.RawMessage("null")
+	}
+
+	return auditEntry{
+		Id:            e.GetId(),
+		Time:          e.CreatedAt.UTC(),
+		TokenId:       e.GetTokenId(),
+		Task:          e.GetTask(),
+		Params:        params,
+		Success:       e.GetSuccess(),
+		Result:        e.GetResult(),
+		Error:         e.GetError(),
+		TxHash:        e.GetTxHash(),
+		TxStatus:      e.GetTxStatusString(),
+		TxBlockNumber: e.GetTxBlockNumber(),
+		TxGasUsed:     e.GetTxGasUsed(),
+	}
+}
+
+func writeAuditText(out io.Writer, entries []models.AdminAudit) {
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "no audit entries found")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tTOKEN\tTASK\tSUCCESS\tTX HASH\tTX STATUS")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%v\t%s\t%s\n", e.GetId(), e.CreatedAt.UTC().Format(time.RFC3339),
+			e.GetTokenId(), e.GetTask(), e.GetSuccess(), e.GetTxHash(), e.GetTxStatusString())
+	}
+	_ = w.Flush()
+}
+
+func writeAuditCsv(out io.Writer, entries []models.AdminAudit) error {
+	w := csv.NewWriter(out)
+
+	err := w.Write([]string{"id", "time", "token_id", "task", "params", "success", "result", "error",
+		"tx_hash", "tx_status", "tx_block_number", "tx_gas_used"})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		err = w.Write([]string{
+			strconv.FormatUint(uint64(e.GetId()), 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			e.GetTokenId(),
+			e.GetTask(),
+			e.GetParams(),
+			strconv.FormatBool(e.GetSuccess()),
+			e.GetResult(),
+			e.GetError(),
+			e.GetTxHash(),
+			e.GetTxStatusString(),
+			strconv.FormatUint(e.GetTxBlockNumber(), 10),
+			strconv.FormatUint(e.GetTxGasUsed(), 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"go-ooo/server"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/spf13/cobra"
+)
+
+// dryRunReportCmd represents the dry-run-report command
+var dryRunReportCmd = &cobra.Command{
+	Use:   "dry-run-report",
+	Short: "Compare dry-run prices with the production node's on-chain fulfilments",
+	Long: `Compare the prices recorded by a node started with 'go-ooo start --dry-run'
+with the prices fulfilled on chain by the production node. Must be run against the
+dry-run node.
+
+Examples:
+
+  go-ooo admin dry-run-report --home=/home/user/go-ooo-dry-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "dry_run_report"
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+func init() {
+	adminCmd.AddCommand(dryRunReportCmd)
+}
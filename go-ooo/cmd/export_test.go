@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	go_ooo_types "go-ooo/types"
+)
+
+var ReadFeesFile = readFeesFile
+
+// FeeChange is a feeChange with exported fields, for testing
+type FeeChange struct {
+	Consumer string
+	Current  uint64
+	Fee      uint64
+}
+
+func DiffFees(current, wanted go_ooo_types.ProviderFees, prune bool) ([]FeeChange, []string) {
+	changes, unmanaged := diffFees(current, wanted, prune)
+	out := make([]FeeChange, 0, len(changes))
+	for _, change := range changes {
+		out = append(out, FeeChange{Consumer: change.consumer, Current: change.current, Fee: change.fee})
+	}
+	return out, unmanaged
+}
@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"go-ooo/config"
+	"go-ooo/server"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	feesYes       bool
+	feesPrune     bool
+	feesAll       bool
+	feesOutput    string
+	feesConsumers []string
+)
+
+// feeChange is a fee which differs between the fees file and the Router
+type feeChange struct {
+	consumer string // empty for the min fee
+	current  uint64
+	fee      uint64
+}
+
+// feesCmd represents the fees command
+var feesCmd = &cobra.Command{
+	Use:   "fees",
+	Short: "Manage fees from a fees file",
+	Long: `Manage the provider's min fee and granular fees from a YAML file, for example:
+
+  min_fee: 1000000
+  granular_fees:
+    "0x12345abcde...": 2000000
+    "0x67890fghij...": 500000
+
+Fees are in the smallest xFUND unit, 10 ^ 9. For example, 0.01 xFUND is 10000000.
+
+Fees are read and set via the node's HTTP API, so the service must be running.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("run one of the sub-commands. See 'go-ooo fees --help'")
+	},
+}
+
+// feesApplyCmd represents the fees apply command
+var feesApplyCmd = &cobra.Command{
+	Use:   "apply <fees_file>",
+	Short: "Set the fees in a fees file",
+	Long: `Compare the fees in the file with the fees currently set on the Router, and
+display the differences. Once confirmed, a set_fee or set_granular_fee Tx is sent for each
+fee which differs.
+
+Since the Router returns the min fee for consumers without a granular fee, if the min fee is
+changing a set_granular_fee Tx is sent for consumers in the file whose current fee equals the
+current min fee, so that their fee does not change with the min fee.
+
+Consumers not in the file which have sent requests to the provider, and whose granular
+fee differs from the min fee, are listed but not changed. If the min fee is changing, those
+whose fee equals the current min fee are also listed, since they may have a granular fee
+set. Use --prune to set their granular fee to the file's min fee.
+
+The provider must already be registered with 'go-ooo admin register'.
+
+Examples:
+
+  go-ooo fees apply fees.yaml
+  go-ooo fees apply fees.yaml --prune --yes`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+
+		wanted, err := readFeesFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		pass, err := readApiPassword()
+		fmt.Println("")
+		if err != nil {
+			return err
+		}
+
+		consumers := make([]string, 0, len(wanted.GranularFees))
+		for consumer := range wanted.GranularFees {
+			consumers = append(consumers, consumer)
+		}
+
+		current, err := queryProviderFees(cfg, pass, consumers)
+		if err != nil {
+			return err
+		}
+		if current.MinFee == 0 {
+			return errors.New("provider is not registered. Run 'go-ooo admin register' first")
+		}
+
+		changes, unmanaged := diffFees(current, wanted, feesPrune)
+
+		if len(unmanaged) > 0 {
+			fmt.Println("Consumers not in the fees file with a granular fee, which will not be changed:")
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CONSUMER\tFEE")
+			for _, consumer := range unmanaged {
+				fmt.Fprintf(w, "%s\t%d\n", consumer, current.GranularFees[consumer])
+			}
+			w.Flush()
+			fmt.Println("")
+		}
+
+		if len(changes) == 0 {
+			fmt.Println("Fees are up to date. No Txs to send.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FEE\tCURRENT\tNEW")
+		for _, change := range changes {
+			name := "min fee"
+			if change.consumer != "" {
+				name = change.consumer
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\n", name, change.current, change.fee)
+		}
+		w.Flush()
+		fmt.Println("")
+
+		if !feesYes && !confirm(fmt.Sprintf("Send %d Txs?", len(changes))) {
+			fmt.Println("aborted")
+			return nil
+		}
+
+		for i, change := range changes {
+			adminTask := go_ooo_types.AdminTask{
+				Task:        "set_fee",
+				FeeOrAmount: change.fee,
+			}
+			if change.consumer != "" {
+				adminTask.Task = "set_granular_fee"
+				adminTask.ToOrConsumer = change.consumer
+			}
+
+			resp, err := sendAdminTask(adminTask, cfg, pass)
+			if err != nil {
+				return err
+			}
+			if !resp.Success {
+				return errors.New(fmt.Sprintf("%s failed: %s", adminTask.Task, resp.Error))
+			}
+			fmt.Printf("%d/%d %s %s: %s\n", i+1, len(changes), adminTask.Task, adminTask.ToOrConsumer, resp.TxHash)
+		}
+
+		fmt.Println("")
+		fmt.Println("All Txs sent. Use 'go-ooo audit' to check their outcome.")
+
+		return nil
+	},
+}
+
+// feesExportCmd represents the fees export command
+var feesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the current fees as a fees file",
+	Long: `Read the min fee, and the granular fee for each consumer which has sent requests
+to the provider, from the Router and output them as a fees file. Additional consumers can be
+included with --consumer.
+
+Granular fees equal to the min fee are omitted unless --all is set, since the Router returns
+the min fee for consumers without a granular fee.
+
+Examples:
+
+  go-ooo fees export > fees.yaml
+  go-ooo fees export --consumer 0x12345abcde... --output fees.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+
+		for _, consumer := range feesConsumers {
+			if !common.IsHexAddress(consumer) {
+				return errors.New(fmt.Sprintf("invalid consumer address %s", consumer))
+			}
+		}
+
+		pass, err := readApiPassword()
+		fmt.Fprintln(os.Stderr, "")
+		if err != nil {
+			return err
+		}
+
+		fees, err := queryProviderFees(cfg, pass, feesConsumers)
+		if err != nil {
+			return err
+		}
+
+		if !feesAll {
+			for consumer, fee := range fees.GranularFees {
+				if fee == fees.MinFee {
+					delete(fees.GranularFees, consumer)
+				}
+			}
+		}
+
+		out, err := yaml.Marshal(fees)
+		if err != nil {
+			return err
+		}
+
+		if feesOutput == "" {
+			fmt.Print(string(out))
+			return nil
+		}
+
+		if err = os.WriteFile(feesOutput, out, 0644); err != nil {
+			return err
+		}
+		fmt.Println("fees saved to:")
+		fmt.Println(feesOutput)
+
+		return nil
+	},
+}
+
+func init() {
+	feesApplyCmd.Flags().BoolVar(&feesYes, "yes", false, "send Txs without asking for confirmation")
+	feesApplyCmd.Flags().BoolVar(&feesPrune, "prune", false, "set the granular fee of consumers not in the file to the min fee")
+	feesExportCmd.Flags().BoolVar(&feesAll, "all", false, "include granular fees equal to the min fee")
+	feesExportCmd.Flags().StringVarP(&feesOutput, "output", "o", "", "file to write to. Default stdout")
+	feesExportCmd.Flags().StringSliceVar(&feesConsumers, "consumer", []string{}, "additional consumer contract to include")
+
+	feesCmd.AddCommand(feesApplyCmd)
+	feesCmd.AddCommand(feesExportCmd)
+	rootCmd.AddCommand(feesCmd)
+}
+
+// readFeesFile reads and validates a fees file. Consumer addresses are checksummed
+func readFeesFile(path string) (go_ooo_types.ProviderFees, error) {
+	var raw go_ooo_types.ProviderFees
+	fees := go_ooo_types.ProviderFees{
+		GranularFees: make(map[string]uint64),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fees, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&raw); err != nil {
+		return fees, errors.New(fmt.Sprintf("invalid fees file %s: %s", path, err.Error()))
+	}
+
+	if raw.MinFee == 0 {
+		return fees, errors.New(fmt.Sprintf("invalid fees file %s: min_fee must be greater than zero", path))
+	}
+	fees.MinFee = raw.MinFee
+
+	for consumer, fee := range raw.GranularFees {
+		if !common.IsHexAddress(consumer) {
+			return fees, errors.New(fmt.Sprintf("invalid fees file %s: invalid consumer address %s", path, consumer))
+		}
+		if fee == 0 {
+			return fees, errors.New(fmt.Sprintf("invalid fees file %s: fee for %s must be greater than zero", path, consumer))
+		}
+		address := common.HexToAddress(consumer).Hex()
+		if _, ok := fees.GranularFees[address]; ok {
+			return fees, errors.New(fmt.Sprintf("invalid fees file %s: duplicate consumer %s", path, address))
+		}
+		fees.GranularFees[address] = fee
+	}
+
+	return fees, nil
+}
+
+// queryProviderFees reads the provider's fees from the Router via the node's HTTP API
+func queryProviderFees(cfg *config.Config, pass string, consumers []string) (go_ooo_types.ProviderFees, error) {
+	var fees go_ooo_types.ProviderFees
+
+	resp, err := sendAdminTask(go_ooo_types.AdminTask{
+		Task:         "query_fee_config",
+		ToOrConsumer: strings.Join(consumers, ","),
+	}, cfg, pass)
+	if err != nil {
+		return fees, err
+	}
+	if !resp.Success {
+		return fees, errors.New(resp.Error)
+	}
+
+	err = json.Unmarshal([]byte(resp.Result), &fees)
+	return fees, err
+}
+
+// diffFees returns the changes required to set the wanted fees, min fee first, and the
+// consumers on the Router with a granular fee which are not in the fees file. If prune is
+// true, those consumers are set to the wanted min fee instead.
+//
+// The Router returns the min fee for consumers without a granular fee, so a current fee equal
+// to the current min fee may be either unset or explicitly set. Consumers in the file with
+// such a fee are always sent a set_granular_fee, so that their fee is not left to fall back
+// to the min fee. Consumers not in the file with such a fee are only reported, or pruned, if
+// the min fee is changing, since an explicit granular fee would then keep the old min fee
+func diffFees(current, wanted go_ooo_types.ProviderFees, prune bool) ([]feeChange, []string) {
+	changes := make([]feeChange, 0)
+	unmanaged := make([]string, 0)
+
+	minFeeChanged := current.MinFee != wanted.MinFee
+	if minFeeChanged {
+		changes = append(changes, feeChange{current: current.MinFee, fee: wanted.MinFee})
+	}
+
+	consumers := make([]string, 0, len(current.GranularFees))
+	for consumer := range current.GranularFees {
+		consumers = append(consumers, consumer)
+	}
+	for consumer := range wanted.GranularFees {
+		if _, ok := current.GranularFees[consumer]; !ok {
+			consumers = append(consumers, consumer)
+		}
+	}
+	sort.Strings(consumers)
+
+	for _, consumer := range consumers {
+		currentFee, known := current.GranularFees[consumer]
+		if !known {
+			currentFee = current.MinFee
+		}
+		// may be unset, and fall back to the min fee
+		mayBeUnset := currentFee == current.MinFee
+
+		fee, ok := wanted.GranularFees[consumer]
+		if !ok {
+			if mayBeUnset && !minFeeChanged {
+				// already the min fee, whether or not a granular fee is set
+				continue
+			}
+			if !prune {
+				unmanaged = append(unmanaged, consumer)
+				continue
+			}
+			fee = wanted.MinFee
+		}
+		if fee != currentFee || (mayBeUnset && minFeeChanged) {
+			changes = append(changes, feeChange{consumer: consumer, current: currentFee, fee: fee})
+		}
+	}
+
+	return changes, unmanaged
+}
@@ -0,0 +1,216 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-ooo/cmd"
+	go_ooo_types "go-ooo/types"
+)
+
+const (
+	consumerA = "0x00000000000000000000000000000000000000AA"
+	consumerB = "0x00000000000000000000000000000000000000BB"
+)
+
+func TestReadFeesFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected go_ooo_types.ProviderFees
+		err      string
+	}{
+		{
+			name:     "min fee only",
+			contents: "min_fee: 1000\n",
+			expected: go_ooo_types.ProviderFees{MinFee: 1000, GranularFees: map[string]uint64{}},
+		},
+		{
+			name:     "consumers are checksummed",
+			contents: "min_fee: 1000\ngranular_fees:\n  \"0x00000000000000000000000000000000000000aa\": 2000\n",
+			expected: go_ooo_types.ProviderFees{MinFee: 1000, GranularFees: map[string]uint64{consumerA: 2000}},
+		},
+		{
+			name:     "zero min fee",
+			contents: "min_fee: 0\n",
+			err:      "min_fee must be greater than zero",
+		},
+		{
+			name:     "missing min fee",
+			contents: "granular_fees:\n  \"" + consumerA + "\": 2000\n",
+			err:      "min_fee must be greater than zero",
+		},
+		{
+			name:     "zero granular fee",
+			contents: "min_fee: 1000\ngranular_fees:\n  \"" + consumerA + "\": 0\n",
+			err:      "must be greater than zero",
+		},
+		{
+			name:     "invalid consumer",
+			contents: "min_fee: 1000\ngranular_fees:\n  \"0x1234\": 2000\n",
+			err:      "invalid consumer address 0x1234",
+		},
+		{
+			name:     "duplicate consumer",
+			contents: "min_fee: 1000\ngranular_fees:\n  \"0x00000000000000000000000000000000000000aa\": 2000\n  \"" + consumerA + "\": 3000\n",
+			err:      "duplicate consumer " + consumerA,
+		},
+		{
+			name:     "unknown field",
+			contents: "min_fee: 1000\nmax_fee: 2000\n",
+			err:      "field max_fee not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fees.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0644))
+
+			fees, err := cmd.ReadFeesFile(path)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, fees)
+		})
+	}
+
+	_, err := cmd.ReadFeesFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestDiffFees(t *testing.T) {
+	fees := func(minFee uint64, granular map[string]uint64) go_ooo_types.ProviderFees {
+		if granular == nil {
+			granular = map[string]uint64{}
+		}
+		return go_ooo_types.ProviderFees{MinFee: minFee, GranularFees: granular}
+	}
+
+	tests := []struct {
+		name      string
+		current   go_ooo_types.ProviderFees
+		wanted    go_ooo_types.ProviderFees
+		prune     bool
+		changes   []cmd.FeeChange
+		unmanaged []string
+	}{
+		{
+			name:      "up to date",
+			current:   fees(1000, map[string]uint64{consumerA: 2000}),
+			wanted:    fees(1000, map[string]uint64{consumerA: 2000}),
+			changes:   []cmd.FeeChange{},
+			unmanaged: []string{},
+		},
+		{
+			name:      "min fee changed",
+			current:   fees(1000, nil),
+			wanted:    fees(1500, nil),
+			changes:   []cmd.FeeChange{{Current: 1000, Fee: 1500}},
+			unmanaged: []string{},
+		},
+		{
+			name:      "granular fee changed",
+			current:   fees(1000, map[string]uint64{consumerA: 2000}),
+			wanted:    fees(1000, map[string]uint64{consumerA: 3000}),
+			changes:   []cmd.FeeChange{{Consumer: consumerA, Current: 2000, Fee: 3000}},
+			unmanaged: []string{},
+		},
+		{
+			name:      "granular fee not returned by the Router",
+			current:   fees(1000, nil),
+			wanted:    fees(1000, map[string]uint64{consumerA: 2000}),
+			changes:   []cmd.FeeChange{{Consumer: consumerA, Current: 1000, Fee: 2000}},
+			unmanaged: []string{},
+		},
+		{
+			name:      "wanted fee equal to the unchanged min fee is not sent",
+			current:   fees(1000, map[string]uint64{consumerA: 1000}),
+			wanted:    fees(1000, map[string]uint64{consumerA: 1000}),
+			changes:   []cmd.FeeChange{},
+			unmanaged: []string{},
+		},
+		{
+			name:    "wanted fee equal to the old min fee is sent when the min fee changes",
+			current: fees(1000, map[string]uint64{consumerA: 1000}),
+			wanted:  fees(1500, map[string]uint64{consumerA: 1000}),
+			changes: []cmd.FeeChange{
+				{Current: 1000, Fee: 1500},
+				{Consumer: consumerA, Current: 1000, Fee: 1000},
+			},
+			unmanaged: []string{},
+		},
+		{
+			name:    "wanted fee equal to the new min fee is sent",
+			current: fees(1000, map[string]uint64{consumerA: 1000}),
+			wanted:  fees(1500, map[string]uint64{consumerA: 1500}),
+			changes: []cmd.FeeChange{
+				{Current: 1000, Fee: 1500},
+				{Consumer: consumerA, Current: 1000, Fee: 1500},
+			},
+			unmanaged: []string{},
+		},
+		{
+			name:      "explicit granular fee equal to the new min fee is not sent",
+			current:   fees(1000, map[string]uint64{consumerA: 1500}),
+			wanted:    fees(1500, map[string]uint64{consumerA: 1500}),
+			changes:   []cmd.FeeChange{{Current: 1000, Fee: 1500}},
+			unmanaged: []string{},
+		},
+		{
+			name:      "unmanaged consumer is reported",
+			current:   fees(1000, map[string]uint64{consumerA: 2000, consumerB: 1000}),
+			wanted:    fees(1000, nil),
+			changes:   []cmd.FeeChange{},
+			unmanaged: []string{consumerA},
+		},
+		{
+			name:      "unmanaged consumer is pruned",
+			current:   fees(1000, map[string]uint64{consumerA: 2000, consumerB: 1000}),
+			wanted:    fees(1000, nil),
+			prune:     true,
+			changes:   []cmd.FeeChange{{Consumer: consumerA, Current: 2000, Fee: 1000}},
+			unmanaged: []string{},
+		},
+		{
+			name:      "consumer with the old min fee is reported when the min fee changes",
+			current:   fees(1000, map[string]uint64{consumerA: 1000}),
+			wanted:    fees(1500, nil),
+			changes:   []cmd.FeeChange{{Current: 1000, Fee: 1500}},
+			unmanaged: []string{consumerA},
+		},
+		{
+			name:    "consumer with the old min fee is pruned when the min fee changes",
+			current: fees(1000, map[string]uint64{consumerA: 1000, consumerB: 2000}),
+			wanted:  fees(1500, nil),
+			prune:   true,
+			changes: []cmd.FeeChange{
+				{Current: 1000, Fee: 1500},
+				{Consumer: consumerA, Current: 1000, Fee: 1500},
+				{Consumer: consumerB, Current: 2000, Fee: 1500},
+			},
+			unmanaged: []string{},
+		},
+		{
+			name:      "unmanaged consumer already at the new min fee is not pruned",
+			current:   fees(1000, map[string]uint64{consumerA: 1500}),
+			wanted:    fees(1500, nil),
+			prune:     true,
+			changes:   []cmd.FeeChange{{Current: 1000, Fee: 1500}},
+			unmanaged: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, unmanaged := cmd.DiffFees(tt.current, tt.wanted, tt.prune)
+			require.Equal(t, tt.changes, changes)
+			require.Equal(t, tt.unmanaged, unmanaged)
+		})
+	}
+}
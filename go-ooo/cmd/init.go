@@ -19,7 +19,8 @@ var initCmd = &cobra.Command{
 
 The command will initialise the config.toml file according to the given network option, and
 also your keystore. You can generate a new key, or import an existing private key hex string,
-and will be prompted to do so during the process.
+and will be prompted to do so during the process. Keys are saved as standard Ethereum V3
+keystore files in the keystore directory, encrypted with the generated decryption password.
 
 By default, the environment will use $HOME/.go-ooo to store the app's configuration. You can
 specify where to store these files with the --home flag.
@@ -52,7 +53,7 @@ Examples:
 
 		cfgFile := filepath.Join(appHomePath, "config.toml")
 		dbFile := filepath.Join(appHomePath, "ooo.sqlite")
-		ksFile := filepath.Join(appHomePath, "keystore.json")
+		ksDir := filepath.Join(appHomePath, "keystore")
 		if _, err := os.Stat(cfgFile); errors.Is(err, os.ErrNotExist) {
 			fmt.Println(cfgFile, "does not exist. Creating with defaults")
 
@@ -66,14 +67,18 @@ Examples:
 			conf := config.DefaultConfig()
 			conf.InitForNet(network)
 
-			ks, _ := keystore.NewKeyStorageNoLogger(ksFile)
+			ks, err := keystore.NewV3KeyStorage(ksDir)
+			if err != nil {
+				panic(err)
+			}
 
-			err, ksUser := ks.InitNewKeystore(ksFile)
+			err, ksUser := ks.InitNewKeystore(ksDir)
 			if err != nil {
 				panic(err)
 			}
 
-			conf.SetKeystore(ksFile, ksUser)
+			conf.SetKeystoreType(config.KeystoreTypeV3)
+			conf.SetKeystore(ksDir, ksUser)
 
 			conf.SetSqliteDb(dbFile)
 
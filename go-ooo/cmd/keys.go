@@ -0,0 +1,530 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"go-ooo/config"
+	"go-ooo/keystore"
+	"go-ooo/server"
+	"go-ooo/utils"
+	"go-ooo/utils/walletworker"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	kmDir          string
+	kmUpdateConfig bool
+	kmPass         server.PasswordSource
+	kmNewPass      string
+	kmKeyFile      string
+	kmForce        bool
+)
+
+// keysCmd represents the keys command
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the oracle's keystore",
+	Long: `Manage the keys held in the oracle's keystore without interactive prompts.
+
+The --pass flag can be used to pass the decryption password, or the location of a file
+containing it. --pass-file and --pass-env read it from a file, or "-" for stdin, and an
+environment variable. If not set, the password will be prompted for. If the keystore is empty,
+a new decryption password is generated when the first key is added.
+
+Accounts in a legacy keystore are identified by name, and accounts in a V3 keystore by
+their address.
+
+The service should be stopped while modifying the keystore, and restarted afterwards.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("run one of the sub-commands. See 'go-ooo keys --help'")
+	},
+}
+
+// keysListCmd represents the keys list command
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the accounts in the keystore",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+		ks, err := openKeystore(cfg)
+		if err != nil {
+			return err
+		}
+
+		if !ks.Exists() {
+			fmt.Println("no keys found")
+			return nil
+		}
+
+		// addresses of legacy keystore accounts can only be derived from the decrypted key
+		if !ks.IsV3() {
+			if _, err = unlockKeystore(ks); err != nil {
+				return err
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tADDRESS\tSELECTED")
+		for _, account := range ks.GetAccounts() {
+			address := account
+			if !ks.IsV3() {
+				addr, err := walletworker.AddressFromPrivateKeyString(ks.GetByUsername(account).GetPrivate())
+				if err != nil {
+					return err
+				}
+				address = addr.Hex()
+			}
+			selected := ""
+			if isSelectedAccount(cfg, account) {
+				selected = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", account, address, selected)
+		}
+		return w.Flush()
+	},
+}
+
+// keysAddCmd represents the keys add command
+var keysAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Import an existing private key",
+	Long: `Import an existing hex encoded private key, read from --private-key-file. Use
+"--private-key-file -" to read the key from stdin. A name is required for legacy keystores.
+
+Examples:
+
+  go-ooo keys add --private-key-file /path/to/key.txt --pass /path/to/pass.txt
+  cat key.txt | go-ooo keys add oracle2 --private-key-file -`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if kmKeyFile == "" {
+			return errors.New("--private-key-file is required")
+		}
+		if kmKeyFile == "-" && kmPass.File == "-" {
+			return errors.New("--private-key-file and --pass-file cannot both read from stdin")
+		}
+
+		ks, err := openKeystore(server.GetServerContextFromCmd(cmd).Config)
+		if err != nil {
+			return err
+		}
+
+		name, err := keyName(ks, args)
+		if err != nil {
+			return err
+		}
+
+		privateKey, err := readPrivateKeyFile(kmKeyFile)
+		if err != nil {
+			return err
+		}
+
+		address, err := walletworker.AddressFromPrivateKeyString(privateKey)
+		if err != nil {
+			return errors.New("invalid private key")
+		}
+
+		if _, err = unlockKeystore(ks); err != nil {
+			return err
+		}
+
+		if err = ks.AddExisting(name, privateKey); err != nil {
+			return err
+		}
+
+		fmt.Println("imported key for address", address.Hex())
+		return nil
+	},
+}
+
+// keysGenerateCmd represents the keys generate command
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate [name]",
+	Short: "Generate a new private key",
+	Long: `Generate a new private key, and save it to the keystore. A name is required for legacy
+keystores. The private key is not displayed.
+
+Examples:
+
+  go-ooo keys generate --pass /path/to/pass.txt
+  go-ooo keys generate oracle2`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ks, err := openKeystore(server.GetServerContextFromCmd(cmd).Config)
+		if err != nil {
+			return err
+		}
+
+		name, err := keyName(ks, args)
+		if err != nil {
+			return err
+		}
+
+		if _, err = unlockKeystore(ks); err != nil {
+			return err
+		}
+
+		privateKey, err := ks.GeneratePrivate(name)
+		if err != nil {
+			return err
+		}
+
+		address, err := walletworker.AddressFromPrivateKeyString(privateKey)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("generated key for address", address.Hex())
+		return nil
+	},
+}
+
+// keysRemoveCmd represents the keys remove command
+var keysRemoveCmd = &cobra.Command{
+	Use:   "remove <account>",
+	Short: "Remove a key from the keystore",
+	Long: `Remove a key from the keystore. The key cannot be recovered unless it has been backed
+up. The account selected in config.toml can only be removed with --force.
+
+Examples:
+
+  go-ooo keys remove oracle1
+  go-ooo keys remove 0x1234... --pass /path/to/pass.txt`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+		ks, err := openKeystore(cfg)
+		if err != nil {
+			return err
+		}
+
+		if !ks.ExistsByUsername(args[0]) {
+			return errors.New(fmt.Sprintf("account %s not found", args[0]))
+		}
+
+		if isSelectedAccount(cfg, args[0]) && !kmForce {
+			return errors.New(fmt.Sprintf("account %s is selected in config.toml. Select another account first, or use --force", args[0]))
+		}
+
+		if _, err = unlockKeystore(ks); err != nil {
+			return err
+		}
+
+		if err = ks.Remove(args[0]); err != nil {
+			return err
+		}
+
+		fmt.Println("removed account", args[0])
+		return nil
+	},
+}
+
+// keysSelectCmd represents the keys select command
+var keysSelectCmd = &cobra.Command{
+	Use:   "select <account>",
+	Short: "Select the account used by the service",
+	Long: `Set keystorage.account in config.toml to the account. The service must be restarted
+to use the account.
+
+Examples:
+
+  go-ooo keys select oracle2`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+		ks, err := openKeystore(cfg)
+		if err != nil {
+			return err
+		}
+
+		if !ks.ExistsByUsername(args[0]) {
+			return errors.New(fmt.Sprintf("account %s not found", args[0]))
+		}
+
+		cfg.SetKeystore(cfg.Keystore.File, args[0])
+
+		cfgFile := filepath.Join(appHomePath, "config.toml")
+		config.WriteConfigFile(cfgFile, cfg)
+
+		fmt.Println("selected account", args[0])
+		fmt.Println("config saved to:")
+		fmt.Println(cfgFile)
+		return nil
+	},
+}
+
+// keysChangeTokenCmd represents the keys change-token command
+var keysChangeTokenCmd = &cobra.Command{
+	Use:   "change-token",
+	Short: "Re-encrypt the keystore with a new decryption password",
+	Long: `Re-encrypt every key in the keystore with a new decryption password, which is also
+the admin password for the HTTP API. A new password is generated unless one is given with
+--new-pass, which accepts either the password or the location of a file containing it.
+
+Named API tokens are not affected. Update any password files, and restart the service.
+
+Examples:
+
+  go-ooo keys change-token --pass /path/to/pass.txt
+  go-ooo keys change-token --pass /path/to/pass.txt --new-pass /path/to/new_pass.txt`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ks, err := openKeystore(server.GetServerContextFromCmd(cmd).Config)
+		if err != nil {
+			return err
+		}
+
+		if !ks.Exists() {
+			return errors.New("no keys found")
+		}
+
+		if _, err = unlockKeystore(ks); err != nil {
+			return err
+		}
+
+		newToken := keystore.GenerateRandomBytes(32)
+		if kmNewPass != "" {
+			newToken = server.GetPasswordFromFileOrFlag(kmNewPass)
+		}
+
+		if err = ks.ChangeToken(newToken); err != nil {
+			return err
+		}
+
+		fmt.Println("keystore re-encrypted")
+		if kmNewPass == "" {
+			printNewToken(newToken)
+		}
+		return nil
+	},
+}
+
+// keysMigrateCmd represents the keys migrate command
+var keysMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate a legacy keystore.json to standard V3 keystore files",
+	Long: `Migrate the keys held in a legacy go-ooo keystore.json to standard Ethereum V3
+(Web3 Secret Storage) keystore files, as used by geth and Clef.
+
+Each key is decrypted with the decryption password, and written to the --dir directory
+encrypted with the same password, so the password used to start the service and send
+admin tasks is unchanged. The legacy keystore.json is not modified, and should be
+securely deleted once the migration has been verified.
+
+V3 accounts are identified by their address rather than name. With --update-config,
+the [keystorage] section of config.toml is updated to use the V3 keystore and the
+address of the currently configured account.
+
+The service should be stopped before migrating.
+
+Examples:
+
+  go-ooo keys migrate
+  go-ooo keys migrate --dir /path/to/keystore --update-config`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+
+		if cfg.Keystore.Type == config.KeystoreTypeV3 {
+			return errors.New("keystorage.type is already v3")
+		}
+
+		if _, err := os.Stat(cfg.Keystore.File); err != nil {
+			return errors.New(fmt.Sprintf("cannot open keystore %s: %s", cfg.Keystore.File, err.Error()))
+		}
+
+		ks, err := keystore.NewKeyStorageNoLogger(cfg.Keystore.File)
+		if err != nil {
+			return err
+		}
+
+		if err = checkKeystorePassword(ks, kmPass); err != nil {
+			return err
+		}
+
+		dir := kmDir
+		if dir == "" {
+			dir = filepath.Join(filepath.Dir(cfg.Keystore.File), "keystore")
+		}
+
+		migrated, err := ks.MigrateToV3(dir)
+		if err != nil {
+			return err
+		}
+
+		account := ""
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tADDRESS")
+		for _, m := range migrated {
+			fmt.Fprintf(w, "%s\t%s\n", m.Account, m.Address.Hex())
+			if m.Account == cfg.Keystore.Account {
+				account = m.Address.Hex()
+			}
+		}
+		_ = w.Flush()
+
+		fmt.Println("")
+		fmt.Println("V3 keystore saved to:")
+		fmt.Println(dir)
+		fmt.Println("")
+
+		if account == "" && len(migrated) > 0 {
+			account = migrated[0].Address.Hex()
+		}
+
+		if !kmUpdateConfig {
+			fmt.Println("Update the [keystorage] section of config.toml to use the V3 keystore:")
+			fmt.Println("")
+			fmt.Println("[keystorage]")
+			fmt.Printf("type = \"%s\"\n", config.KeystoreTypeV3)
+			fmt.Printf("account = \"%s\"\n", account)
+			fmt.Printf("file = \"%s\"\n", dir)
+			return nil
+		}
+
+		cfg.SetKeystoreType(config.KeystoreTypeV3)
+		cfg.SetKeystore(dir, account)
+
+		cfgFile := filepath.Join(appHomePath, "config.toml")
+		config.WriteConfigFile(cfgFile, cfg)
+
+		fmt.Println("config saved to:")
+		fmt.Println(cfgFile)
+
+		return nil
+	},
+}
+
+func init() {
+	addPasswordFlags(keysCmd, &kmPass)
+
+	keysAddCmd.Flags().StringVar(&kmKeyFile, "private-key-file", "", "file containing the hex encoded private key, or - for stdin")
+	keysRemoveCmd.Flags().BoolVar(&kmForce, "force", false, "remove the account even if it is selected in config.toml")
+	keysChangeTokenCmd.Flags().StringVar(&kmNewPass, "new-pass", "", "new keystore password or password file location. Default generate a new password")
+
+	keysMigrateCmd.Flags().StringVar(&kmDir, "dir", "", "directory to write V3 keystore files to. Default keystore directory alongside keystore.json")
+	keysMigrateCmd.Flags().BoolVar(&kmUpdateConfig, "update-config", false, "update config.toml to use the V3 keystore")
+
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysAddCmd)
+	keysCmd.AddCommand(keysGenerateCmd)
+	keysCmd.AddCommand(keysRemoveCmd)
+	keysCmd.AddCommand(keysSelectCmd)
+	keysCmd.AddCommand(keysChangeTokenCmd)
+	keysCmd.AddCommand(keysMigrateCmd)
+	rootCmd.AddCommand(keysCmd)
+}
+
+// openKeystore opens the keystore configured in config.toml
+func openKeystore(cfg *config.Config) (*keystore.Keystorage, error) {
+	if cfg.Signer.Type == config.SignerTypeClef {
+		return nil, errors.New("keys are held by the external signer. See signer.type in config.toml")
+	}
+
+	if cfg.Keystore.File == "" {
+		return nil, errors.New("keystorage.file not set in config.toml")
+	}
+
+	if cfg.Keystore.Type == config.KeystoreTypeV3 {
+		return keystore.NewV3KeyStorage(cfg.Keystore.File)
+	}
+
+	return keystore.NewKeyStorageNoLogger(cfg.Keystore.File)
+}
+
+// unlockKeystore sets the keystore's decryption password. If the keystore is empty and has
+// no password, a new password is generated and displayed
+func unlockKeystore(ks *keystore.Keystorage) (bool, error) {
+	if !ks.Exists() && ks.KeyStore.GetHash() == "" {
+		token, err := ks.GenerateToken()
+		if err != nil {
+			return false, err
+		}
+		printNewToken(token)
+		return true, nil
+	}
+
+	return false, checkKeystorePassword(ks, kmPass)
+}
+
+// checkKeystorePassword reads the password from the password flags, or prompts for it, and
+// checks it decrypts the keystore
+func checkKeystorePassword(ks *keystore.Keystorage, src server.PasswordSource) error {
+	pass, err := src.Read()
+	if err != nil {
+		return err
+	}
+
+	if pass == "" {
+		if !term.IsTerminal(int(syscall.Stdin)) {
+			return errors.New("keystore password required. Use --pass, --pass-file or --pass-env")
+		}
+		fmt.Fprint(os.Stderr, "Enter your password:	")
+		bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+		fmt.Fprintln(os.Stderr, "")
+		if err != nil {
+			return err
+		}
+		pass = strings.TrimSpace(string(bytePassword))
+	}
+
+	if err := ks.CheckToken(pass); err != nil {
+		return errors.New("cannot decrypt keystore with this password")
+	}
+	return nil
+}
+
+func printNewToken(token string) {
+	fmt.Println("")
+	fmt.Println("Your new keystore decryption & admin password:")
+	fmt.Println(token)
+	fmt.Println("")
+	fmt.Println("KEEP THIS KEY SAFE! You will need it to run the application and admin tasks!")
+	fmt.Println("")
+}
+
+// keyName returns the account name for a new key. V3 keystore accounts are identified by
+// their address, so do not have a name
+func keyName(ks *keystore.Keystorage, args []string) (string, error) {
+	if ks.IsV3() {
+		return "", nil
+	}
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return "", errors.New("account name required")
+	}
+	if ks.ExistsByUsername(args[0]) {
+		return "", errors.New(fmt.Sprintf("account %s already exists", args[0]))
+	}
+	return args[0], nil
+}
+
+func isSelectedAccount(cfg *config.Config, account string) bool {
+	if cfg.Keystore.Type == config.KeystoreTypeV3 {
+		return strings.EqualFold(cfg.Keystore.Account, account)
+	}
+	return cfg.Keystore.Account == account
+}
+
+func readPrivateKeyFile(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return utils.AddHexPrefix(strings.TrimSpace(string(data))), nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"go-ooo/server"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/spf13/cobra"
+)
+
+// provenanceCmd represents the provenance command
+var provenanceCmd = &cobra.Command{
+	Use:   "provenance <request_id>",
+	Short: "Show how the price for a request was calculated",
+	Long: `Show the price provenance records for a request, including the sources
+queried, the number of raw prices, any values removed by the outlier filter,
+the method and dMax used, and the time window.
+
+Examples:
+
+  go-ooo admin provenance 0x1234abcd...`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "show_provenance"
+		adminTask.RequestId = args[0]
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+func init() {
+	adminCmd.AddCommand(provenanceCmd)
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	"go-ooo/replay"
+	"go-ooo/server"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rFromBlock      uint64
+	rToBlock        uint64
+	rRequestIds     []string
+	rAtRequestBlock bool
+	rOutput         string
+	rOutFile        string
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Re-price historical requests and compare with the stored price",
+	Long: `Re-price historical requests from the database using the current configuration,
+and report the deviation from the price originally returned. Requests are selected either
+by a block range or by request IDs.
+
+Finchains can only return current prices. With --at-request-block, DEX prices are
+calculated for the window ending at the time of the original request block.
+
+The service does not need to be running, but the database must be accessible.
+
+Examples:
+
+  go-ooo replay --from-block 14000000 --to-block 14100000
+  go-ooo replay --request-ids 0x1234...,0x5678... --at-request-block
+  go-ooo replay --from-block 14000000 --output csv --out replay.csv`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// --from-block 0 is a valid range start, so check whether the flag was given rather than its value
+		if len(rRequestIds) == 0 && !cmd.Flags().Changed("from-block") {
+			return errors.New("either --from-block or --request-ids is required")
+		}
+
+		if rOutput != "text" && rOutput != "csv" && rOutput != "json" {
+			return fmt.Errorf("unknown output format %s", rOutput)
+		}
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+		cfg := srvCtx.Config
+		logger.SetLogLevel(cfg.Log.Level)
+
+		db, err := database.NewDb(cfg)
+		if err != nil {
+			return err
+		}
+
+		var requests []models.DataRequests
+		if len(rRequestIds) > 0 {
+			requests, err = db.GetRequestsByRequestIds(rRequestIds)
+		} else {
+			requests, err = db.GetPricedRequestsInBlockRange(rFromBlock, rToBlock)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(requests) == 0 {
+			fmt.Println("no requests found")
+			return nil
+		}
+
+		replayer, err := replay.NewReplayer(context.Background(), cfg, db, rAtRequestBlock)
+		if err != nil {
+			return err
+		}
+
+		results := replayer.Replay(requests)
+
+		out := io.Writer(os.Stdout)
+		if rOutFile != "" {
+			f, err := os.Create(rOutFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+
+		switch rOutput {
+		case "csv":
+			return writeReplayCsv(out, results)
+		case "json":
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(results)
+		default:
+			writeReplayText(out, results)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	replayCmd.Flags().Uint64Var(&rFromBlock, "from-block", 0, "first request block to replay")
+	replayCmd.Flags().Uint64Var(&rToBlock, "to-block", 0, "last request block to replay. Default latest")
+	replayCmd.Flags().StringSliceVar(&rRequestIds, "request-ids", []string{}, "comma separated list of request IDs to replay")
+	replayCmd.Flags().BoolVar(&rAtRequestBlock, "at-request-block", false, "calculate DEX prices at the time of the original request block")
+	replayCmd.Flags().StringVar(&rOutput, "output", "text", "output format: text, csv or json")
+	replayCmd.Flags().StringVar(&rOutFile, "out", "", "write output to file instead of stdout")
+	rootCmd.AddCommand(replayCmd)
+}
+
+func writeReplayText(out io.Writer, results []replay.Result) {
+	var totalDeviation, maxDeviation float64
+	compared := 0
+
+	for _, r := range results {
+		fmt.Fprintf(out, "%s  block %d  %s\n", r.RequestId, r.RequestBlock, r.Endpoint)
+		fmt.Fprintf(out, "  stored : %s (%s)\n", r.StoredPrice, r.StoredSource)
+		if r.Error != "" {
+			fmt.Fprintf(out, "  error  : %s\n\n", r.Error)
+			continue
+		}
+		fmt.Fprintf(out, "  replay : %s (%s)\n", r.ReplayPrice, r.ReplaySource)
+		fmt.Fprintf(out, "  dev    : %.4f%%\n\n", r.Deviation*100)
+
+		compared++
+		totalDeviation += r.Deviation
+		if r.Deviation > maxDeviation {
+			maxDeviation = r.Deviation
+		}
+	}
+
+	fmt.Fprintf(out, "Requests : %d\n", len(results))
+	fmt.Fprintf(out, "Replayed : %d\n", compared)
+	if compared > 0 {
+		fmt.Fprintf(out, "Mean dev : %.4f%%\n", totalDeviation/float64(compared)*100)
+		fmt.Fprintf(out, "Max dev  : %.4f%%\n", maxDeviation*100)
+	}
+}
+
+func writeReplayCsv(out io.Writer, results []replay.Result) error {
+	w := csv.NewWriter(out)
+
+	err := w.Write([]string{"request_id", "endpoint", "request_block", "priced_at", "stored_price",
+		"stored_source", "replay_price", "replay_source", "deviation", "error"})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		pricedAt := ""
+		if r.PricedAt != nil {
+			pricedAt = r.PricedAt.UTC().Format("2006-01-02T15:04:05Z")
+		}
+		err = w.Write([]string{
+			r.RequestId,
+			r.Endpoint,
+			strconv.FormatUint(r.RequestBlock, 10),
+			pricedAt,
+			r.StoredPrice,
+			r.StoredSource,
+			r.ReplayPrice,
+			r.ReplaySource,
+			strconv.FormatFloat(r.Deviation, 'f', 6, 64),
+			r.Error,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"go-ooo/config"
+	"go-ooo/server"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	rqStatus    string
+	rqConsumer  string
+	rqFromBlock uint64
+	rqToBlock   uint64
+	rqAdhoc     string
+	rqLimit     int
+	rqOutput    string
+)
+
+// requestsCmd represents the requests command
+var requestsCmd = &cobra.Command{
+	Use:   "requests",
+	Short: "Query the status of requests and pending jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("run one of the sub-commands. See 'go-ooo requests --help'")
+	},
+}
+
+// requestsListCmd represents the requests list command
+var requestsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List requests, most recent first",
+	Long: `List requests held in the database, most recent first. Requests can be filtered by
+status, consumer contract, request block range, and whether or not they are AdHoc requests.
+
+Status can be either the status name, e.g. "fulfilment_failed", or the numeric status.
+
+Examples:
+
+  go-ooo requests list --status fulfilment_failed
+  go-ooo requests list --consumer 0x1234... --from-block 14000000 --to-block 14100000
+  go-ooo requests list --adhoc true --limit 20 --output json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkRequestsOutput(); err != nil {
+			return err
+		}
+
+		query := url.Values{}
+		if rqStatus != "" {
+			query.Set("status", rqStatus)
+		}
+		if rqConsumer != "" {
+			query.Set("consumer", rqConsumer)
+		}
+		if rqFromBlock > 0 {
+			query.Set("from_block", strconv.FormatUint(rqFromBlock, 10))
+		}
+		if rqToBlock > 0 {
+			query.Set("to_block", strconv.FormatUint(rqToBlock, 10))
+		}
+		if rqAdhoc != "" {
+			query.Set("adhoc", rqAdhoc)
+		}
+		if rqLimit > 0 {
+			query.Set("limit", strconv.Itoa(rqLimit))
+		}
+
+		var requests []go_ooo_types.RequestInfo
+		err := getStatusApi(server.GetServerContextFromCmd(cmd).Config, "/requests", query, &requests)
+		if err != nil {
+			return err
+		}
+
+		if rqOutput == "json" {
+			return writeJson(os.Stdout, requests)
+		}
+
+		writeRequestsText(os.Stdout, requests)
+		return nil
+	},
+}
+
+// requestsShowCmd represents the requests show command
+var requestsShowCmd = &cobra.Command{
+	Use:   "show <request_id>",
+	Short: "Show a request and its failed fulfilment history",
+	Long: `Show a single request, along with any failed fulfilment attempts.
+
+Examples:
+
+  go-ooo requests show 0x1234abcd...`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkRequestsOutput(); err != nil {
+			return err
+		}
+
+		var detail go_ooo_types.RequestDetail
+		err := getStatusApi(server.GetServerContextFromCmd(cmd).Config, "/requests/"+url.PathEscape(args[0]), nil, &detail)
+		if err != nil {
+			return err
+		}
+
+		if rqOutput == "json" {
+			return writeJson(os.Stdout, detail)
+		}
+
+		writeRequestDetailText(os.Stdout, detail)
+		return nil
+	},
+}
+
+// requestsJobsCmd represents the requests jobs command
+var requestsJobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Show the pending job queue",
+	Long: `Show the pending job queue, oldest first, with the time each job has spent in its
+current status, and the time since the request was received.
+
+Examples:
+
+  go-ooo requests jobs`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkRequestsOutput(); err != nil {
+			return err
+		}
+
+		var jobs []go_ooo_types.PendingJob
+		err := getStatusApi(server.GetServerContextFromCmd(cmd).Config, "/jobs", nil, &jobs)
+		if err != nil {
+			return err
+		}
+
+		if rqOutput == "json" {
+			return writeJson(os.Stdout, jobs)
+		}
+
+		writePendingJobsText(os.Stdout, jobs)
+		return nil
+	},
+}
+
+func init() {
+	requestsListCmd.Flags().StringVar(&rqStatus, "status", "", "filter by request status name or number")
+	requestsListCmd.Flags().StringVar(&rqConsumer, "consumer", "", "filter by consumer contract address")
+	requestsListCmd.Flags().Uint64Var(&rqFromBlock, "from-block", 0, "first request block")
+	requestsListCmd.Flags().Uint64Var(&rqToBlock, "to-block", 0, "last request block")
+	requestsListCmd.Flags().StringVar(&rqAdhoc, "adhoc", "", "filter by AdHoc requests: true or false")
+	requestsListCmd.Flags().IntVar(&rqLimit, "limit", 0, "maximum number of requests to return. Default 100")
+
+	requestsCmd.PersistentFlags().StringVar(&rqOutput, "output", "text", "output format: text or json")
+
+	requestsCmd.AddCommand(requestsListCmd)
+	requestsCmd.AddCommand(requestsShowCmd)
+	requestsCmd.AddCommand(requestsJobsCmd)
+	rootCmd.AddCommand(requestsCmd)
+}
+
+func checkRequestsOutput() error {
+	if rqOutput != "text" && rqOutput != "json" {
+		return errors.New(fmt.Sprintf("unknown output format %s", rqOutput))
+	}
+	return nil
+}
+
+// getStatusApi sends an authenticated GET request to the running service, and decodes the
+// JSON response into res
+func getStatusApi(cfg *config.Config, path string, query url.Values, res interface{}) error {
+	fmt.Fprint(os.Stderr, "Enter your password:	")
+
+	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(os.Stderr, "")
+	if err != nil {
+		return err
+	}
+
+	pass := strings.TrimSpace(string(bytePassword))
+
+	u := apiBaseUrl(cfg) + path
+	if len(query) > 0 {
+		u = fmt.Sprintf("%s?%s", u, query.Encode())
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Authorization", "Bearer "+pass)
+
+	client, err := apiHttpClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("%s: %s", resp.Status, strings.TrimSpace(string(body))))
+	}
+
+	return json.Unmarshal(body, res)
+}
+
+func writeJson(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeRequestsText(out io.Writer, requests []go_ooo_types.RequestInfo) {
+	if len(requests) == 0 {
+		fmt.Fprintln(out, "no requests found")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REQUEST ID\tBLOCK\tENDPOINT\tCONSUMER\tSTATUS\tJOB\tATTEMPTS\tPRICE")
+	for _, r := range requests {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%d\t%s\n", r.RequestId, r.RequestBlockNumber, r.Endpoint,
+			r.Consumer, r.RequestStatus, r.JobStatus, r.FulfillmentAttempts, r.PriceResult)
+	}
+	_ = w.Flush()
+}
+
+func writeRequestDetailText(out io.Writer, detail go_ooo_types.RequestDetail) {
+	r := detail.Request
+
+	fmt.Fprintln(out, "Request ID       :", r.RequestId)
+	fmt.Fprintln(out, "Consumer         :", r.Consumer)
+	fmt.Fprintln(out, "Endpoint         :", r.Endpoint)
+	fmt.Fprintln(out, "AdHoc            :", r.IsAdhoc)
+	fmt.Fprintln(out, "Fee              :", r.Fee)
+	fmt.Fprintln(out, "Request block    :", r.RequestBlockNumber)
+	fmt.Fprintln(out, "Request tx       :", r.RequestTxHash)
+	fmt.Fprintln(out, "Status           :", r.RequestStatus)
+	fmt.Fprintln(out, "Job status       :", r.JobStatus)
+	if r.StatusReason != "" {
+		fmt.Fprintln(out, "Status reason    :", r.StatusReason)
+	}
+	fmt.Fprintln(out, "Attempts         :", r.FulfillmentAttempts)
+	if r.PriceResult != "" {
+		fmt.Fprintf(out, "Price            : %s (%s)\n", r.PriceResult, r.PriceSource)
+	}
+	if r.FulfillTxHash != "" {
+		fmt.Fprintln(out, "Fulfil tx        :", r.FulfillTxHash)
+		fmt.Fprintln(out, "Fulfil block     :", r.FulfillConfirmedBlockNumber)
+		fmt.Fprintln(out, "Fulfil gas       :", r.FulfillGasUsed, "@", r.FulfillGasPrice)
+	}
+	fmt.Fprintln(out, "Created          :", r.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintln(out, "Updated          :", r.UpdatedAt.UTC().Format(time.RFC3339))
+
+	if len(detail.FailedFulfilments) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Failed fulfilments:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tTX HASH\tGAS USED\tGAS PRICE\tREASON")
+	for _, f := range detail.FailedFulfilments {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", f.CreatedAt.UTC().Format(time.RFC3339), f.TxHash,
+			f.GasUsed, f.GasPrice, f.FailReason)
+	}
+	_ = w.Flush()
+}
+
+func writePendingJobsText(out io.Writer, jobs []go_ooo_types.PendingJob) {
+	if len(jobs) == 0 {
+		fmt.Fprintln(out, "no pending jobs")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REQUEST ID\tBLOCK\tENDPOINT\tSTATUS\tIN STATUS\tAGE\tATTEMPTS")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%d\n", j.RequestId, j.RequestBlockNumber, j.Endpoint, j.RequestStatus,
+			time.Duration(j.StatusAgeSeconds)*time.Second, time.Duration(j.AgeSeconds)*time.Second, j.FulfillmentAttempts)
+	}
+	_ = w.Flush()
+}
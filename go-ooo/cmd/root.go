@@ -8,7 +8,8 @@ import (
 )
 
 var appHomePath string
-var keystorePass string
+var keystorePass server.PasswordSource
+var dryRun bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -29,3 +30,10 @@ func Execute(defaultHome string) {
 	rootCmd.PersistentFlags().StringVar(&appHomePath, flags.FlagHome, defaultHome, "app home file (default is $HOME/.go-ooo)")
 	cobra.CheckErr(rootCmd.ExecuteContext(ctx))
 }
+
+// addPasswordFlags adds the flags the keystore decryption password can be read from
+func addPasswordFlags(cmd *cobra.Command, src *server.PasswordSource) {
+	cmd.PersistentFlags().StringVar(&src.Pass, flags.FlagPass, "", "keystore password or password file location")
+	cmd.PersistentFlags().StringVar(&src.File, flags.FlagPassFile, "", "file containing the keystore password, or - to read it from stdin")
+	cmd.PersistentFlags().StringVar(&src.Env, flags.FlagPassEnv, "", "environment variable containing the keystore password")
+}
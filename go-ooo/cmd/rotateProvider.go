@@ -0,0 +1,477 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go-ooo/chain"
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/keystore"
+	"go-ooo/logger"
+	"go-ooo/ooo_router"
+	"go-ooo/server"
+	"go-ooo/signer"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+)
+
+// rotateProviderTokenId is recorded in the admin audit log for Txs sent by rotate-provider
+const rotateProviderTokenId = "cli:rotate-provider"
+
+var (
+	rpPass       server.PasswordSource
+	rpConsumers  []string
+	rpWithdrawTo string
+	rpTimeout    time.Duration
+	rpYes        bool
+	rpForce      bool
+)
+
+// rotateStep is a single admin task sent during a provider key rotation
+type rotateStep struct {
+	description string
+	router      *chain.OoORouterService
+	task        go_ooo_types.AdminTask
+}
+
+// rotateProviderCmd represents the rotate-provider command
+var rotateProviderCmd = &cobra.Command{
+	Use:   "rotate-provider <new_account>",
+	Short: "Rotate the provider key to a new account",
+	Long: `Rotate the oracle's provider key to another account in the keystore. The new address
+is registered on the Router with the current account's min fee, and the granular fee for
+each consumer which has sent requests to the current address is copied. Any withdrawable
+fees are then withdrawn from the current address. Each Tx is sent in order, and must be
+confirmed before the next is sent.
+
+Once complete, config.toml is updated to use the new account, and the current account is
+set as the retiring account. When the service is restarted, it will continue to fulfil
+requests sent to the retiring address until 'go-ooo rotate-provider finish' is run. The
+consumer contracts listed must be updated to send requests to the new address.
+
+Additional consumers can be included with --consumer. Withdrawn fees are sent to the new
+address unless --withdraw-to is set. The new address must hold enough funds for gas.
+
+The service must be stopped, and the keystore must be used as the signer.
+
+Examples:
+
+  go-ooo rotate-provider oracle2 --pass /path/to/pass.txt
+  go-ooo rotate-provider 0x1234... --consumer 0xabcd... --withdraw-to 0x5678...`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+		logger.SetLogLevel(cfg.Log.Level)
+
+		if cfg.Keystore.RetiringAccount != "" {
+			return errors.New(fmt.Sprintf("account %s is already retiring. Run 'go-ooo rotate-provider finish' first",
+				cfg.Keystore.RetiringAccount))
+		}
+
+		newAccount := args[0]
+		if isSelectedAccount(cfg, newAccount) {
+			return errors.New(fmt.Sprintf("account %s is already the provider account", newAccount))
+		}
+
+		ks, err := openKeystore(cfg)
+		if err != nil {
+			return err
+		}
+		if !ks.ExistsByUsername(newAccount) {
+			return errors.New(fmt.Sprintf("account %s not found", newAccount))
+		}
+		if err = checkKeystorePassword(ks, rpPass); err != nil {
+			return err
+		}
+
+		oldSigner, err := keystoreSigner(ks, cfg.Keystore.Account)
+		if err != nil {
+			return err
+		}
+		newSigner, err := keystoreSigner(ks, newAccount)
+		if err != nil {
+			return err
+		}
+
+		db, oldRouter, err := rotateRouter(cfg, oldSigner)
+		if err != nil {
+			return err
+		}
+		_, newRouter, err := rotateRouter(cfg, newSigner)
+		if err != nil {
+			return err
+		}
+
+		consumers, err := db.GetConsumers(oldSigner.Address().Hex())
+		if err != nil {
+			return err
+		}
+		for _, c := range rpConsumers {
+			if !common.IsHexAddress(c) {
+				return errors.New(fmt.Sprintf("invalid consumer address %s", c))
+			}
+			consumers = append(consumers, common.HexToAddress(c).Hex())
+		}
+		consumers = uniqueAddresses(consumers)
+
+		oldFees, err := oldRouter.GetFeeConfig(consumers)
+		if err != nil {
+			return err
+		}
+		if oldFees.MinFee == 0 {
+			return errors.New(fmt.Sprintf("%s is not registered as a provider", oldSigner.Address().Hex()))
+		}
+
+		// the new address may already have been registered by a previous, interrupted, rotation
+		newFees, err := newRouter.GetFeeConfig(consumers)
+		if err != nil {
+			return err
+		}
+
+		withdrawTo := newSigner.Address().Hex()
+		if rpWithdrawTo != "" {
+			if !common.IsHexAddress(rpWithdrawTo) {
+				return errors.New(fmt.Sprintf("invalid address %s", rpWithdrawTo))
+			}
+			withdrawTo = common.HexToAddress(rpWithdrawTo).Hex()
+		}
+
+		withdrawable, err := oldRouter.GetWithdrawable()
+		if err != nil {
+			return err
+		}
+
+		steps := rotateSteps(oldRouter, newRouter, oldFees, newFees, withdrawable, withdrawTo)
+
+		fmt.Println("Current provider :", oldSigner.Address().Hex())
+		fmt.Println("New provider     :", newSigner.Address().Hex())
+		fmt.Println("")
+		if len(steps) == 0 {
+			fmt.Println("The new provider's fees already match. No Txs to send.")
+		} else {
+			fmt.Println("The following Txs will be sent, in order:")
+			for i, step := range steps {
+				fmt.Printf("  %d. %s\n", i+1, step.description)
+			}
+		}
+		fmt.Println("")
+
+		if !rpYes && !confirm("Continue?") {
+			fmt.Println("aborted")
+			return nil
+		}
+
+		if err = runRotateSteps(db, steps); err != nil {
+			return err
+		}
+
+		cfg.SetRetiringAccount(cfg.Keystore.Account)
+		cfg.SetKeystore(cfg.Keystore.File, newAccount)
+		if err = writeRotateConfig(cfg); err != nil {
+			return err
+		}
+
+		fmt.Println("")
+		fmt.Println("Rotation complete. Restart the service to use the new provider address.")
+		fmt.Println("Requests sent to the retiring address will continue to be fulfilled until")
+		fmt.Println("'go-ooo rotate-provider finish' is run.")
+		fmt.Println("")
+		if len(consumers) > 0 {
+			fmt.Println("Notify the owners of the following consumer contracts to send requests to", newSigner.Address().Hex())
+			for _, c := range consumers {
+				fmt.Println(" ", c)
+			}
+		}
+
+		return nil
+	},
+}
+
+// rotateProviderFinishCmd represents the rotate-provider finish command
+var rotateProviderFinishCmd = &cobra.Command{
+	Use:   "finish",
+	Short: "Stop serving the retiring provider address",
+	Long: `Complete a provider key rotation. Any fees accumulated by the retiring address since the
+rotation are withdrawn to the current provider address, or --withdraw-to, and the retiring
+account is removed from config.toml.
+
+The retiring address remains registered on the Router, but requests sent to it will no
+longer be fulfilled. If it still has pending requests, --force is required.
+
+The service must be stopped.
+
+Examples:
+
+  go-ooo rotate-provider finish --pass /path/to/pass.txt`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := server.GetServerContextFromCmd(cmd).Config
+		logger.SetLogLevel(cfg.Log.Level)
+
+		if cfg.Keystore.RetiringAccount == "" {
+			return errors.New("no retiring account set in config.toml")
+		}
+
+		ks, err := openKeystore(cfg)
+		if err != nil {
+			return err
+		}
+		if err = checkKeystorePassword(ks, rpPass); err != nil {
+			return err
+		}
+
+		retiringSigner, err := keystoreSigner(ks, cfg.Keystore.RetiringAccount)
+		if err != nil {
+			return err
+		}
+		currentSigner, err := keystoreSigner(ks, cfg.Keystore.Account)
+		if err != nil {
+			return err
+		}
+
+		db, retiringRouter, err := rotateRouter(cfg, retiringSigner)
+		if err != nil {
+			return err
+		}
+
+		pending, err := db.GetPendingJobsForProvider(retiringSigner.Address().Hex())
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 && !rpForce {
+			return errors.New(fmt.Sprintf("%d requests to %s are still pending. Use --force to finish anyway",
+				len(pending), retiringSigner.Address().Hex()))
+		}
+
+		withdrawTo := currentSigner.Address().Hex()
+		if rpWithdrawTo != "" {
+			if !common.IsHexAddress(rpWithdrawTo) {
+				return errors.New(fmt.Sprintf("invalid address %s", rpWithdrawTo))
+			}
+			withdrawTo = common.HexToAddress(rpWithdrawTo).Hex()
+		}
+
+		withdrawable, err := retiringRouter.GetWithdrawable()
+		if err != nil {
+			return err
+		}
+
+		if withdrawable > 0 {
+			steps := []rotateStep{withdrawStep(retiringRouter, withdrawable, withdrawTo)}
+			fmt.Println(steps[0].description)
+			if !rpYes && !confirm("Continue?") {
+				fmt.Println("aborted")
+				return nil
+			}
+			if err = runRotateSteps(db, steps); err != nil {
+				return err
+			}
+		}
+
+		cfg.SetRetiringAccount("")
+		if err = writeRotateConfig(cfg); err != nil {
+			return err
+		}
+
+		fmt.Println("")
+		fmt.Println("No longer serving", retiringSigner.Address().Hex()+". Restart the service.")
+		return nil
+	},
+}
+
+func init() {
+	addPasswordFlags(rotateProviderCmd, &rpPass)
+	rotateProviderCmd.PersistentFlags().StringVar(&rpWithdrawTo, "withdraw-to", "", "recipient of withdrawn fees. Default new provider address")
+	rotateProviderCmd.PersistentFlags().DurationVar(&rpTimeout, "timeout", 10*time.Minute, "time to wait for each Tx to be confirmed")
+	rotateProviderCmd.PersistentFlags().BoolVar(&rpYes, "yes", false, "send Txs without asking for confirmation")
+	rotateProviderCmd.Flags().StringSliceVar(&rpConsumers, "consumer", []string{}, "additional consumer contract whose granular fee should be copied")
+	rotateProviderFinishCmd.Flags().BoolVar(&rpForce, "force", false, "finish even if the retiring address has pending requests")
+
+	rotateProviderCmd.AddCommand(rotateProviderFinishCmd)
+	rootCmd.AddCommand(rotateProviderCmd)
+}
+
+// rotateSteps returns the Txs required to reproduce the old provider's fee configuration
+// for the new provider, and withdraw the old provider's fees
+func rotateSteps(oldRouter, newRouter *chain.OoORouterService, oldFees, newFees chain.FeeConfig,
+	withdrawable uint64, withdrawTo string) []rotateStep {
+
+	steps := make([]rotateStep, 0)
+
+	if newFees.MinFee == 0 {
+		steps = append(steps, rotateStep{
+			description: fmt.Sprintf("register %s with min fee %d", newRouter.GetProviderAddress().Hex(), oldFees.MinFee),
+			router:      newRouter,
+			task:        go_ooo_types.AdminTask{Task: "register", FeeOrAmount: oldFees.MinFee},
+		})
+	} else if newFees.MinFee != oldFees.MinFee {
+		steps = append(steps, rotateStep{
+			description: fmt.Sprintf("set %s min fee to %d", newRouter.GetProviderAddress().Hex(), oldFees.MinFee),
+			router:      newRouter,
+			task:        go_ooo_types.AdminTask{Task: "set_fee", FeeOrAmount: oldFees.MinFee},
+		})
+	}
+
+	consumers := make([]string, 0, len(oldFees.GranularFees))
+	for consumer := range oldFees.GranularFees {
+		consumers = append(consumers, consumer)
+	}
+	sort.Strings(consumers)
+
+	for _, consumer := range consumers {
+		fee := oldFees.GranularFees[consumer]
+		if newFees.GranularFees[consumer] == fee {
+			continue
+		}
+		steps = append(steps, rotateStep{
+			description: fmt.Sprintf("set %s granular fee for %s to %d", newRouter.GetProviderAddress().Hex(), consumer, fee),
+			router:      newRouter,
+			task:        go_ooo_types.AdminTask{Task: "set_granular_fee", FeeOrAmount: fee, ToOrConsumer: consumer},
+		})
+	}
+
+	if withdrawable > 0 {
+		steps = append(steps, withdrawStep(oldRouter, withdrawable, withdrawTo))
+	}
+
+	return steps
+}
+
+func withdrawStep(router *chain.OoORouterService, amount uint64, recipient string) rotateStep {
+	return rotateStep{
+		description: fmt.Sprintf("withdraw %d from %s to %s", amount, router.GetProviderAddress().Hex(), recipient),
+		router:      router,
+		task:        go_ooo_types.AdminTask{Task: "withdraw", FeeOrAmount: amount, ToOrConsumer: recipient},
+	}
+}
+
+// runRotateSteps sends each Tx in order, recording it in the admin audit log, and waits for
+// it to be confirmed before sending the next
+func runRotateSteps(db *database.DB, steps []rotateStep) error {
+	for i, step := range steps {
+		fmt.Printf("%d/%d %s\n", i+1, len(steps), step.description)
+
+		step.task.TokenId = rotateProviderTokenId
+		resp := step.router.ProcessAdminTask(step.task)
+		recordRotateAudit(db, step.task, resp)
+
+		if !resp.Success {
+			return errors.New(fmt.Sprintf("%s failed: %s", step.description, resp.Error))
+		}
+
+		fmt.Println("    sent", resp.TxHash, "- waiting for confirmation")
+		if err := step.router.WaitForTx(resp.TxHash, rpTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordRotateAudit appends the task to the admin audit log. The Tx outcome is recorded by the
+// service once it is restarted
+func recordRotateAudit(db *database.DB, task go_ooo_types.AdminTask, resp go_ooo_types.AdminTaskResponse) {
+	params, _ := json.Marshal(task)
+
+	entry := models.AdminAudit{
+		TokenId:  task.TokenId,
+		Task:     task.Task,
+		Params:   string(params),
+		Success:  resp.Success,
+		Result:   resp.Result,
+		Error:    resp.Error,
+		TxHash:   resp.TxHash,
+		TxStatus: models.AUDIT_TX_STATUS_NONE,
+	}
+	if resp.TxHash != "" {
+		entry.TxStatus = models.AUDIT_TX_STATUS_PENDING
+	}
+
+	if err := db.InsertAdminAudit(entry); err != nil {
+		fmt.Println("failed to record audit entry:", err.Error())
+	}
+}
+
+func keystoreSigner(ks *keystore.Keystorage, account string) (signer.Signer, error) {
+	key, err := ks.GetByAccount(account)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("account %s not found", account))
+	}
+	if key.GetPrivate() == "" {
+		return nil, errors.New(fmt.Sprintf("cannot decrypt key for account %s", account))
+	}
+	return signer.NewKeystoreSigner(key.GetPrivate())
+}
+
+// rotateRouter returns an OoORouterService for the signer, connected to the chain's HTTP RPC
+func rotateRouter(cfg *config.Config, s signer.Signer) (*database.DB, *chain.OoORouterService, error) {
+	if cfg.Signer.Type == config.SignerTypeClef {
+		return nil, nil, errors.New("rotate-provider requires the keystore signer")
+	}
+
+	db, err := database.NewDb(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = db.Migrate(); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := ethclient.Dial(cfg.Chain.EthHttpHost)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contractAddress := common.HexToAddress(cfg.Chain.ContractAddress)
+	router, err := ooo_router.NewOooRouter(contractAddress, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	routerService, err := chain.NewOoORouter(context.Background(), cfg, client, router, contractAddress, s, db, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, routerService, nil
+}
+
+func writeRotateConfig(cfg *config.Config) error {
+	cfgFile := filepath.Join(appHomePath, "config.toml")
+	config.WriteConfigFile(cfgFile, cfg)
+	fmt.Println("config saved to:")
+	fmt.Println(cfgFile)
+	return nil
+}
+
+func uniqueAddresses(addresses []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		if !seen[a] {
+			seen[a] = true
+			unique = append(unique, a)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func confirm(prompt string) bool {
+	fmt.Print(prompt, " [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
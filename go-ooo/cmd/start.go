@@ -19,13 +19,25 @@ the configuration.
 
 The --home path can be specified.
 The --pass flag can also be used to pass the location of the file containing your
-keystore password, or the password itself.
+keystore password, or the password itself. For unattended start-up, --pass-file reads
+the password from a file, which must exist, or from stdin with "--pass-file -", and
+--pass-env reads it from an environment variable. If none are set, the password is read
+from the systemd credential "go-ooo-pass" if available, otherwise it is prompted for.
+Start-up fails instead of prompting when not running in a terminal.
+
+The --dry-run flag runs the service without sending any transactions. Requests are
+watched and priced as normal, but the fulfilment transactions are recorded in the
+shadow_fulfilments table instead. This can be used to test a new build alongside a
+production node. Use 'go-ooo admin dry-run-report' to compare the results.
 
 Examples:
 
   go-ooo start
   go-ooo start --home=/home/user/some-other-go-ooo
   go-ooo start --home=/home/user/some-other-go-ooo --pass=/path/to/pass.txt
+  go-ooo start --pass-file=/run/secrets/go-ooo-pass
+  GO_OOO_PASS=... go-ooo start --pass-env=GO_OOO_PASS
+  go-ooo start --home=/home/user/go-ooo-dry-run --dry-run
 `,
 	PreRunE: func(cmd *cobra.Command, _ []string) error {
 		serverCtx := server.GetServerContextFromCmd(cmd)
@@ -37,7 +49,7 @@ Examples:
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		serverCtx := server.GetServerContextFromCmd(cmd)
-		server, err := server.NewServer(serverCtx, keystorePass)
+		server, err := server.NewServer(serverCtx, keystorePass, dryRun)
 		if err != nil {
 			panic(err)
 		}
@@ -48,6 +60,7 @@ Examples:
 }
 
 func init() {
-	startCmd.PersistentFlags().StringVar(&keystorePass, "pass", "", "keystore password or password file location")
+	addPasswordFlags(startCmd, &keystorePass)
+	startCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "price requests without sending fulfilment txs")
 	rootCmd.AddCommand(startCmd)
 }
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"go-ooo/server"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/spf13/cobra"
+)
+
+// tokensCmd represents the tokens command
+var tokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "Review and approve canonical token contracts used for AdHoc pricing",
+	Long: `AdHoc prices are only calculated from DEX pairs where both tokens are the
+canonical contract for their symbol on the chain. New tokens found while indexing DEX
+pairs are pending until they are reviewed and approved, so that a spoofed contract cannot be
+used for pricing.
+
+Tokens already known when upgrading from a database without canonical tokens are approved
+during migration, unless another contract exists for the same symbol on the chain.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("run one of the sub-commands. See 'go-ooo admin tokens --help'")
+	},
+}
+
+// tokensPendingCmd represents the tokens pending command
+var tokensPendingCmd = &cobra.Command{
+	Use:   "pending [chain]",
+	Short: "List token contracts pending review",
+	Long: `List token contracts which have not been approved as canonical, optionally
+filtered by chain.
+
+Examples:
+
+  go-ooo admin tokens pending
+  go-ooo admin tokens pending eth`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "list_pending_tokens"
+		if len(args) > 0 {
+			adminTask.Chain = args[0]
+		}
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+// tokensApproveCmd represents the tokens approve command
+var tokensApproveCmd = &cobra.Command{
+	Use:   "approve <chain> <token_contract_address>",
+	Short: "Approve a token contract as canonical for its symbol",
+	Long: `Approve a token contract as the canonical contract for its symbol on the
+given chain. Any other contract with the same symbol on the chain will be revoked.
+
+Examples:
+
+  go-ooo admin tokens approve eth 0x12345abcde...`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "approve_token"
+		adminTask.Chain = args[0]
+		adminTask.ToOrConsumer = args[1]
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+// tokensRevokeCmd represents the tokens revoke command
+var tokensRevokeCmd = &cobra.Command{
+	Use:   "revoke <chain> <token_contract_address>",
+	Short: "Revoke a token contract's canonical status",
+	Long: `Revoke a token contract's canonical status. Pairs using the token will no
+longer be used for AdHoc pricing.
+
+Examples:
+
+  go-ooo admin tokens revoke eth 0x12345abcde...`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminTask := go_ooo_types.AdminTask{}
+
+		adminTask.Task = "revoke_token"
+		adminTask.Chain = args[0]
+		adminTask.ToOrConsumer = args[1]
+
+		srvCtx := server.GetServerContextFromCmd(cmd)
+
+		processAdminTask(adminTask, srvCtx.Config)
+	},
+}
+
+func init() {
+	tokensCmd.AddCommand(tokensPendingCmd)
+	tokensCmd.AddCommand(tokensApproveCmd)
+	tokensCmd.AddCommand(tokensRevokeCmd)
+	adminCmd.AddCommand(tokensCmd)
+}
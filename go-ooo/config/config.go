@@ -15,13 +15,34 @@ type JobsConfig struct {
 }
 
 type ServeConfig struct {
-	Host string `mapstructure:"host"`
-	Port string `mapstructure:"port"`
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	TlsCert  string `mapstructure:"tls_cert"`
+	TlsKey   string `mapstructure:"tls_key"`
+	ClientCa string `mapstructure:"client_ca"`
 }
 
+const (
+	KeystoreTypeLegacy = "legacy"
+	KeystoreTypeV3     = "v3"
+)
+
 type KeystoreConfig struct {
-	File    string `mapstructure:"file"`
-	Account string `mapstructure:"account"`
+	Type            string `mapstructure:"type"`
+	File            string `mapstructure:"file"`
+	Account         string `mapstructure:"account"`
+	RetiringAccount string `mapstructure:"retiring_account"` // previous provider account after a key rotation
+}
+
+const (
+	SignerTypeKeystore = "keystore"
+	SignerTypeClef     = "clef"
+)
+
+type SignerConfig struct {
+	Type    string `mapstructure:"type"`
+	ClefUrl string `mapstructure:"clef_url"`
+	Address string `mapstructure:"address"`
 }
 
 type ChainConfig struct {
@@ -70,6 +91,74 @@ type DexConfig struct {
 	MinTxCount    uint64 `mapstructure:"min_tx_count"`
 }
 
+type AdhocGuardsConfig struct {
+	MinPools           uint64  `mapstructure:"min_pools"`
+	MinDexs            uint64  `mapstructure:"min_dexs"`
+	MaxDispersion      float64 `mapstructure:"max_dispersion"`
+	MaxDeviation       float64 `mapstructure:"max_deviation"`
+	DeviationWindowMin uint64  `mapstructure:"deviation_window_mins"`
+}
+
+// SourcePolicyConfig defines the price sources for a pair. Order lists the sources to try, e.g.
+// finchains then dex. If CrossCheck is set, the first two sources are both queried, and
+// the price is refused if they diverge by more than MaxDivergence, or if either source fails
+// and AllowSingleSource is not set
+type SourcePolicyConfig struct {
+	Order             []string `mapstructure:"order"`
+	CrossCheck        bool     `mapstructure:"cross_check"`
+	MaxDivergence     float64  `mapstructure:"max_divergence"`
+	AllowSingleSource bool     `mapstructure:"allow_single_source"`
+}
+
+type SourcesConfig struct {
+	Default SourcePolicyConfig `mapstructure:"default"`
+	// per pair overrides, keyed by BASE-TARGET, e.g. ETH-USDT
+	Pairs map[string]SourcePolicyConfig `mapstructure:"pairs"`
+	// Finchains symbols mapped to the symbols used for the same asset on the DEXs, e.g. ETH = WETH
+	DexSymbols map[string]string `mapstructure:"dex_symbols"`
+}
+
+const (
+	FeeAdjustModeNotify = "notify"
+	FeeAdjustModeApply  = "apply"
+)
+
+// FeeAdjustConfig configures the scheduled adjustment of the provider's global fee. The
+// break-even fee is calculated from the mean gas cost of the last Window fulfilments and the
+// current xFUND price, and TargetMargin is added. The fee is only changed if the new fee
+// differs from the current fee by more than Hysteresis. In notify mode, the new fee is
+// logged but not set
+type FeeAdjustConfig struct {
+	Enabled      bool    `mapstructure:"enabled"`
+	Mode         string  `mapstructure:"mode"`
+	IntervalMins uint64  `mapstructure:"interval_mins"`
+	Window       int     `mapstructure:"window"`
+	MinSamples   int     `mapstructure:"min_samples"`
+	TargetMargin float64 `mapstructure:"target_margin"`
+	Hysteresis   float64 `mapstructure:"hysteresis"`
+	MinFee       uint64  `mapstructure:"min_fee"`
+	MaxFee       uint64  `mapstructure:"max_fee"`
+}
+
+const (
+	XfundSourceDex       = "dex"
+	XfundSourceCoinGecko = "coingecko"
+	XfundSourceStatic    = "static"
+)
+
+// XfundPriceConfig configures how the xFUND/ETH price used by analytics and fee adjustment is
+// obtained. Sources are tried in order until one returns a price. The dex source uses the
+// DEX engine's DexBase/DexTarget pair, averaged over DexMinutes. Prices are cached for
+// CacheMins, and recorded each time they are fetched
+type XfundPriceConfig struct {
+	Sources     []string `mapstructure:"sources"`
+	DexBase     string   `mapstructure:"dex_base"`
+	DexTarget   string   `mapstructure:"dex_target"`
+	DexMinutes  uint64   `mapstructure:"dex_minutes"`
+	StaticPrice float64  `mapstructure:"static_price"`
+	CacheMins   uint64   `mapstructure:"cache_mins"`
+}
+
 type DexList struct {
 	BscPancakeswapV3      DexConfig `mapstructure:"bsc_pancakeswap_v3"`
 	EthShibaswap          DexConfig `mapstructure:"eth_shibaswap"`
@@ -81,16 +170,21 @@ type DexList struct {
 }
 
 type Config struct {
-	Jobs       JobsConfig       `mapstructure:"jobs"`
-	Serve      ServeConfig      `mapstructure:"serve"`
-	Keystore   KeystoreConfig   `mapstructure:"keystorage"`
-	Chain      ChainConfig      `mapstructure:"chain"`
-	Database   DatabaseConfig   `mapstructure:"database"`
-	Prometheus PrometheusConfig `mapstructure:"prometheus"`
-	Log        LogConfig        `mapstructure:"log"`
-	Subchain   SubchainConfig   `mapstructure:"subchain"`
-	ApiKeys    ApiKeysConfig    `mapstructure:"api_keys"`
-	Dexs       DexList          `mapstructure:"dexs"`
+	Jobs       JobsConfig        `mapstructure:"jobs"`
+	Serve      ServeConfig       `mapstructure:"serve"`
+	Keystore   KeystoreConfig    `mapstructure:"keystorage"`
+	Signer     SignerConfig      `mapstructure:"signer"`
+	Chain      ChainConfig       `mapstructure:"chain"`
+	Database   DatabaseConfig    `mapstructure:"database"`
+	Prometheus PrometheusConfig  `mapstructure:"prometheus"`
+	Log        LogConfig         `mapstructure:"log"`
+	Subchain   SubchainConfig    `mapstructure:"subchain"`
+	ApiKeys    ApiKeysConfig     `mapstructure:"api_keys"`
+	Dexs       DexList           `mapstructure:"dexs"`
+	Guards     AdhocGuardsConfig `mapstructure:"adhoc_guards"`
+	Sources    SourcesConfig     `mapstructure:"sources"`
+	FeeAdjust  FeeAdjustConfig   `mapstructure:"fee_adjust"`
+	XfundPrice XfundPriceConfig  `mapstructure:"xfund_price"`
 }
 
 // DefaultConfig returns server's default configuration.
@@ -102,12 +196,22 @@ func DefaultConfig() *Config {
 			WaitConfirmations: 1,
 		},
 		Serve: ServeConfig{
-			Host: "127.0.0.1",
-			Port: "8445",
+			Host:     "127.0.0.1",
+			Port:     "8445",
+			TlsCert:  "",
+			TlsKey:   "",
+			ClientCa: "",
 		},
 		Keystore: KeystoreConfig{
-			File:    "",
-			Account: "",
+			Type:            KeystoreTypeLegacy,
+			File:            "",
+			Account:         "",
+			RetiringAccount: "",
+		},
+		Signer: SignerConfig{
+			Type:    SignerTypeKeystore,
+			ClefUrl: "",
+			Address: "",
 		},
 		Chain: ChainConfig{
 			GasLimit:        500000,
@@ -174,6 +278,46 @@ func DefaultConfig() *Config {
 				MinTxCount:    oooapidextypes.DefaultMinTxCount,
 			},
 		},
+		Guards: AdhocGuardsConfig{
+			MinPools:           1,
+			MinDexs:            1,
+			MaxDispersion:      0.2,
+			MaxDeviation:       0.25,
+			DeviationWindowMin: 60,
+		},
+		Sources: SourcesConfig{
+			Default: SourcePolicyConfig{
+				Order:             []string{"finchains"},
+				CrossCheck:        false,
+				MaxDivergence:     0.05,
+				AllowSingleSource: false,
+			},
+			Pairs: map[string]SourcePolicyConfig{},
+			DexSymbols: map[string]string{
+				"btc": "WBTC",
+				"eth": "WETH",
+				"usd": "USDC",
+			},
+		},
+		FeeAdjust: FeeAdjustConfig{
+			Enabled:      false,
+			Mode:         FeeAdjustModeNotify,
+			IntervalMins: 360,
+			Window:       100,
+			MinSamples:   10,
+			TargetMargin: 0.2,
+			Hysteresis:   0.1,
+			MinFee:       0,
+			MaxFee:       0,
+		},
+		XfundPrice: XfundPriceConfig{
+			Sources:     []string{XfundSourceDex, XfundSourceCoinGecko},
+			DexBase:     "xFUND",
+			DexTarget:   "WETH",
+			DexMinutes:  60,
+			StaticPrice: 0,
+			CacheMins:   10,
+		},
 	}
 }
 
@@ -268,6 +412,14 @@ func (c *Config) SetKeystore(path, account string) {
 	c.Keystore.Account = account
 }
 
+func (c *Config) SetRetiringAccount(account string) {
+	c.Keystore.RetiringAccount = account
+}
+
+func (c *Config) SetKeystoreType(ksType string) {
+	c.Keystore.Type = ksType
+}
+
 func (c *Config) SetSqliteDb(path string) {
 	c.Database.Storage = path
 }
@@ -338,6 +490,52 @@ func (c Config) ValidateBasic() error {
 		return errors.New("jobs.ooo_api_url not set in config.toml")
 	}
 
+	if c.FeeAdjust.Enabled {
+		if c.FeeAdjust.Mode != FeeAdjustModeNotify && c.FeeAdjust.Mode != FeeAdjustModeApply {
+			return errors.New(fmt.Sprintf("unknown fee_adjust.mode %s in config.toml", c.FeeAdjust.Mode))
+		}
+		if c.FeeAdjust.IntervalMins == 0 {
+			return errors.New("fee_adjust.interval_mins not set in config.toml")
+		}
+		if c.FeeAdjust.Window <= 0 {
+			return errors.New("fee_adjust.window not set in config.toml")
+		}
+		if c.FeeAdjust.TargetMargin < 0 || c.FeeAdjust.Hysteresis < 0 {
+			return errors.New("fee_adjust.target_margin and fee_adjust.hysteresis cannot be negative")
+		}
+		if c.FeeAdjust.MaxFee > 0 && c.FeeAdjust.MaxFee < c.FeeAdjust.MinFee {
+			return errors.New("fee_adjust.max_fee is less than fee_adjust.min_fee in config.toml")
+		}
+	}
+
+	for _, source := range c.XfundPrice.Sources {
+		if source != XfundSourceDex && source != XfundSourceCoinGecko && source != XfundSourceStatic {
+			return errors.New(fmt.Sprintf("unknown xfund_price.sources %s in config.toml", source))
+		}
+		if source == XfundSourceStatic && c.XfundPrice.StaticPrice <= 0 {
+			return errors.New("static selected in xfund_price.sources but xfund_price.static_price not set in config.toml")
+		}
+	}
+
+	if c.Signer.Type == SignerTypeClef {
+		if c.Signer.ClefUrl == "" {
+			return errors.New("clef selected as signer.type but signer.clef_url not set in config.toml")
+		}
+		// requests sent to the retiring address would never be fulfilled
+		if c.Keystore.RetiringAccount != "" {
+			return errors.New("keystorage.retiring_account is set, but retiring accounts are only served by the keystore " +
+				"signer. Run 'go-ooo rotate-provider finish' or set signer.type = \"keystore\" in config.toml")
+		}
+		// keys are held by the external signer
+		return nil
+	}
+	if c.Signer.Type != SignerTypeKeystore {
+		return errors.New(fmt.Sprintf("unknown signer.type %s in config.toml", c.Signer.Type))
+	}
+
+	if c.Keystore.Type != KeystoreTypeLegacy && c.Keystore.Type != KeystoreTypeV3 {
+		return errors.New(fmt.Sprintf("unknown keystorage.type %s in config.toml", c.Keystore.Type))
+	}
 	if c.Keystore.Account == "" {
 		return errors.New("keystorage.account not set in config.toml")
 	}
@@ -80,11 +80,36 @@ wait_confirmations = {{ .Jobs.WaitConfirmations }}
 ## Keystore                             ##
 ##########################################
 
-# Path to keystore, and account name to use
+# Keystore type, path to keystore, and account to use.
+# type "v3": file is a directory of standard Ethereum V3 keystore files,
+#            e.g. a geth keystore, and account is the account address
+# type "legacy": file is a go-ooo keystore.json, and account is the account name.
+#            Migrate with "go-ooo keys migrate"
+# retiring_account is set by "go-ooo rotate-provider". Requests sent to the previous
+# provider account continue to be fulfilled until "go-ooo rotate-provider finish" is run
 
 [keystorage]
+type = "{{ .Keystore.Type }}"
 account = "{{ .Keystore.Account }}"
 file = "{{ .Keystore.File }}"
+retiring_account = "{{ .Keystore.RetiringAccount }}"
+
+##########################################
+## Signer                               ##
+##########################################
+
+# type "keystore": sign using the key decrypted from the keystore
+# type "clef": sign using an external signer speaking Clef's external API. The
+#              private key is not held by go-ooo, and the keystore is not used.
+#              clef_url is the signer's IPC path or HTTP URL, and address is the
+#              provider address. If address is empty, the signer's first account is used.
+#              The HTTP API can only be accessed using tokens created with
+#              "go-ooo api-tokens create"
+
+[signer]
+type = "{{ .Signer.Type }}"
+clef_url = "{{ .Signer.ClefUrl }}"
+address = "{{ .Signer.Address }}"
 
 ##########################################
 ## Logs                                 ##
@@ -110,9 +135,16 @@ port = "{{ .Prometheus.Port }}"
 # Host and port on which to listen for Admin commands
 # for example, query the db, update your provider fee on-chain etc.
 
+# If tls_cert and tls_key are set, the HTTP API is served over TLS using the
+# PEM encoded certificate and key. If client_ca is also set, clients must present
+# a certificate signed by one of the CAs in the PEM encoded bundle (mutual TLS)
+
 [serve]
 host = "{{ .Serve.Host }}"
 port = "{{ .Serve.Port }}"
+tls_cert = "{{ .Serve.TlsCert }}"
+tls_key = "{{ .Serve.TlsKey }}"
+client_ca = "{{ .Serve.ClientCa }}"
 
 ##########################################
 ## Subchain                             ##
@@ -176,6 +208,116 @@ min_tx_count = "{{ .Dexs.PolygonPosQuickswapV3.MinTxCount }}"
 min_reserve_usd = "{{ .Dexs.XdaiHoneyswap.MinReserveUsd }}"
 min_tx_count = "{{ .Dexs.XdaiHoneyswap.MinTxCount }}"
 
+##########################################
+## AdHoc Price Guards                   ##
+##########################################
+
+# Guards against price manipulation for AdHoc DEX prices. A price
+# that fails any guard is rejected, and the request is not fulfilled.
+
+[adhoc_guards]
+# Minimum number of distinct pools and DEXs that must return prices
+min_pools = {{ .Guards.MinPools }}
+min_dexs = {{ .Guards.MinDexs }}
+
+# Maximum dispersion (standard deviation / mean) of the mean prices
+# returned by each DEX, after outliers are removed, e.g. 0.2 = 20%.
+# 0 to disable
+max_dispersion = {{ .Guards.MaxDispersion }}
+
+# Maximum deviation from the last price served for the same pair,
+# e.g. 0.25 = 25%. 0 to disable. Only checked if the last price was
+# served within deviation_window_mins. A window of 0 has no time limit
+max_deviation = {{ .Guards.MaxDeviation }}
+deviation_window_mins = {{ .Guards.DeviationWindowMin }}
+
+##########################################
+## Price Sources                        ##
+##########################################
+
+# Source policy for standard (non-AdHoc) price requests. Sources are
+# tried in order until one returns a price. Valid sources are
+# "finchains" and "dex". The dex source is only used if the pair
+# exists on a supported DEX, and only for PR queries with a window of
+# up to 1H, e.g. PR.AVG.30M. Longer windows are refused by the dex source.
+#
+# If cross_check is true, the first two sources are both queried, and
+# the request is refused if their prices diverge by more than
+# max_divergence, e.g. 0.05 = 5%. The request is also refused if either
+# source fails, unless allow_single_source is true, in which case the
+# price from the remaining source is used without cross-checking.
+
+[sources.default]
+order = [{{ range $i, $s := .Sources.Default.Order }}{{ if $i }}, {{ end }}"{{ $s }}"{{ end }}]
+cross_check = {{ .Sources.Default.CrossCheck }}
+max_divergence = {{ .Sources.Default.MaxDivergence }}
+allow_single_source = {{ .Sources.Default.AllowSingleSource }}
+
+# Per pair overrides, keyed by BASE-TARGET, for example:
+#
+# [sources.pairs.ETH-USDT]
+# order = ["finchains", "dex"]
+# cross_check = true
+# max_divergence = 0.02
+# allow_single_source = false
+
+# Finchains symbols are mapped to the symbols used on the DEXs when
+# querying the dex source, e.g. ETH-USD is queried as WETH-USDC.
+# Symbols not listed are queried unchanged.
+
+[sources.dex_symbols]
+{{ range $k, $v := .Sources.DexSymbols }}{{ $k }} = "{{ $v }}"
+{{ end }}
+##########################################
+## Automatic Fee Adjustment             ##
+##########################################
+
+# Every interval_mins, the break-even fee is calculated from the mean
+# gas cost of the last "window" successful fulfilments and the current
+# xFUND price. target_margin is added, e.g. 0.2 = 20%, and the result
+# is bounded by min_fee and max_fee (0 for no bound). Fees are in the
+# lowest xFUND denomination, e.g. 10000000 = 0.01 xFUND.
+#
+# The global fee is only changed if the new fee differs from the
+# current fee by more than hysteresis, e.g. 0.1 = 10%. In "notify"
+# mode, the new fee is logged but not set. Set mode = "apply" to send
+# the set_fee Tx. Fee changes are recorded in the admin audit log.
+
+[fee_adjust]
+enabled = {{ .FeeAdjust.Enabled }}
+mode = "{{ .FeeAdjust.Mode }}"
+interval_mins = {{ .FeeAdjust.IntervalMins }}
+window = {{ .FeeAdjust.Window }}
+min_samples = {{ .FeeAdjust.MinSamples }}
+target_margin = {{ .FeeAdjust.TargetMargin }}
+hysteresis = {{ .FeeAdjust.Hysteresis }}
+min_fee = {{ .FeeAdjust.MinFee }}
+max_fee = {{ .FeeAdjust.MaxFee }}
+
+##########################################
+## xFUND Price                          ##
+##########################################
+
+# The xFUND/ETH price used by analytics and fee adjustment. Sources
+# are tried in order until one returns a price. Valid sources are:
+#
+#   dex       - the DEX engine's dex_base/dex_target pair, averaged
+#               over dex_minutes
+#   coingecko - the CoinGecko API
+#   static    - static_price, in ETH
+#
+# Prices are cached for cache_mins, and recorded each time they are
+# fetched, so that profit/loss is calculated using the price at the
+# time of each fulfilment.
+
+[xfund_price]
+sources = [{{ range $i, $s := .XfundPrice.Sources }}{{ if $i }}, {{ end }}"{{ $s }}"{{ end }}]
+dex_base = "{{ .XfundPrice.DexBase }}"
+dex_target = "{{ .XfundPrice.DexTarget }}"
+dex_minutes = {{ .XfundPrice.DexMinutes }}
+static_price = {{ .XfundPrice.StaticPrice }}
+cache_mins = {{ .XfundPrice.CacheMins }}
+
 `
 
 var configTemplate *template.Template
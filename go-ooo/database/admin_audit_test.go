@@ -0,0 +1,63 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/database/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDb(t *testing.T) *database.DB {
+	cfg := config.DefaultConfig()
+	cfg.Database.Dialect = "sqlite"
+	cfg.Database.Storage = filepath.Join(t.TempDir(), "go-ooo.sqlite")
+
+	db, err := database.NewDb(cfg)
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+
+	return db
+}
+
+func TestAdminAudit(t *testing.T) {
+	db := newTestDb(t)
+
+	require.NoError(t, db.InsertAdminAudit(models.AdminAudit{TokenId: "default", Task: "query_fees", Success: true}))
+	require.NoError(t, db.InsertAdminAudit(models.AdminAudit{
+		TokenId:  "ops",
+		Task:     "withdraw",
+		Success:  true,
+		TxHash:   "0x01",
+		TxStatus: models.AUDIT_TX_STATUS_PENDING,
+	}))
+
+	entries, err := db.GetAdminAudit(database.AdminAuditFilter{Task: "withdraw"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "ops", entries[0].GetTokenId())
+
+	pending, err := db.GetAdminAuditPendingTxs()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	// outcome is only set once
+	require.NoError(t, db.UpdateAdminAuditTxOutcome(pending[0].GetId(), models.AUDIT_TX_STATUS_CONFIRMED, 10, 21000))
+	require.NoError(t, db.UpdateAdminAuditTxOutcome(pending[0].GetId(), models.AUDIT_TX_STATUS_FAILED, 11, 1))
+
+	entries, err = db.GetAdminAudit(database.AdminAuditFilter{TokenId: "ops"})
+	require.NoError(t, err)
+	require.Equal(t, models.AUDIT_TX_STATUS_CONFIRMED, entries[0].GetTxStatus())
+	require.Equal(t, uint64(10), entries[0].GetTxBlockNumber())
+	require.Equal(t, uint64(21000), entries[0].GetTxGasUsed())
+
+	// entries cannot be deleted
+	require.Error(t, db.Delete(&entries[0]).Error)
+
+	entries, err = db.GetAdminAudit(database.AdminAuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
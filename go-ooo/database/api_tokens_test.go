@@ -0,0 +1,47 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"go-ooo/apitoken"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiTokens(t *testing.T) {
+	db := newTestDb(t)
+
+	token, hash, err := apitoken.Generate()
+	require.NoError(t, err)
+	require.NoError(t, db.InsertApiToken("grafana", hash, []string{apitoken.ScopeRead, apitoken.ScopeAnalytics}, nil))
+
+	expired := time.Now().Add(-time.Minute)
+	_, expiredHash, err := apitoken.Generate()
+	require.NoError(t, err)
+	require.NoError(t, db.InsertApiToken("old", expiredHash, []string{apitoken.ScopeWithdraw}, &expired))
+
+	// names are unique
+	require.Error(t, db.InsertApiToken("grafana", "abc", []string{apitoken.ScopeRead}, nil))
+
+	found, err := db.FindApiTokenByHash(apitoken.Hash(token))
+	require.NoError(t, err)
+	require.Equal(t, "grafana", found.GetName())
+	require.Equal(t, []string{apitoken.ScopeRead, apitoken.ScopeAnalytics}, found.GetScopes())
+	require.True(t, found.IsActive(time.Now()))
+
+	old, err := db.FindApiTokenByName("old")
+	require.NoError(t, err)
+	require.False(t, old.IsActive(time.Now()))
+	require.Equal(t, "EXPIRED", old.GetStatusString(time.Now()))
+
+	require.NoError(t, db.RevokeApiToken("grafana"))
+	found, err = db.FindApiTokenByHash(apitoken.Hash(token))
+	require.NoError(t, err)
+	require.False(t, found.IsActive(time.Now()))
+	require.Equal(t, "REVOKED", found.GetStatusString(time.Now()))
+
+	tokens, err := db.GetApiTokens()
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+}
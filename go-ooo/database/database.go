@@ -81,6 +81,11 @@ func (d *DB) Migrate() (err error) {
 		&models.DexPairs{},
 		&models.TokenContracts{},
 		&models.VersionInfo{},
+		&models.PriceProvenance{},
+		&models.ShadowFulfilments{},
+		&models.AdminAudit{},
+		&models.ApiTokens{},
+		&models.XfundPrices{},
 	)
 
 	// post-model data migration
@@ -90,5 +95,7 @@ func (d *DB) Migrate() (err error) {
 
 	d.MigrateV2ToV3()
 
+	d.MigrateV3ToV4()
+
 	return
 }
@@ -0,0 +1,82 @@
+package database_test
+
+import (
+	"strings"
+	"testing"
+
+	"go-ooo/database/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplacedFulfilmentRecorded(t *testing.T) {
+	db := newTestDb(t)
+
+	require.NoError(t, db.Create(&models.DataRequests{RequestId: "01", Consumer: "0xa"}).Error)
+
+	require.NoError(t, db.UpdateFulfillmentSent("01", "0xaa", 1, 10))
+	require.NoError(t, db.UpdateFulfillmentSent("01", "0xaa", 1, 10))
+
+	failed, err := db.GetFailedFulfilments("01")
+	require.NoError(t, err)
+	require.Len(t, failed, 0)
+
+	// the reverted Tx is already recorded, so is not recorded again when resent
+	require.NoError(t, db.InsertNewFailedFulfilment("01", "0xaa", 21000, 10, "tx reverted"))
+	require.NoError(t, db.UpdateFulfillmentSent("01", "0xbb", 2, 12))
+
+	// a Tx sent later may be replaced by an earlier one being mined
+	require.NoError(t, db.UpdateFulfillmentSent("01", "0xcc", 2, 14))
+	require.NoError(t, db.UpdateFulfillmentSuccess("01", 15, "0xbb", 30000, 20))
+
+	// 0xbb was recorded as replaced, but is the successful fulfilment
+	failed, err = db.GetFailedFulfilments("01")
+	require.NoError(t, err)
+	require.Len(t, failed, 2)
+	require.Equal(t, "0xaa", failed[0].GetTxHash())
+	require.Equal(t, "0xcc", failed[1].GetTxHash())
+	require.Equal(t, models.FAIL_REASON_TX_REPLACED, failed[1].GetFailReason())
+
+	require.NoError(t, db.UpdateFailedFulfilmentGas(failed[1].GetId(), 25000, 30))
+	failed, err = db.GetFailedFulfilments("01")
+	require.NoError(t, err)
+	require.Equal(t, uint64(25000), failed[1].GetGasUsed())
+	require.Equal(t, uint64(30), failed[1].GetGasPrice())
+}
+
+func TestFindByRequestIdNormalised(t *testing.T) {
+	db := newTestDb(t)
+
+	requestId := "5c1a4b0e6f2a7d9d0e5b1d3c9a8f7e6d5c4b3a291807f6e5d4c3b2a190807f6e"
+	require.NoError(t, db.Create(&models.DataRequests{RequestId: requestId, Consumer: "0xa"}).Error)
+
+	for _, id := range []string{requestId, "0x" + requestId, "0X" + strings.ToUpper(requestId), " 0x" + requestId + " "} {
+		req, err := db.FindByRequestId(id)
+		require.NoError(t, err, id)
+		require.Equal(t, requestId, req.GetRequestId())
+	}
+
+	requests, err := db.GetRequestsByRequestIds([]string{"0x" + strings.ToUpper(requestId)})
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+
+	require.NoError(t, db.UpdateRequestStatus("0x"+requestId, models.REQUEST_STATUS_ABANDONED, "test"))
+	req, err := db.FindByRequestId(requestId)
+	require.NoError(t, err)
+	require.Equal(t, models.REQUEST_STATUS_ABANDONED, req.GetRequestStatus())
+}
+
+func TestShadowFulfilmentNormalised(t *testing.T) {
+	db := newTestDb(t)
+
+	require.NoError(t, db.UpdateShadowOnChainResult("0xAB01", "0xa", "100", "0xaa", 10))
+	require.NoError(t, db.InsertShadowFulfilment(models.ShadowFulfilments{RequestId: "0xab01", Price: "101"}))
+	require.NoError(t, db.UpdateShadowOnChainResult("AB01", "0xa", "100", "0xaa", 10))
+
+	shadows, err := db.GetShadowFulfilments()
+	require.NoError(t, err)
+	require.Len(t, shadows, 1)
+	require.Equal(t, "ab01", shadows[0].GetRequestId())
+	require.Equal(t, "101", shadows[0].Price)
+	require.Equal(t, "100", shadows[0].OnChainPrice)
+}
@@ -41,6 +41,21 @@ func (d *DB) MigrateV2ToV3() {
 	}
 }
 
+// MigrateV3ToV4 approves existing tokens as canonical where there is no symbol collision
+// on the chain, and re-verifies all pairs. Collisions are left for the operator to review
+func (d *DB) MigrateV3ToV4() {
+	dbVers, _ := d.getCurrentDbSchemaVersion()
+	if dbVers.CurrentVersion == 3 {
+		d.Exec(`UPDATE token_contracts SET canonical = ? WHERE id IN (
+			SELECT MIN(id) FROM token_contracts WHERE deleted_at IS NULL
+			GROUP BY chain, token_symbol HAVING COUNT(*) = 1)`, true)
+
+		_ = d.ReverifyAllDexPairs()
+
+		_ = d.setDbSchemaVersion(4)
+	}
+}
+
 // DeleteAdhocTokenData is used when migrating from Db v0 to v1
 func (d *DB) DeleteAdhocTokenData() {
 	if d.Migrator().HasTable("dex_pairs") {
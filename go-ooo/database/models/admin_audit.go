@@ -0,0 +1,96 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AUDIT_TX_STATUS_NONE      = iota // no Tx sent by the task
+	AUDIT_TX_STATUS_PENDING          // Tx sent, waiting for confirmation
+	AUDIT_TX_STATUS_CONFIRMED        // Tx confirmed successful on chain
+	AUDIT_TX_STATUS_FAILED           // Tx reverted on chain
+)
+
+// AdminAudit records each admin task submitted to the service, who submitted it, and the
+// outcome. Entries are append-only - the only update is the on-chain outcome of any Tx sent
+// by the task, which is set once the Tx is confirmed
+type AdminAudit struct {
+	gorm.Model
+	TokenId       string `gorm:"index"`
+	Task          string `gorm:"index"`
+	Params        string // JSON encoded task parameters
+	Success       bool
+	Result        string
+	Error         string
+	TxHash        string `gorm:"index"`
+	TxStatus      int    `gorm:"index"`
+	TxBlockNumber uint64
+	TxGasUsed     uint64
+}
+
+func (AdminAudit) TableName() string {
+	return "admin_audit"
+}
+
+// BeforeDelete prevents audit entries from being deleted
+func (AdminAudit) BeforeDelete(tx *gorm.DB) error {
+	return errors.New("admin audit entries cannot be deleted")
+}
+
+func (a AdminAudit) GetId() uint {
+	return a.ID
+}
+
+func (a AdminAudit) GetTokenId() string {
+	return a.TokenId
+}
+
+func (a AdminAudit) GetTask() string {
+	return a.Task
+}
+
+func (a AdminAudit) GetParams() string {
+	return a.Params
+}
+
+func (a AdminAudit) GetSuccess() bool {
+	return a.Success
+}
+
+func (a AdminAudit) GetResult() string {
+	return a.Result
+}
+
+func (a AdminAudit) GetError() string {
+	return a.Error
+}
+
+func (a AdminAudit) GetTxHash() string {
+	return a.TxHash
+}
+
+func (a AdminAudit) GetTxStatus() int {
+	return a.TxStatus
+}
+
+func (a AdminAudit) GetTxBlockNumber() uint64 {
+	return a.TxBlockNumber
+}
+
+func (a AdminAudit) GetTxGasUsed() uint64 {
+	return a.TxGasUsed
+}
+
+func (a AdminAudit) GetTxStatusString() string {
+	switch a.TxStatus {
+	case AUDIT_TX_STATUS_PENDING:
+		return "PENDING"
+	case AUDIT_TX_STATUS_CONFIRMED:
+		return "CONFIRMED"
+	case AUDIT_TX_STATUS_FAILED:
+		return "FAILED"
+	}
+	return "NONE"
+}
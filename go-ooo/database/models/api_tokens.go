@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApiTokens are named tokens used to authenticate with the service's HTTP API. Only the
+// SHA-256 hash of the token is stored
+type ApiTokens struct {
+	gorm.Model
+	Name       string `gorm:"uniqueIndex"`
+	TokenHash  string `gorm:"uniqueIndex"`
+	Scopes     string // comma separated list of scopes
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+func (ApiTokens) TableName() string {
+	return "api_tokens"
+}
+
+func (a ApiTokens) GetId() uint {
+	return a.ID
+}
+
+func (a ApiTokens) GetName() string {
+	return a.Name
+}
+
+func (a ApiTokens) GetScopes() []string {
+	if a.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(a.Scopes, ",")
+}
+
+func (a ApiTokens) IsRevoked() bool {
+	return a.RevokedAt != nil
+}
+
+func (a ApiTokens) IsExpired(now time.Time) bool {
+	return a.ExpiresAt != nil && !now.Before(*a.ExpiresAt)
+}
+
+// IsActive returns true if the token has not been revoked and has not expired
+func (a ApiTokens) IsActive(now time.Time) bool {
+	return !a.IsRevoked() && !a.IsExpired(now)
+}
+
+func (a ApiTokens) GetStatusString(now time.Time) string {
+	if a.IsRevoked() {
+		return "REVOKED"
+	}
+	if a.IsExpired(now) {
+		return "EXPIRED"
+	}
+	return "ACTIVE"
+}
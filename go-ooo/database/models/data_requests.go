@@ -1,6 +1,13 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
 
 const (
 	REQUEST_STATUS_UNKNOWN            = iota // Saywhatnow?
@@ -12,6 +19,9 @@ const (
 	REQUEST_STATUS_TX_FAILED                 // Fulfilment Tx failed and not broadcast
 	REQUEST_STATUS_SUCCESS                   // Fulfilment Tx successful and confirmed in RandomnessRequestFulfilled event
 	REQUEST_STATUS_FULFILMENT_FAILED         // Fulfilment failed - too many failed attempts.
+	REQUEST_STATUS_PRICE_REJECTED            // AdHoc price rejected by manipulation guards
+	REQUEST_STATUS_DRY_RUN                   // Price fetched and recorded in shadow table. No Tx sent (dry-run mode)
+	REQUEST_STATUS_ABANDONED                 // Abandoned by the operator via an admin task
 )
 
 const (
@@ -36,9 +46,11 @@ type DataRequests struct {
 	Endpoint                    string
 	EndpointDecoded             string
 	PriceResult                 string
+	PriceSource                 string
 	LastFulfillSentBlockNumber  uint64 `gorm:"index"`
 	FulfillConfirmedBlockNumber uint64 `gorm:"index"`
 	FulfillTxHash               string `gorm:"index"`
+	FulfillTxNonce              uint64 // 0 if not known
 	FulfillGasUsed              uint64
 	FulfillGasPrice             uint64
 	FulfillmentAttempts         uint64 `gorm:"default:0"`
@@ -99,6 +111,10 @@ func (d *DataRequests) GetPriceResult() string {
 	return d.PriceResult
 }
 
+func (d *DataRequests) GetPriceSource() string {
+	return d.PriceSource
+}
+
 func (d *DataRequests) GetEndpoint() string {
 	return d.Endpoint
 }
@@ -119,6 +135,10 @@ func (d *DataRequests) GetFulfillTxHash() string {
 	return d.FulfillTxHash
 }
 
+func (d *DataRequests) GetFulfillTxNonce() uint64 {
+	return d.FulfillTxNonce
+}
+
 func (d *DataRequests) GetFulfillGasUsed() uint64 {
 	return d.FulfillGasUsed
 }
@@ -160,6 +180,12 @@ func (d *DataRequests) GetRequestStatusString() string {
 		return "SUCCESS"
 	case REQUEST_STATUS_FULFILMENT_FAILED:
 		return "FULFILMENT FAILED"
+	case REQUEST_STATUS_PRICE_REJECTED:
+		return "PRICE REJECTED"
+	case REQUEST_STATUS_DRY_RUN:
+		return "DRY RUN"
+	case REQUEST_STATUS_ABANDONED:
+		return "ABANDONED"
 	}
 
 	return "UNKNOWN"
@@ -180,3 +206,24 @@ func (d *DataRequests) GetJobStatusString() string {
 func (d *DataRequests) GetStatusReason() string {
 	return d.StatusReason
 }
+
+// RequestStatusFromString returns the request status for either the numeric status, or the
+// status name returned by GetRequestStatusString, e.g. "FULFILMENT FAILED" or fulfilment_failed
+func RequestStatusFromString(status string) (int, error) {
+	if i, err := strconv.Atoi(status); err == nil {
+		if i < REQUEST_STATUS_UNKNOWN || i > REQUEST_STATUS_ABANDONED {
+			return 0, errors.New(fmt.Sprintf("unknown request status %d", i))
+		}
+		return i, nil
+	}
+
+	name := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(status), "_", " "))
+	for i := REQUEST_STATUS_UNKNOWN; i <= REQUEST_STATUS_ABANDONED; i++ {
+		req := DataRequests{RequestStatus: i}
+		if req.GetRequestStatusString() == name {
+			return i, nil
+		}
+	}
+
+	return 0, errors.New(fmt.Sprintf("unknown request status %s", status))
+}
@@ -2,6 +2,10 @@ package models
 
 import "gorm.io/gorm"
 
+// FAIL_REASON_TX_REPLACED is recorded for a fulfilment Tx which was superseded by a later Tx for
+// the same request. If it was mined, it still cost gas
+const FAIL_REASON_TX_REPLACED = "tx replaced"
+
 type FailedFulfilment struct {
 	gorm.Model
 	RequestId  string `gorm:"index"`
@@ -9,6 +13,7 @@ type FailedFulfilment struct {
 	GasUsed    uint64
 	GasPrice   uint64
 	FailReason string
+	NotMined   bool // the Tx has no receipt and will not be mined, so its receipt is no longer fetched
 }
 
 func (FailedFulfilment) TableName() string {
@@ -38,3 +43,7 @@ func (f FailedFulfilment) GetGasPrice() uint64 {
 func (f FailedFulfilment) GetFailReason() string {
 	return f.FailReason
 }
+
+func (f FailedFulfilment) GetNotMined() bool {
+	return f.NotMined
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PriceProvenance records how the price for a request was calculated. A request may have
+// more than one record, e.g. if the price was cross-checked against a second source, or
+// re-fetched after an error
+type PriceProvenance struct {
+	gorm.Model
+	RequestId     string `gorm:"index"`
+	Endpoint      string
+	Source        string // finchains or dex
+	Price         string // price in wei
+	Method        string // outlier removal method, e.g. chauvenet
+	DMax          float64
+	Window        string // e.g. 60M, 24H
+	WindowStart   time.Time
+	WindowEnd     time.Time
+	RawPriceCount uint64
+	RemovedValues string // JSON array of values removed by the outlier filter
+	Sources       string // JSON array of exchanges/pools or Finchains queries used
+	Response      string // raw Finchains API response
+}
+
+func (PriceProvenance) TableName() string {
+	return "price_provenance"
+}
+
+func (p PriceProvenance) GetId() uint {
+	return p.ID
+}
+
+func (p PriceProvenance) GetRequestId() string {
+	return p.RequestId
+}
+
+func (p PriceProvenance) GetEndpoint() string {
+	return p.Endpoint
+}
+
+func (p PriceProvenance) GetSource() string {
+	return p.Source
+}
+
+func (p PriceProvenance) GetPrice() string {
+	return p.Price
+}
+
+func (p PriceProvenance) GetMethod() string {
+	return p.Method
+}
+
+func (p PriceProvenance) GetDMax() float64 {
+	return p.DMax
+}
+
+func (p PriceProvenance) GetWindow() string {
+	return p.Window
+}
+
+func (p PriceProvenance) GetWindowStart() time.Time {
+	return p.WindowStart
+}
+
+func (p PriceProvenance) GetWindowEnd() time.Time {
+	return p.WindowEnd
+}
+
+func (p PriceProvenance) GetRawPriceCount() uint64 {
+	return p.RawPriceCount
+}
+
+func (p PriceProvenance) GetRemovedValues() string {
+	return p.RemovedValues
+}
+
+func (p PriceProvenance) GetSources() string {
+	return p.Sources
+}
+
+func (p PriceProvenance) GetResponse() string {
+	return p.Response
+}
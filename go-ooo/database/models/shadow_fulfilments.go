@@ -0,0 +1,87 @@
+package models
+
+import "gorm.io/gorm"
+
+// ShadowFulfilments holds the prices and would-be fulfilment transactions from a node running
+// in dry-run mode, along with the result fulfilled on chain by the production node
+type ShadowFulfilments struct {
+	gorm.Model
+	RequestId          string `gorm:"uniqueIndex"`
+	Consumer           string `gorm:"index"`
+	EndpointDecoded    string
+	Price              string
+	PriceSource        string
+	BlockNumber        uint64
+	TxData             string // hex encoded fulfillRequest call data
+	Signature          string
+	Nonce              uint64
+	GasPrice           uint64
+	GasLimit           uint64
+	OnChainPrice       string
+	OnChainTxHash      string
+	OnChainBlockNumber uint64
+}
+
+func (ShadowFulfilments) TableName() string {
+	return "shadow_fulfilments"
+}
+
+func (s ShadowFulfilments) GetId() uint {
+	return s.ID
+}
+
+func (s ShadowFulfilments) GetRequestId() string {
+	return s.RequestId
+}
+
+func (s ShadowFulfilments) GetConsumer() string {
+	return s.Consumer
+}
+
+func (s ShadowFulfilments) GetEndpointDecoded() string {
+	return s.EndpointDecoded
+}
+
+func (s ShadowFulfilments) GetPrice() string {
+	return s.Price
+}
+
+func (s ShadowFulfilments) GetPriceSource() string {
+	return s.PriceSource
+}
+
+func (s ShadowFulfilments) GetBlockNumber() uint64 {
+	return s.BlockNumber
+}
+
+func (s ShadowFulfilments) GetTxData() string {
+	return s.TxData
+}
+
+func (s ShadowFulfilments) GetSignature() string {
+	return s.Signature
+}
+
+func (s ShadowFulfilments) GetNonce() uint64 {
+	return s.Nonce
+}
+
+func (s ShadowFulfilments) GetGasPrice() uint64 {
+	return s.GasPrice
+}
+
+func (s ShadowFulfilments) GetGasLimit() uint64 {
+	return s.GasLimit
+}
+
+func (s ShadowFulfilments) GetOnChainPrice() string {
+	return s.OnChainPrice
+}
+
+func (s ShadowFulfilments) GetOnChainTxHash() string {
+	return s.OnChainTxHash
+}
+
+func (s ShadowFulfilments) GetOnChainBlockNumber() uint64 {
+	return s.OnChainBlockNumber
+}
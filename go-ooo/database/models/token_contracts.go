@@ -7,6 +7,7 @@ type TokenContracts struct {
 	TokenSymbol     string `gorm:"index:idx_token_contracts_chain_symbol;index:idx_token_contracts_symbol_address;index:idx_token_contracts_symbol"`
 	ContractAddress string `gorm:"index:idx_token_contracts_symbol_address;index:idx_token_contracts_address"`
 	Chain           string `gorm:"index:idx_token_contracts_chain_symbol;index:idx_token_contracts_chain"`
+	Canonical       bool   `gorm:"index"` // approved as the canonical contract for the symbol on the chain
 }
 
 func (TokenContracts) TableName() string {
@@ -24,3 +25,7 @@ func (d *TokenContracts) GetContractAddress() string {
 func (d *TokenContracts) GetChain() string {
 	return d.Chain
 }
+
+func (d *TokenContracts) GetCanonical() bool {
+	return d.Canonical
+}
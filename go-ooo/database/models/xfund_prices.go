@@ -0,0 +1,29 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// XfundPrices records the xFUND/ETH price each time it is fetched, so that analytics can use
+// the price at the time of each fulfilment
+type XfundPrices struct {
+	gorm.Model
+	PriceEth float64
+	Source   string // dex, coingecko or static
+}
+
+func (XfundPrices) TableName() string {
+	return "xfund_prices"
+}
+
+func (x XfundPrices) GetId() uint {
+	return x.ID
+}
+
+func (x XfundPrices) GetPriceEth() float64 {
+	return x.PriceEth
+}
+
+func (x XfundPrices) GetSource() string {
+	return x.Source
+}
@@ -0,0 +1,55 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"go-ooo/database/models"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestProfitReportQueries(t *testing.T) {
+	db := newTestDb(t)
+
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	requests := []models.DataRequests{
+		{RequestId: "01", Consumer: "0xa", JobStatus: models.JOB_STATUS_SUCCESS, Model: gorm.Model{UpdatedAt: day}},
+		{RequestId: "02", Consumer: "0xb", JobStatus: models.JOB_STATUS_SUCCESS, Model: gorm.Model{UpdatedAt: day.Add(time.Hour)}},
+		{RequestId: "03", Consumer: "0xa", JobStatus: models.JOB_STATUS_FAIL, Model: gorm.Model{UpdatedAt: day}},
+		{RequestId: "04", Consumer: "0xa", JobStatus: models.JOB_STATUS_SUCCESS, Model: gorm.Model{UpdatedAt: day.AddDate(0, 0, 2)}},
+	}
+	for i := range requests {
+		require.NoError(t, db.Create(&requests[i]).Error)
+	}
+
+	failed := []models.FailedFulfilment{
+		{RequestId: "03", GasUsed: 21000, GasPrice: 10, Model: gorm.Model{CreatedAt: day}},
+		{RequestId: "02", GasUsed: 30000, GasPrice: 20, Model: gorm.Model{CreatedAt: day.Add(time.Minute)}},
+	}
+	for i := range failed {
+		require.NoError(t, db.Create(&failed[i]).Error)
+	}
+
+	jobs, err := db.GetSuccessfulRequestsBetween(day.Add(-time.Hour), day.AddDate(0, 0, 1), "")
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	require.Equal(t, "01", jobs[0].GetRequestId())
+
+	jobs, err = db.GetSuccessfulRequestsBetween(day.Add(-time.Hour), day.AddDate(0, 0, 3), "0xa")
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+
+	costs, err := db.GetFailedFulfilmentsBetween(day.Add(-time.Hour), day.AddDate(0, 0, 1), "")
+	require.NoError(t, err)
+	require.Len(t, costs, 2)
+	require.Equal(t, "0xa", costs[0].Consumer)
+	require.Equal(t, uint64(21000), costs[0].GasUsed)
+
+	costs, err = db.GetFailedFulfilmentsBetween(day.Add(-time.Hour), day.AddDate(0, 0, 1), "0xb")
+	require.NoError(t, err)
+	require.Len(t, costs, 1)
+	require.Equal(t, "02", costs[0].RequestId)
+}
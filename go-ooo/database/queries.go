@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 	"go-ooo/database/models"
+	"strings"
 	"time"
 )
 
@@ -20,9 +21,16 @@ func (d DB) GetLastBlockNumQueried() (models.ToBlocks, error) {
   DataRequests Queries
 */
 
+// NormaliseRequestId returns a request ID in the form it is stored, without the 0x prefix and
+// lower case, so that IDs copied from block explorers or logs can be used in lookups
+func NormaliseRequestId(requestId string) string {
+	requestId = strings.ToLower(strings.TrimSpace(requestId))
+	return strings.TrimPrefix(requestId, "0x")
+}
+
 func (d *DB) FindByRequestId(requestId string) (models.DataRequests, error) {
 	result := models.DataRequests{}
-	err := d.Where("request_id = ?", requestId).First(&result).Error
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&result).Error
 	return result, err
 }
 
@@ -33,6 +41,22 @@ func (d *DB) GetPendingJobs() ([]models.DataRequests, error) {
 	return jobs, err
 }
 
+// GetPendingJobsForProvider returns the pending jobs for requests sent to the provider address
+func (d *DB) GetPendingJobsForProvider(provider string) ([]models.DataRequests, error) {
+	var jobs = []models.DataRequests{}
+	err := d.Where("job_status = ? AND provider = ?",
+		models.JOB_STATUS_PENDING, provider).Order(fmt.Sprintf("id %s", "asc")).Find(&jobs).Error
+	return jobs, err
+}
+
+// GetConsumers returns the distinct consumer contracts which have sent requests to the provider
+func (d *DB) GetConsumers(provider string) ([]string, error) {
+	var consumers []string
+	err := d.Model(&models.DataRequests{}).Where("provider = ?", provider).
+		Distinct("consumer").Order("consumer asc").Pluck("consumer", &consumers).Error
+	return consumers, err
+}
+
 func (d *DB) GetLastXSuccessfulRequests(limit int, consumer string) ([]models.DataRequests, error) {
 	var requests = []models.DataRequests{}
 	var err error
@@ -50,6 +74,100 @@ func (d *DB) GetLastXSuccessfulRequests(limit int, consumer string) ([]models.Da
 	return requests, err
 }
 
+// GetLastServedAdhocPrice returns the most recent AdHoc request for the base/target
+// for which a price was fetched and sent, or successfully fulfilled
+func (d *DB) GetLastServedAdhocPrice(base string, target string) (models.DataRequests, error) {
+	request := models.DataRequests{}
+	err := d.Where("is_adhoc = ? AND endpoint_decoded LIKE ? AND price_result <> '' AND request_status IN ?",
+		true, fmt.Sprintf("%s.%s.AD%%", base, target),
+		[]int{models.REQUEST_STATUS_TX_SENT, models.REQUEST_STATUS_SUCCESS},
+	).Order("updated_at desc").First(&request).Error
+	return request, err
+}
+
+// GetPricedRequestsInBlockRange returns requests with a price result, requested between the
+// from and to blocks inclusive. A to block of 0 returns all requests from the from block
+func (d *DB) GetPricedRequestsInBlockRange(fromBlock uint64, toBlock uint64) ([]models.DataRequests, error) {
+	var requests []models.DataRequests
+	tx := d.Where("price_result <> ''").Where("request_block_number >= ?", fromBlock)
+	if toBlock > 0 {
+		tx = tx.Where("request_block_number <= ?", toBlock)
+	}
+	err := tx.Order("request_block_number asc").Find(&requests).Error
+	return requests, err
+}
+
+func (d *DB) GetRequestsByRequestIds(requestIds []string) ([]models.DataRequests, error) {
+	ids := make([]string, len(requestIds))
+	for i, id := range requestIds {
+		ids[i] = NormaliseRequestId(id)
+	}
+	var requests []models.DataRequests
+	err := d.Where("request_id IN ?", ids).Order("request_block_number asc").Find(&requests).Error
+	return requests, err
+}
+
+// RequestsFilter filters the requests returned by GetRequests. Zero values are not filtered
+type RequestsFilter struct {
+	Status    *int
+	Consumer  string
+	FromBlock uint64
+	ToBlock   uint64
+	Adhoc     *bool
+	Limit     int
+}
+
+// GetRequests returns requests matching the filter, most recent first
+func (d *DB) GetRequests(filter RequestsFilter) ([]models.DataRequests, error) {
+	var requests []models.DataRequests
+	tx := d.Model(&models.DataRequests{})
+
+	if filter.Status != nil {
+		tx = tx.Where("request_status = ?", *filter.Status)
+	}
+	if filter.Consumer != "" {
+		tx = tx.Where("LOWER(consumer) = LOWER(?)", filter.Consumer)
+	}
+	if filter.FromBlock > 0 {
+		tx = tx.Where("request_block_number >= ?", filter.FromBlock)
+	}
+	if filter.ToBlock > 0 {
+		tx = tx.Where("request_block_number <= ?", filter.ToBlock)
+	}
+	if filter.Adhoc != nil {
+		tx = tx.Where("is_adhoc = ?", *filter.Adhoc)
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+
+	err := tx.Order("id desc").Find(&requests).Error
+	return requests, err
+}
+
+// GetSuccessfulRequestsBetween returns the successful requests fulfilled between from and to,
+// oldest first, optionally filtered by consumer
+func (d *DB) GetSuccessfulRequestsBetween(from time.Time, to time.Time, consumer string) ([]models.DataRequests, error) {
+	var requests = []models.DataRequests{}
+
+	query := d.Where("job_status = ? AND updated_at >= ? AND updated_at < ?", models.JOB_STATUS_SUCCESS, from, to)
+	if len(consumer) > 0 {
+		query = query.Where("consumer = ?", consumer)
+	}
+
+	err := query.Order("updated_at asc").Find(&requests).Error
+	return requests, err
+}
+
+// GetRequestsMissingGas returns up to limit successful requests with a fulfilment Tx, but no gas
+// used or gas price recorded, oldest first
+func (d *DB) GetRequestsMissingGas(limit int) ([]models.DataRequests, error) {
+	var requests = []models.DataRequests{}
+	err := d.Where("job_status = ? AND fulfill_tx_hash != '' AND (fulfill_gas_used = 0 OR fulfill_gas_price = 0)",
+		models.JOB_STATUS_SUCCESS).Order("id asc").Limit(limit).Find(&requests).Error
+	return requests, err
+}
+
 func (d *DB) GetMostGasUsed() (models.DataRequests, error) {
 	request := models.DataRequests{}
 	err := d.Where("job_status = ?", models.JOB_STATUS_SUCCESS).Order(fmt.Sprintf("fulfill_gas_used %s", "desc")).Limit(1).First(&request).Error
@@ -62,6 +180,140 @@ func (d *DB) GetLeastGasUsed() (models.DataRequests, error) {
 	return request, err
 }
 
+/*
+  FailedFulfilment queries
+*/
+
+func (d *DB) GetFailedFulfilments(requestId string) ([]models.FailedFulfilment, error) {
+	var failed []models.FailedFulfilment
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).Order("id asc").Find(&failed).Error
+	return failed, err
+}
+
+// GetFailedFulfilmentsMissingGas returns up to limit failed fulfilment Txs with no gas used or gas
+// price recorded, which have not been marked as not mined, oldest first
+func (d *DB) GetFailedFulfilmentsMissingGas(limit int) ([]models.FailedFulfilment, error) {
+	var failed []models.FailedFulfilment
+	err := d.Where("tx_hash != '' AND not_mined = ? AND (gas_used = 0 OR gas_price = 0)", false).
+		Order("id asc").Limit(limit).Find(&failed).Error
+	return failed, err
+}
+
+// FailedFulfilmentCost is a failed fulfilment Tx, with the consumer of the request it was for
+type FailedFulfilmentCost struct {
+	Id        uint
+	RequestId string
+	TxHash    string
+	Consumer  string
+	GasUsed   uint64
+	GasPrice  uint64
+	CreatedAt time.Time
+}
+
+// GetFailedFulfilmentsBetween returns the failed fulfilment Txs recorded between from and to,
+// oldest first, optionally filtered by the consumer of the request
+func (d *DB) GetFailedFulfilmentsBetween(from time.Time, to time.Time, consumer string) ([]FailedFulfilmentCost, error) {
+	var failed []FailedFulfilmentCost
+
+	query := d.Model(&models.FailedFulfilment{}).
+		Select("failed_fulfilments.id, failed_fulfilments.request_id, failed_fulfilments.tx_hash, data_requests.consumer, "+
+			"failed_fulfilments.gas_used, failed_fulfilments.gas_price, failed_fulfilments.created_at").
+		Joins("LEFT JOIN data_requests ON data_requests.request_id = failed_fulfilments.request_id").
+		Where("failed_fulfilments.created_at >= ? AND failed_fulfilments.created_at < ?", from, to)
+	if len(consumer) > 0 {
+		query = query.Where("data_requests.consumer = ?", consumer)
+	}
+
+	err := query.Order("failed_fulfilments.created_at asc").Scan(&failed).Error
+	return failed, err
+}
+
+/*
+  PriceProvenance queries
+*/
+
+func (d *DB) GetPriceProvenance(requestId string) ([]models.PriceProvenance, error) {
+	var provenance []models.PriceProvenance
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).Order("id asc").Find(&provenance).Error
+	return provenance, err
+}
+
+/*
+  AdminAudit queries
+*/
+
+// AdminAuditFilter filters the entries returned by GetAdminAudit. Zero values are not filtered
+type AdminAuditFilter struct {
+	TokenId string
+	Task    string
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+// GetAdminAudit returns audit entries matching the filter, oldest first
+func (d *DB) GetAdminAudit(filter AdminAuditFilter) ([]models.AdminAudit, error) {
+	var entries []models.AdminAudit
+	tx := d.Model(&models.AdminAudit{})
+
+	if filter.TokenId != "" {
+		tx = tx.Where("token_id = ?", filter.TokenId)
+	}
+	if filter.Task != "" {
+		tx = tx.Where("task = ?", filter.Task)
+	}
+	if !filter.From.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where("created_at <= ?", filter.To)
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+
+	err := tx.Order("id asc").Find(&entries).Error
+	return entries, err
+}
+
+func (d *DB) GetAdminAuditPendingTxs() ([]models.AdminAudit, error) {
+	var entries []models.AdminAudit
+	err := d.Where("tx_status = ?", models.AUDIT_TX_STATUS_PENDING).Order("id asc").Find(&entries).Error
+	return entries, err
+}
+
+/*
+  ApiTokens queries
+*/
+
+func (d *DB) FindApiTokenByHash(tokenHash string) (models.ApiTokens, error) {
+	token := models.ApiTokens{}
+	err := d.Where("token_hash = ?", tokenHash).First(&token).Error
+	return token, err
+}
+
+func (d *DB) FindApiTokenByName(name string) (models.ApiTokens, error) {
+	token := models.ApiTokens{}
+	err := d.Where("name = ?", name).First(&token).Error
+	return token, err
+}
+
+func (d *DB) GetApiTokens() ([]models.ApiTokens, error) {
+	var tokens []models.ApiTokens
+	err := d.Order("id asc").Find(&tokens).Error
+	return tokens, err
+}
+
+/*
+  ShadowFulfilments queries
+*/
+
+func (d *DB) GetShadowFulfilments() ([]models.ShadowFulfilments, error) {
+	var shadows []models.ShadowFulfilments
+	err := d.Order("id asc").Find(&shadows).Error
+	return shadows, err
+}
+
 /*
   SupportedPairs queries
 */
@@ -88,13 +340,19 @@ func (d *DB) PairsNoLongerSupported(pairs []string) ([]models.SupportedPairs, er
   DexPairs queries
 */
 
+// FindByDexPairName returns verified pairs for the base/target, where both tokens are
+// the canonical contracts for their symbols on the chain
 func (d *DB) FindByDexPairName(base, target, chain, dexName string) ([]models.DexPairs, error) {
 	pair := fmt.Sprintf("%s-%s", base, target)
 	pairRev := fmt.Sprintf("%s-%s", target, base)
 	var result []models.DexPairs
-	err := d.Where(
-		"(pair = ? OR pair = ?) AND chain = ? AND dex = ? AND verified = ?", pair, pairRev, chain, dexName, true,
-	).Order("reserve_usd desc").Find(&result).Error
+	err := d.
+		Joins("JOIN token_contracts t0 ON t0.id = dex_pairs.t0_token_id AND t0.canonical = ? AND t0.deleted_at IS NULL", true).
+		Joins("JOIN token_contracts t1 ON t1.id = dex_pairs.t1_token_id AND t1.canonical = ? AND t1.deleted_at IS NULL", true).
+		Where(
+			"(dex_pairs.pair = ? OR dex_pairs.pair = ?) AND dex_pairs.chain = ? AND dex_pairs.dex = ? AND dex_pairs.verified = ?",
+			pair, pairRev, chain, dexName, true,
+		).Order("dex_pairs.reserve_usd desc").Find(&result).Error
 	return result, err
 }
 
@@ -136,6 +394,42 @@ func (d *DB) FindByTokenAll(symbol string, address string, chain string) (models
 	return result, err
 }
 
+func (d *DB) FindCanonicalToken(chain string, symbol string) (models.TokenContracts, error) {
+	result := models.TokenContracts{}
+	err := d.Where("chain = ? AND token_symbol = ? AND canonical = ?", chain, symbol, true).First(&result).Error
+	return result, err
+}
+
+func (d *DB) FindTokenByChainAndAddressAnyCase(chain string, address string) (models.TokenContracts, error) {
+	result := models.TokenContracts{}
+	err := d.Where("chain = ? AND LOWER(contract_address) = LOWER(?)", chain, address).First(&result).Error
+	return result, err
+}
+
+func (d *DB) GetTokensBySymbol(chain string, symbol string) ([]models.TokenContracts, error) {
+	var result []models.TokenContracts
+	err := d.Where("chain = ? AND token_symbol = ?", chain, symbol).Order("id asc").Find(&result).Error
+	return result, err
+}
+
+// GetPendingTokens returns tokens awaiting review, i.e. not approved as canonical.
+// chain is optional
+func (d *DB) GetPendingTokens(chain string) ([]models.TokenContracts, error) {
+	var result []models.TokenContracts
+	q := d.Where("canonical = ?", false)
+	if chain != "" {
+		q = q.Where("chain = ?", chain)
+	}
+	err := q.Order("chain asc, token_symbol asc").Find(&result).Error
+	return result, err
+}
+
+func (d *DB) tokensAreCanonical(t0DbId uint, t1DbId uint) bool {
+	var count int64
+	d.Model(&models.TokenContracts{}).Where("id IN ? AND canonical = ?", []uint{t0DbId, t1DbId}, true).Count(&count)
+	return count == 2
+}
+
 func (d *DB) FindTokenAddressByRowId(id uint) (string, error) {
 	result := models.TokenContracts{}
 	err := d.Where("id = ?", id).First(&result).Error
@@ -151,3 +445,27 @@ func (d *DB) getCurrentDbSchemaVersion() (models.VersionInfo, error) {
 	err := d.Where("version_type = ?", models.VERSION_TYPE_DB_SCHEMA).First(&result).Error
 	return result, err
 }
+
+/*
+  XfundPrices queries
+*/
+
+// GetXfundPriceHistory returns the xFUND prices recorded between from and to, oldest first,
+// including the last price recorded before from, if any
+func (d *DB) GetXfundPriceHistory(from time.Time, to time.Time) ([]models.XfundPrices, error) {
+	var prices []models.XfundPrices
+
+	var before models.XfundPrices
+	err := d.Where("created_at < ?", from).Order("created_at desc").Limit(1).Find(&before).Error
+	if err != nil {
+		return prices, err
+	}
+	if before.ID != 0 {
+		prices = append(prices, before)
+	}
+
+	var inRange []models.XfundPrices
+	err = d.Where("created_at >= ? AND created_at <= ?", from, to).Order("created_at asc").Find(&inRange).Error
+
+	return append(prices, inRange...), err
+}
@@ -0,0 +1,152 @@
+package database_test
+
+import (
+	"testing"
+
+	"go-ooo/database/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokensPendingReview(t *testing.T) {
+	db := newTestDb(t)
+
+	weth, err := db.FindOrInsertNewTokenContract("WETH", "0xaa", "eth")
+	require.NoError(t, err)
+	require.False(t, weth.Canonical)
+	usdc, err := db.FindOrInsertNewTokenContract("USDC", "0xbb", "eth")
+	require.NoError(t, err)
+	require.False(t, usdc.Canonical)
+
+	pair, err := db.InsertNewDexPair("WETH", "USDC", "eth", "0x01", "uniswapv3", weth.ID, usdc.ID, 1000, 10)
+	require.NoError(t, err)
+	require.False(t, pair.Verified)
+
+	pending, err := db.GetPendingTokens("eth")
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+
+	pairs, err := db.FindByDexPairName("WETH", "USDC", "eth", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 0)
+}
+
+func TestSetTokenCanonical(t *testing.T) {
+	db := newTestDb(t)
+
+	weth, _ := db.InsertNewTokenContract("WETH", "0xaa", "eth")
+	usdc, _ := db.InsertNewTokenContract("USDC", "0xbb", "eth")
+	_, err := db.InsertNewDexPair("WETH", "USDC", "eth", "0x01", "uniswapv3", weth.ID, usdc.ID, 1000, 10)
+	require.NoError(t, err)
+
+	// approving one token is not enough to verify the pair
+	weth, err = db.SetTokenCanonical("eth", "0xAA", true)
+	require.NoError(t, err)
+	require.True(t, weth.Canonical)
+	pairs, err := db.FindByDexPairName("WETH", "USDC", "eth", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 0)
+
+	_, err = db.SetTokenCanonical("eth", "0xbb", true)
+	require.NoError(t, err)
+	pairs, err = db.FindByDexPairName("WETH", "USDC", "eth", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+	require.True(t, pairs[0].Verified)
+
+	// reversed pair names match
+	pairs, err = db.FindByDexPairName("USDC", "WETH", "eth", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+
+	// approving a spoofed contract with the same symbol revokes the original
+	spoof, _ := db.InsertNewTokenContract("WETH", "0xcc", "eth")
+	_, err = db.SetTokenCanonical("eth", "0xcc", true)
+	require.NoError(t, err)
+
+	original, err := db.FindByChainAndAddress("eth", "0xaa")
+	require.NoError(t, err)
+	require.False(t, original.Canonical)
+
+	pairs, err = db.FindByDexPairName("WETH", "USDC", "eth", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 0)
+
+	_, err = db.SetTokenCanonical("eth", spoof.ContractAddress, false)
+	require.NoError(t, err)
+
+	_, err = db.SetTokenCanonical("eth", "0xdd", true)
+	require.Error(t, err)
+}
+
+func TestFindByDexPairNameRequiresCanonicalTokens(t *testing.T) {
+	db := newTestDb(t)
+
+	weth, _ := db.InsertNewTokenContract("WETH", "0xaa", "eth")
+	usdc, _ := db.InsertNewTokenContract("USDC", "0xbb", "eth")
+	_, err := db.SetTokenCanonical("eth", "0xaa", true)
+	require.NoError(t, err)
+
+	// a pair flagged verified is still excluded if either token is not canonical
+	pair, _ := db.InsertNewDexPair("WETH", "USDC", "eth", "0x01", "uniswapv3", weth.ID, usdc.ID, 1000, 10)
+	require.NoError(t, db.Model(&pair).Update("verified", true).Error)
+
+	pairs, err := db.FindByDexPairName("WETH", "USDC", "eth", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 0)
+
+	_, err = db.SetTokenCanonical("eth", "0xbb", true)
+	require.NoError(t, err)
+	pairs, err = db.FindByDexPairName("WETH", "USDC", "eth", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+
+	// other chains and DEXs are not matched
+	pairs, err = db.FindByDexPairName("WETH", "USDC", "bsc", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 0)
+	pairs, err = db.FindByDexPairName("WETH", "USDC", "eth", "sushiswap")
+	require.NoError(t, err)
+	require.Len(t, pairs, 0)
+}
+
+func TestMigrateV3ToV4(t *testing.T) {
+	db := newTestDb(t)
+
+	weth, _ := db.InsertNewTokenContract("WETH", "0xaa", "eth")
+	usdc, _ := db.InsertNewTokenContract("USDC", "0xbb", "eth")
+	_, _ = db.InsertNewTokenContract("USDC", "0xcc", "eth")
+	_, _ = db.InsertNewTokenContract("USDC", "0xdd", "bsc")
+	_, err := db.InsertNewDexPair("WETH", "USDC", "eth", "0x01", "uniswapv3", weth.ID, usdc.ID, 1000, 10)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Model(&models.VersionInfo{}).
+		Where("version_type = ?", models.VERSION_TYPE_DB_SCHEMA).Update("current_version", 3).Error)
+
+	db.MigrateV3ToV4()
+
+	var version models.VersionInfo
+	require.NoError(t, db.Where("version_type = ?", models.VERSION_TYPE_DB_SCHEMA).First(&version).Error)
+	require.Equal(t, uint64(4), version.GetCurrentVersion())
+
+	// tokens without a symbol collision on the chain are approved
+	pending, err := db.GetPendingTokens("")
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	for _, p := range pending {
+		require.Equal(t, "USDC", p.GetTokenSymbol())
+		require.Equal(t, "eth", p.GetChain())
+	}
+
+	// the pair uses a colliding token, so is not verified
+	pairs, err := db.FindByDexPairName("WETH", "USDC", "eth", "uniswapv3")
+	require.NoError(t, err)
+	require.Len(t, pairs, 0)
+
+	// tokens added after the migration are not approved when the migration runs again
+	_, _ = db.InsertNewTokenContract("DAI", "0xee", "eth")
+	db.MigrateV3ToV4()
+	pending, err = db.GetPendingTokens("")
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+}
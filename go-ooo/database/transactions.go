@@ -4,6 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"go-ooo/database/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 /*
@@ -38,7 +42,19 @@ func (d *DB) UpdateFulfillmentSuccess(requestId string, blockNumber uint64,
 	txHash string, gasUsed uint64, gasPrice uint64) error {
 
 	req := models.DataRequests{}
-	err := d.Where("request_id = ?", requestId).First(&req).Error
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&req).Error
+	if err != nil {
+		return err
+	}
+
+	if err = d.recordReplacedFulfilment(req, txHash); err != nil {
+		return err
+	}
+
+	// an earlier Tx may have been mined after being replaced, in which case its gas is counted
+	// as the fulfilment's
+	err = d.Where("tx_hash = ? AND fail_reason = ?", txHash, models.FAIL_REASON_TX_REPLACED).
+		Delete(&models.FailedFulfilment{}).Error
 	if err != nil {
 		return err
 	}
@@ -55,15 +71,20 @@ func (d *DB) UpdateFulfillmentSuccess(requestId string, blockNumber uint64,
 	return err
 }
 
-func (d *DB) UpdateFulfillmentSent(requestId string, txHash string, blockNumber uint64) error {
+func (d *DB) UpdateFulfillmentSent(requestId string, txHash string, nonce uint64, blockNumber uint64) error {
 
 	req := models.DataRequests{}
-	err := d.Where("request_id = ?", requestId).First(&req).Error
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&req).Error
 	if err != nil {
 		return err
 	}
 
+	if err = d.recordReplacedFulfilment(req, txHash); err != nil {
+		return err
+	}
+
 	req.FulfillTxHash = txHash
+	req.FulfillTxNonce = nonce
 	req.LastFulfillSentBlockNumber = blockNumber
 
 	err = d.Save(&req).Error
@@ -71,9 +92,32 @@ func (d *DB) UpdateFulfillmentSent(requestId string, txHash string, blockNumber
 	return err
 }
 
+// recordReplacedFulfilment adds the request's current fulfilment Tx to the failed Tx history when
+// it is superseded by txHash, unless it has already been recorded, so that its gas is accounted for
+func (d *DB) recordReplacedFulfilment(req models.DataRequests, txHash string) error {
+	if req.FulfillTxHash == "" || req.FulfillTxHash == txHash {
+		return nil
+	}
+
+	var count int64
+	err := d.Model(&models.FailedFulfilment{}).Where("tx_hash = ?", req.FulfillTxHash).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+
+	return d.InsertNewFailedFulfilment(req.RequestId, req.FulfillTxHash, 0, 0, models.FAIL_REASON_TX_REPLACED)
+}
+
+// UpdateFulfillmentGas sets the gas used and gas price of a request's successful fulfilment Tx.
+// updated_at is left unchanged, since it is used as the fulfilment time
+func (d *DB) UpdateFulfillmentGas(requestId string, gasUsed uint64, gasPrice uint64) error {
+	return d.Model(&models.DataRequests{}).Where("request_id = ?", NormaliseRequestId(requestId)).
+		UpdateColumns(map[string]interface{}{"fulfill_gas_used": gasUsed, "fulfill_gas_price": gasPrice}).Error
+}
+
 func (d *DB) IncrementFulfillmentAttempts(requestId string) error {
 	req := models.DataRequests{}
-	err := d.Where("request_id = ?", requestId).First(&req).Error
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&req).Error
 	if err != nil {
 		return err
 	}
@@ -87,7 +131,7 @@ func (d *DB) IncrementFulfillmentAttempts(requestId string) error {
 
 func (d *DB) UpdateRequestStatus(requestId string, status int, reason string) error {
 	req := models.DataRequests{}
-	err := d.Where("request_id = ?", requestId).First(&req).Error
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&req).Error
 	if err != nil {
 		return err
 	}
@@ -95,10 +139,17 @@ func (d *DB) UpdateRequestStatus(requestId string, status int, reason string) er
 	req.RequestStatus = status
 	req.StatusReason = reason
 
-	if status == models.REQUEST_STATUS_FULFILMENT_FAILED {
+	if status == models.REQUEST_STATUS_FULFILMENT_FAILED || status == models.REQUEST_STATUS_PRICE_REJECTED ||
+		status == models.REQUEST_STATUS_ABANDONED {
 		req.JobStatus = models.JOB_STATUS_FAIL
 	}
 
+	// nothing more for a dry-run node to do. The production node's fulfilment is
+	// only recorded in the shadow_fulfilments table
+	if status == models.REQUEST_STATUS_DRY_RUN {
+		req.JobStatus = models.JOB_STATUS_SUCCESS
+	}
+
 	err = d.Save(&req).Error
 
 	return err
@@ -106,7 +157,7 @@ func (d *DB) UpdateRequestStatus(requestId string, status int, reason string) er
 
 func (d *DB) UpdateJobStatus(requestId string, status int) error {
 	req := models.DataRequests{}
-	err := d.Where("request_id = ?", requestId).First(&req).Error
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&req).Error
 	if err != nil {
 		return err
 	}
@@ -118,15 +169,41 @@ func (d *DB) UpdateJobStatus(requestId string, status int) error {
 	return err
 }
 
-func (d *DB) UpdateDataFetched(requestId string, price string) error {
+// ResetRequest puts a request back into the pending job queue with the given status, and
+// resets the number of fulfilment attempts. If clearPrice is true, any previously fetched
+// price is removed so that it is fetched again
+func (d *DB) ResetRequest(requestId string, status int, reason string, clearPrice bool) error {
 	req := models.DataRequests{}
-	err := d.Where("request_id = ?", requestId).First(&req).Error
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&req).Error
+	if err != nil {
+		return err
+	}
+
+	req.RequestStatus = status
+	req.StatusReason = reason
+	req.JobStatus = models.JOB_STATUS_PENDING
+	req.FulfillmentAttempts = 0
+
+	if clearPrice {
+		req.PriceResult = ""
+		req.PriceSource = ""
+	}
+
+	err = d.Save(&req).Error
+
+	return err
+}
+
+func (d *DB) UpdateDataFetched(requestId string, price string, source string) error {
+	req := models.DataRequests{}
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&req).Error
 	if err != nil {
 		return err
 	}
 
 	req.RequestStatus = models.REQUEST_STATUS_DATA_READY_TO_SEND
 	req.PriceResult = price
+	req.PriceSource = source
 
 	err = d.Save(&req).Error
 
@@ -135,7 +212,7 @@ func (d *DB) UpdateDataFetched(requestId string, price string) error {
 
 func (d *DB) UpdateLastDataFetchBlockNumber(requestId string, blockNum uint64) error {
 	req := models.DataRequests{}
-	err := d.Where("request_id = ?", requestId).First(&req).Error
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&req).Error
 	if err != nil {
 		return err
 	}
@@ -192,6 +269,120 @@ func (d *DB) InsertNewFailedFulfilment(requestId string, txHash string, gasUsed
 	return
 }
 
+// UpdateFailedFulfilmentGas sets the gas used and gas price of a failed fulfilment Tx
+func (d *DB) UpdateFailedFulfilmentGas(id uint, gasUsed uint64, gasPrice uint64) error {
+	return d.Model(&models.FailedFulfilment{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"gas_used": gasUsed, "gas_price": gasPrice}).Error
+}
+
+// SetFailedFulfilmentNotMined records that a failed fulfilment Tx will not be mined, and costs nothing
+func (d *DB) SetFailedFulfilmentNotMined(id uint) error {
+	return d.Model(&models.FailedFulfilment{}).Where("id = ?", id).UpdateColumn("not_mined", true).Error
+}
+
+/*
+  PriceProvenance
+*/
+
+func (d *DB) InsertPriceProvenance(provenance models.PriceProvenance) error {
+	return d.Create(&provenance).Error
+}
+
+/*
+  ShadowFulfilments
+*/
+
+// InsertShadowFulfilment records the price and would-be Tx for a request. If the production
+// node's fulfilment has already been recorded for the request, it is retained
+func (d *DB) InsertShadowFulfilment(shadow models.ShadowFulfilments) error {
+	shadow.RequestId = NormaliseRequestId(shadow.RequestId)
+
+	existing := models.ShadowFulfilments{}
+	err := d.Where("request_id = ?", shadow.RequestId).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if existing.ID != 0 {
+		shadow.ID = existing.ID
+		shadow.CreatedAt = existing.CreatedAt
+		shadow.OnChainPrice = existing.OnChainPrice
+		shadow.OnChainTxHash = existing.OnChainTxHash
+		shadow.OnChainBlockNumber = existing.OnChainBlockNumber
+	}
+	return d.Save(&shadow).Error
+}
+
+// UpdateShadowOnChainResult records the production node's fulfilment for a request. The row is
+// created if the dry-run node has not yet priced the request
+func (d *DB) UpdateShadowOnChainResult(requestId string, consumer string, price string, txHash string, blockNumber uint64) error {
+	shadow := models.ShadowFulfilments{}
+	err := d.Where("request_id = ?", NormaliseRequestId(requestId)).First(&shadow).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	shadow.RequestId = NormaliseRequestId(requestId)
+	shadow.Consumer = consumer
+	shadow.OnChainPrice = price
+	shadow.OnChainTxHash = txHash
+	shadow.OnChainBlockNumber = blockNumber
+
+	return d.Save(&shadow).Error
+}
+
+/*
+  AdminAudit
+*/
+
+func (d *DB) InsertAdminAudit(entry models.AdminAudit) error {
+	return d.Create(&entry).Error
+}
+
+// UpdateAdminAuditTxOutcome sets the on-chain outcome of a pending audit entry's Tx. Entries
+// whose outcome has already been set are not modified
+func (d *DB) UpdateAdminAuditTxOutcome(id uint, status int, blockNumber uint64, gasUsed uint64) error {
+	return d.Model(&models.AdminAudit{}).
+		Where("id = ? AND tx_status = ?", id, models.AUDIT_TX_STATUS_PENDING).
+		Updates(map[string]interface{}{
+			"tx_status":       status,
+			"tx_block_number": blockNumber,
+			"tx_gas_used":     gasUsed,
+		}).Error
+}
+
+/*
+  ApiTokens
+*/
+
+func (d *DB) InsertApiToken(name string, tokenHash string, scopes []string, expiresAt *time.Time) error {
+	return d.Create(&models.ApiTokens{
+		Name:      name,
+		TokenHash: tokenHash,
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func (d *DB) RevokeApiToken(name string) error {
+	token, err := d.FindApiTokenByName(name)
+	if err != nil {
+		return err
+	}
+
+	if token.IsRevoked() {
+		return nil
+	}
+
+	now := time.Now()
+	token.RevokedAt = &now
+
+	return d.Save(&token).Error
+}
+
+func (d *DB) UpdateApiTokenLastUsed(id uint, lastUsed time.Time) error {
+	return d.Model(&models.ApiTokens{}).Where("id = ?", id).Update("last_used_at", lastUsed).Error
+}
+
 /*
   DexPairs
 */
@@ -206,6 +397,7 @@ func (d *DB) FindOrInsertNewDexPair(t0Symbol string, t1Symbol string, chain stri
 	} else {
 		// update Reserve value
 		pair.ReserveUsd = reserveUsd
+		pair.Verified = d.tokensAreCanonical(pair.T0TokenId, pair.T1TokenId)
 		err = d.Save(&pair).Error
 	}
 
@@ -226,7 +418,7 @@ func (d *DB) InsertNewDexPair(t0Symbol string, t1Symbol string, chain string,
 		ContractAddress: contractAddress,
 		ReserveUsd:      reserveUsd,
 		TxCount:         txCount,
-		Verified:        true,
+		Verified:        d.tokensAreCanonical(t0DbId, t1DbId),
 	}
 
 	err := d.Create(&data).Error
@@ -271,12 +463,15 @@ func (d *DB) UpdateOrInsertNewTokenContract(symbol string, contractAddress strin
 	return res, err
 }
 
+// InsertNewTokenContract adds a new token contract. New tokens are not canonical, and must be
+// reviewed and approved by the operator before pairs using them are priced
 func (d *DB) InsertNewTokenContract(symbol string, contractAddress string, chain string) (models.TokenContracts, error) {
 
 	data := models.TokenContracts{
 		TokenSymbol:     symbol,
 		ContractAddress: contractAddress,
 		Chain:           chain,
+		Canonical:       false,
 	}
 
 	err := d.Create(&data).Error
@@ -284,6 +479,75 @@ func (d *DB) InsertNewTokenContract(symbol string, contractAddress string, chain
 	return data, err
 }
 
+// SetTokenCanonical approves or revokes a token contract as the canonical contract for
+// its symbol on the chain. Approving a token revokes any other contract with the same
+// symbol. Pairs using the affected tokens are re-verified
+func (d *DB) SetTokenCanonical(chain string, contractAddress string, canonical bool) (models.TokenContracts, error) {
+	token, err := d.FindTokenByChainAndAddressAnyCase(chain, contractAddress)
+	if err != nil {
+		return token, err
+	}
+
+	err = d.Transaction(func(tx *gorm.DB) error {
+		if canonical {
+			err := tx.Model(&models.TokenContracts{}).
+				Where("chain = ? AND token_symbol = ? AND id <> ?", chain, token.TokenSymbol, token.ID).
+				Update("canonical", false).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		token.Canonical = canonical
+		return tx.Save(&token).Error
+	})
+
+	if err != nil {
+		return token, err
+	}
+
+	err = d.ReverifyDexPairsForSymbol(chain, token.TokenSymbol)
+
+	return token, err
+}
+
+// ReverifyDexPairsForSymbol sets the verified flag for all pairs on the chain which use
+// a token with the given symbol, according to whether both tokens are canonical
+func (d *DB) ReverifyDexPairsForSymbol(chain string, symbol string) error {
+	var pairs []models.DexPairs
+	err := d.Where("chain = ? AND (t0_symbol = ? OR t1_symbol = ?)", chain, symbol, symbol).Find(&pairs).Error
+	if err != nil {
+		return err
+	}
+
+	return d.reverifyDexPairs(pairs)
+}
+
+// ReverifyAllDexPairs sets the verified flag for all pairs according to whether both tokens
+// are canonical
+func (d *DB) ReverifyAllDexPairs() error {
+	var pairs []models.DexPairs
+	err := d.Find(&pairs).Error
+	if err != nil {
+		return err
+	}
+
+	return d.reverifyDexPairs(pairs)
+}
+
+func (d *DB) reverifyDexPairs(pairs []models.DexPairs) error {
+	for _, p := range pairs {
+		verified := d.tokensAreCanonical(p.T0TokenId, p.T1TokenId)
+		if verified != p.Verified {
+			err := d.Model(&p).Update("verified", verified).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 /*
  VersionInfo
 */
@@ -302,3 +566,14 @@ func (d *DB) setDbSchemaVersion(newVersion uint64) error {
 
 	return err
 }
+
+/*
+  XfundPrices
+*/
+
+func (d *DB) InsertXfundPrice(priceEth float64, source string) error {
+	return d.Create(&models.XfundPrices{
+		PriceEth: priceEth,
+		Source:   source,
+	}).Error
+}
@@ -1,6 +1,8 @@
 package flags
 
 const (
-	FlagHome = "home"
-	FlagPass = "pass"
+	FlagHome     = "home"
+	FlagPass     = "pass"
+	FlagPassFile = "pass-file"
+	FlagPassEnv  = "pass-env"
 )
@@ -20,16 +20,19 @@ func (ks *Keystorage) InitNewKeystore(keyStorePath string) (err error, adduserna
 
 	fmt.Println("")
 	fmt.Println("Import a private key or create a new account")
-	fmt.Print("Username: ")
+	if !ks.IsV3() {
+		// V3 keystore accounts are identified by their address
+		fmt.Print("Username: ")
 
-	fmt.Scanf("%s\n", &addusername)
+		fmt.Scanf("%s\n", &addusername)
 
-	if ks.ExistsByUsername(addusername) {
-		fmt.Println("This account name is already used")
-		_, _ = ks.InitNewKeystore(keyStorePath)
-	} else if addusername == "" {
-		fmt.Println("Please enter account username.")
-		_, _ = ks.InitNewKeystore(keyStorePath)
+		if ks.ExistsByUsername(addusername) {
+			fmt.Println("This account name is already used")
+			_, _ = ks.InitNewKeystore(keyStorePath)
+		} else if addusername == "" {
+			fmt.Println("Please enter account username.")
+			_, _ = ks.InitNewKeystore(keyStorePath)
+		}
 	}
 	fmt.Println("")
 	fmt.Println("Do you want to add an existing private key or generate a new one?")
@@ -85,6 +88,9 @@ func (ks *Keystorage) InitNewKeystore(keyStorePath string) (err error, adduserna
 		return
 	}
 	fmt.Println(walletAddress.Hex())
+	if ks.IsV3() {
+		addusername = walletAddress.Hex()
+	}
 	fmt.Println("Keystore saved to:")
 	fmt.Println(keyStorePath)
 
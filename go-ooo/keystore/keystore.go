@@ -19,6 +19,7 @@ import (
 	"go-ooo/utils"
 	"go-ooo/utils/walletworker"
 
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -26,6 +27,8 @@ type Keystorage struct {
 	File     *os.File
 	KeyStore *KeyStorageModel
 	mu       sync.Mutex
+	// set if the keystore is a directory of V3 keystore files
+	v3 *gethks.KeyStore
 }
 
 func NewKeyStorageNoLogger(filePath string) (*Keystorage, error) {
@@ -34,7 +37,8 @@ func NewKeyStorageNoLogger(filePath string) (*Keystorage, error) {
 	var keyStore = KeyStorageModel{}
 
 	if _, err = os.Stat(filePath); err == nil {
-		keystoreFile, err2 := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0755)
+		var err2 error
+		keystoreFile, err2 = os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0755)
 		if err2 != nil {
 			return nil, err2
 		}
@@ -104,6 +108,9 @@ func NewKeyStorage(filePath string) (*Keystorage, error) {
 }
 
 func (d *Keystorage) GetFirst() *KeyStorageKeyModel {
+	if d.IsV3() {
+		return d.v3GetFirst()
+	}
 	key := d.KeyStore.GetKey()
 	if key[0].Private == "" {
 		key[0].Private, _ = Decrypt(key[0].CipherPrivate, d.KeyStore.Token)
@@ -113,6 +120,10 @@ func (d *Keystorage) GetFirst() *KeyStorageKeyModel {
 }
 
 func (d *Keystorage) GetByUsername(account string) *KeyStorageKeyModel {
+	if d.IsV3() {
+		key, _ := d.v3GetByAccount(account)
+		return key
+	}
 	keys := d.KeyStore.GetKey()
 	for _, key := range keys {
 		if key.Account == account {
@@ -127,6 +138,10 @@ func (d *Keystorage) GetByUsername(account string) *KeyStorageKeyModel {
 }
 
 func (d *Keystorage) ExistsByUsername(account string) bool {
+	if d.IsV3() {
+		_, ok := d.v3Find(account)
+		return ok
+	}
 	keys := d.KeyStore.GetKey()
 	for _, key := range keys {
 		if key.Account == account {
@@ -142,6 +157,11 @@ func (d *Keystorage) GeneratePrivate(username string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if d.IsV3() {
+		// V3 keystore files are identified by address, so the username is not stored
+		_, err = d.importV3(keyGeneratedString)
+		return keyGeneratedString, err
+	}
 	cipherPrivate, err := Encrypt(keyGeneratedString, d.KeyStore.Token)
 	if err != nil {
 		return "", err
@@ -163,6 +183,10 @@ func (d *Keystorage) GeneratePrivate(username string) (string, error) {
 
 func (d *Keystorage) AddExisting(username string, privateKey string) (err error) {
 	privkeyHex := utils.AddHexPrefix(privateKey)
+	if d.IsV3() {
+		_, err = d.importV3(privkeyHex)
+		return
+	}
 	cipherPrivate, err := Encrypt(privkeyHex, d.KeyStore.Token)
 	if err != nil {
 		return err
@@ -182,7 +206,78 @@ func (d *Keystorage) AddExisting(username string, privateKey string) (err error)
 	return
 }
 
+// GetAccounts returns the account names in the keystore. V3 keystore accounts are
+// identified by their address
+func (d *Keystorage) GetAccounts() []string {
+	accounts := make([]string, 0)
+	if d.IsV3() {
+		for _, a := range d.v3Accounts() {
+			accounts = append(accounts, a.Address.Hex())
+		}
+		return accounts
+	}
+	for _, key := range d.KeyStore.GetKey() {
+		accounts = append(accounts, key.GetAccount())
+	}
+	return accounts
+}
+
+// Remove deletes the account's key from the keystore. The token must be set
+func (d *Keystorage) Remove(account string) error {
+	if d.IsV3() {
+		return d.v3Remove(account)
+	}
+
+	keys := make([]*KeyStorageKeyModel, 0, len(d.KeyStore.Key))
+	for _, key := range d.KeyStore.GetKey() {
+		if key.Account != account {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == len(d.KeyStore.Key) {
+		return fmt.Errorf("Can't find user, sorry.")
+	}
+
+	d.KeyStore.Key = keys
+	return d.save()
+}
+
+// ChangeToken re-encrypts each key in the keystore with newToken, which becomes the
+// decryption & admin password. The current token must be set
+func (d *Keystorage) ChangeToken(newToken string) error {
+	if newToken == "" {
+		return fmt.Errorf("new token cannot be empty")
+	}
+	if d.IsV3() {
+		return d.v3ChangeToken(newToken)
+	}
+
+	keys := make([]*KeyStorageKeyModel, 0, len(d.KeyStore.Key))
+	for _, key := range d.KeyStore.GetKey() {
+		private, err := Decrypt(key.CipherPrivate, d.KeyStore.Token)
+		if err != nil {
+			return err
+		}
+		cipherPrivate, err := Encrypt(private, newToken)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, &KeyStorageKeyModel{
+			Account:       key.Account,
+			CipherPrivate: cipherPrivate,
+			Private:       private,
+		})
+	}
+
+	d.KeyStore.Key = keys
+	d.KeyStore.Token = newToken
+	return d.tokenEncryptAndSave()
+}
+
 func (d Keystorage) GetByAccount(account string) (*KeyStorageKeyModel, error) {
+	if d.IsV3() {
+		return d.v3GetByAccount(account)
+	}
 	var keys = d.KeyStore.GetKey()
 	for _, key := range keys {
 		if key.Account == account {
@@ -194,6 +289,9 @@ func (d Keystorage) GetByAccount(account string) (*KeyStorageKeyModel, error) {
 }
 
 func (d Keystorage) Exists() bool {
+	if d.IsV3() {
+		return len(d.v3Accounts()) > 0
+	}
 	if len((d.KeyStore.GetKey())) > 0 {
 		return true
 	}
@@ -208,6 +306,10 @@ func (d *Keystorage) save() error {
 	if err != nil {
 		return err
 	}
+	// truncate first, since the keystore may have shrunk if a key was removed
+	if err = d.File.Truncate(0); err != nil {
+		return err
+	}
 	_, err = d.File.WriteAt(jsonByte, 0)
 	return err
 }
@@ -220,6 +322,9 @@ func (d *Keystorage) GenerateToken() (string, error) {
 }
 
 func (d *Keystorage) CheckToken(token string) (err error) {
+	if d.IsV3() {
+		return d.v3CheckToken(token)
+	}
 	err = bcrypt.CompareHashAndPassword([]byte(d.KeyStore.Hash), []byte(token))
 	if err == nil {
 		d.KeyStore.Token = token
@@ -233,6 +338,9 @@ func (d *Keystorage) SelectPrivateKey(account string) (err error) {
 	} else {
 		d.KeyStore.PrivateKey = d.GetFirst().GetPrivate()
 	}
+	if d.IsV3() && d.KeyStore.PrivateKey == "" {
+		return fmt.Errorf("cannot decrypt key for account %s", account)
+	}
 	return err
 }
 
@@ -241,6 +349,10 @@ func (d *Keystorage) GetSelectedPrivateKey() string {
 }
 
 func (d *Keystorage) tokenEncryptAndSave() (err error) {
+	if d.IsV3() {
+		// V3 keystore files are verified by their own MAC, so no hash is stored
+		return
+	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(d.KeyStore.Token), 8)
 	if err != nil {
 		return
@@ -170,3 +170,28 @@ func TestGetSelectedPrivateKey_WithToken(t *testing.T) {
 
 	require.Equal(t, "0x646f1ce2fdad0e6deeeb5c7e8e5543bdde65e86029e2fd9fc169899c440a7913", ks.GetSelectedPrivateKey())
 }
+
+func TestRemoveAndChangeToken(t *testing.T) {
+	kPath := filepath.Join(t.TempDir(), "keystore.json")
+	ks, err := keystore.NewKeyStorageNoLogger(kPath)
+	require.NoError(t, err)
+
+	_, err = ks.GenerateToken()
+	require.NoError(t, err)
+	require.NoError(t, ks.AddExisting("test", testPrivate))
+	_, err = ks.GeneratePrivate("test2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"test", "test2"}, ks.GetAccounts())
+
+	require.NoError(t, ks.ChangeToken("newtoken"))
+	require.Error(t, ks.Remove("unknown"))
+	require.NoError(t, ks.Remove("test2"))
+
+	// reload from disk
+	ks, err = keystore.NewKeyStorageNoLogger(kPath)
+	require.NoError(t, err)
+	require.Equal(t, []string{"test"}, ks.GetAccounts())
+	require.NoError(t, ks.CheckToken("newtoken"))
+	require.NoError(t, ks.SelectPrivateKey("test"))
+	require.Equal(t, testPrivate, ks.GetSelectedPrivateKey())
+}
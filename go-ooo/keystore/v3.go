@@ -0,0 +1,211 @@
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go-ooo/utils"
+	"go-ooo/utils/walletworker"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// V3ScryptN and V3ScryptP are the scrypt parameters used when writing V3 keystore files.
+// They may be lowered in tests
+var (
+	V3ScryptN = gethks.StandardScryptN
+	V3ScryptP = gethks.StandardScryptP
+)
+
+// V3Migration records the address a legacy keystore account was migrated to
+type V3Migration struct {
+	Account string
+	Address common.Address
+}
+
+// NewV3KeyStorage opens a directory of standard Ethereum V3 (Web3 Secret Storage) keystore
+// files, for example a geth keystore directory. The directory is created if it does not exist.
+// Accounts are identified by their address, and the decryption token is the passphrase used
+// to encrypt the key files
+func NewV3KeyStorage(dir string) (*Keystorage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Keystorage{
+		KeyStore: &KeyStorageModel{Key: []*KeyStorageKeyModel{}},
+		v3:       gethks.NewKeyStore(dir, V3ScryptN, V3ScryptP),
+	}, nil
+}
+
+// IsV3 returns true if the keystore uses V3 keystore files
+func (d *Keystorage) IsV3() bool {
+	return d.v3 != nil
+}
+
+// MigrateToV3 decrypts each key in a legacy keystore with the current token, and writes it
+// to dir as a V3 keystore file encrypted with the same token, so that the token remains the
+// decryption & admin password
+func (d *Keystorage) MigrateToV3(dir string) ([]V3Migration, error) {
+	if d.IsV3() {
+		return nil, errors.New("keystore is already a V3 keystore")
+	}
+	if d.KeyStore.Token == "" {
+		return nil, errors.New("keystore token not set")
+	}
+
+	dst, err := NewV3KeyStorage(dir)
+	if err != nil {
+		return nil, err
+	}
+	dst.KeyStore.SetToken(d.KeyStore.Token)
+
+	migrated := make([]V3Migration, 0, len(d.KeyStore.GetKey()))
+	for _, key := range d.KeyStore.GetKey() {
+		private, err := Decrypt(key.GetCipherPrivate(), d.KeyStore.Token)
+		if err != nil {
+			return migrated, err
+		}
+
+		address, err := dst.importV3(private)
+		if err != nil && !errors.Is(err, gethks.ErrAccountAlreadyExists) {
+			return migrated, errors.New(fmt.Sprintf("migrate account %s: %s", key.GetAccount(), err.Error()))
+		}
+
+		migrated = append(migrated, V3Migration{
+			Account: key.GetAccount(),
+			Address: address,
+		})
+	}
+
+	return migrated, nil
+}
+
+func (d *Keystorage) v3Accounts() []accounts.Account {
+	return d.v3.Accounts()
+}
+
+func (d *Keystorage) v3Find(account string) (accounts.Account, bool) {
+	if !common.IsHexAddress(account) {
+		return accounts.Account{}, false
+	}
+	address := common.HexToAddress(account)
+	for _, a := range d.v3Accounts() {
+		if a.Address == address {
+			return a, true
+		}
+	}
+	return accounts.Account{}, false
+}
+
+// v3Decrypt decrypts the key file for the account using the current token
+func (d *Keystorage) v3Decrypt(a accounts.Account) (*KeyStorageKeyModel, error) {
+	keyJson, err := os.ReadFile(a.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := gethks.DecryptKey(keyJson, d.KeyStore.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyStorageKeyModel{
+		Account: a.Address.Hex(),
+		Private: hexutil.Encode(crypto.FromECDSA(key.PrivateKey)),
+	}, nil
+}
+
+func (d *Keystorage) v3GetByAccount(account string) (*KeyStorageKeyModel, error) {
+	a, ok := d.v3Find(account)
+	if !ok {
+		return &KeyStorageKeyModel{}, fmt.Errorf("Can't find user, sorry.")
+	}
+	return d.v3Decrypt(a)
+}
+
+func (d *Keystorage) v3GetFirst() *KeyStorageKeyModel {
+	accs := d.v3Accounts()
+	if len(accs) == 0 {
+		return &KeyStorageKeyModel{}
+	}
+	key, err := d.v3Decrypt(accs[0])
+	if err != nil {
+		return &KeyStorageKeyModel{Account: accs[0].Address.Hex()}
+	}
+	return key
+}
+
+// v3CheckToken checks the token can decrypt at least one of the key files
+func (d *Keystorage) v3CheckToken(token string) error {
+	accs := d.v3Accounts()
+	if len(accs) == 0 {
+		return errors.New("no keys found in keystore")
+	}
+
+	for _, a := range accs {
+		keyJson, err := os.ReadFile(a.URL.Path)
+		if err != nil {
+			continue
+		}
+		if _, err = gethks.DecryptKey(keyJson, token); err == nil {
+			d.KeyStore.Token = token
+			return nil
+		}
+	}
+
+	return gethks.ErrDecrypt
+}
+
+func (d *Keystorage) v3Remove(account string) error {
+	a, ok := d.v3Find(account)
+	if !ok {
+		return fmt.Errorf("Can't find user, sorry.")
+	}
+	return d.v3.Delete(a, d.KeyStore.Token)
+}
+
+// v3ChangeToken re-encrypts each key file with newToken. If any fails, the keys already
+// updated are reverted to the current token
+func (d *Keystorage) v3ChangeToken(newToken string) error {
+	updated := make([]accounts.Account, 0)
+	for _, a := range d.v3Accounts() {
+		if err := d.v3.Update(a, d.KeyStore.Token, newToken); err != nil {
+			for _, u := range updated {
+				_ = d.v3.Update(u, newToken, d.KeyStore.Token)
+			}
+			return errors.New(fmt.Sprintf("change token for %s: %s", a.Address.Hex(), err.Error()))
+		}
+		updated = append(updated, a)
+	}
+
+	d.KeyStore.Token = newToken
+	return nil
+}
+
+// importV3 encrypts the private key hex string with the current token, and writes it as a
+// V3 keystore file
+func (d *Keystorage) importV3(privateKey string) (common.Address, error) {
+	pkey, err := walletworker.StringToPrivate(utils.RemoveHexPrefix(strings.TrimSpace(privateKey)))
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	address := crypto.PubkeyToAddress(pkey.PublicKey)
+	if _, ok := d.v3Find(address.Hex()); ok {
+		return address, gethks.ErrAccountAlreadyExists
+	}
+
+	a, err := d.v3.ImportECDSA(pkey, d.KeyStore.Token)
+	if err != nil {
+		return address, err
+	}
+
+	return a.Address, nil
+}
@@ -0,0 +1,101 @@
+package keystore_test
+
+import (
+	"testing"
+
+	"go-ooo/keystore"
+
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken   = "0d1bd3us45hi8j6bhno4ca00z5pk5i5t"
+	testPrivate = "0x646f1ce2fdad0e6deeeb5c7e8e5543bdde65e86029e2fd9fc169899c440a7913"
+)
+
+func init() {
+	keystore.V3ScryptN = gethks.LightScryptN
+	keystore.V3ScryptP = gethks.LightScryptP
+}
+
+func TestMigrateToV3(t *testing.T) {
+	ks := loadKeystoreFromFile()
+	require.NoError(t, ks.CheckToken(testToken))
+
+	dir := t.TempDir()
+	migrated, err := ks.MigrateToV3(dir)
+	require.NoError(t, err)
+	require.Len(t, migrated, 1)
+	require.Equal(t, "test", migrated[0].Account)
+
+	// migrating again is idempotent
+	_, err = ks.MigrateToV3(dir)
+	require.NoError(t, err)
+
+	v3, err := keystore.NewV3KeyStorage(dir)
+	require.NoError(t, err)
+	require.True(t, v3.IsV3())
+	require.True(t, v3.Exists())
+
+	require.Error(t, v3.CheckToken("wrong"))
+	require.NoError(t, v3.CheckToken(testToken))
+
+	address := migrated[0].Address.Hex()
+	require.True(t, v3.ExistsByUsername(address))
+
+	require.NoError(t, v3.SelectPrivateKey(address))
+	require.Equal(t, testPrivate, v3.GetSelectedPrivateKey())
+}
+
+func TestV3AddAndGenerate(t *testing.T) {
+	ks, err := keystore.NewV3KeyStorage(t.TempDir())
+	require.NoError(t, err)
+	require.False(t, ks.Exists())
+
+	token, err := ks.GenerateToken()
+	require.NoError(t, err)
+
+	require.NoError(t, ks.AddExisting("", testPrivate))
+	require.Error(t, ks.AddExisting("", testPrivate))
+
+	generated, err := ks.GeneratePrivate("")
+	require.NoError(t, err)
+
+	require.NoError(t, ks.CheckToken(token))
+
+	first := ks.GetFirst()
+	require.NotEmpty(t, first.GetAccount())
+	require.Contains(t, []string{testPrivate, generated}, first.GetPrivate())
+}
+
+func TestV3RemoveAndChangeToken(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := keystore.NewV3KeyStorage(dir)
+	require.NoError(t, err)
+
+	_, err = ks.GenerateToken()
+	require.NoError(t, err)
+	require.NoError(t, ks.AddExisting("", testPrivate))
+	_, err = ks.GeneratePrivate("")
+	require.NoError(t, err)
+	require.Len(t, ks.GetAccounts(), 2)
+
+	require.NoError(t, ks.ChangeToken("newtoken"))
+
+	address := ""
+	for _, a := range ks.GetAccounts() {
+		if ks.GetByUsername(a).GetPrivate() != testPrivate {
+			require.NoError(t, ks.Remove(a))
+		} else {
+			address = a
+		}
+	}
+
+	ks, err = keystore.NewV3KeyStorage(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{address}, ks.GetAccounts())
+	require.NoError(t, ks.CheckToken("newtoken"))
+	require.NoError(t, ks.SelectPrivateKey(address))
+	require.Equal(t, testPrivate, ks.GetSelectedPrivateKey())
+}
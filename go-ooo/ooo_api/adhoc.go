@@ -1,16 +1,34 @@
 package ooo_api
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/montanaflynn/stats"
+	"go-ooo/database/models"
 	"go-ooo/logger"
 	"go-ooo/utils"
 	"math"
 	"math/big"
 	"strconv"
+	"time"
 )
 
 func (o *OOOApi) QueryAdhoc(endpoint string, requestId string) (string, error) {
+	price, _, err := o.queryAdhoc(endpoint, requestId, time.Time{})
+	return price, err
+}
+
+// queryAdhoc calculates the price from the DEX aggregator, and returns the provenance
+// record detailing how the price was calculated. If at is set, the price is calculated
+// for the window ending at that time instead of the latest block
+func (o *OOOApi) queryAdhoc(endpoint string, requestId string, at time.Time) (string, models.PriceProvenance, error) {
+	provenance := models.PriceProvenance{
+		RequestId: requestId,
+		Endpoint:  endpoint,
+		Source:    SourceDex,
+	}
+
 	base, target, _, mins, _, _, _, err := ParseEndpoint(endpoint)
 
 	minutes, _ := strconv.ParseInt(mins, 10, 64)
@@ -25,7 +43,7 @@ func (o *OOOApi) QueryAdhoc(endpoint string, requestId string) (string, error) {
 	}
 
 	if err != nil {
-		return "", err
+		return "", provenance, err
 	}
 
 	logger.Debug("ooo_api", "QueryAdhoc", "ParseEndpoint", "AdHoc endpoint parsed", logger.Fields{
@@ -39,7 +57,19 @@ func (o *OOOApi) QueryAdhoc(endpoint string, requestId string) (string, error) {
 	priceCount := 0
 	total := big.NewInt(0)
 
-	rawPrices := o.dexModuleManager.GetPricesFromDexModules(base, target, uint64(minutes))
+	provenance.Window = fmt.Sprintf("%dM", minutes)
+	provenance.WindowEnd = time.Now()
+	if !at.IsZero() {
+		provenance.WindowEnd = at
+	}
+	provenance.WindowStart = provenance.WindowEnd.Add(-time.Duration(minutes) * time.Minute)
+
+	rawPrices, sources := o.dexModuleManager.GetPricesFromDexModulesAtTime(base, target, uint64(minutes), at)
+
+	provenance.RawPriceCount = uint64(len(rawPrices))
+	if sourcesJson, err := json.Marshal(sources); err == nil {
+		provenance.Sources = string(sourcesJson)
+	}
 
 	if len(rawPrices) == 0 {
 		logger.WarnWithFields("ooo_api", "QueryAdhoc", "", "no prices found on DEXs for pair", logger.Fields{
@@ -47,7 +77,7 @@ func (o *OOOApi) QueryAdhoc(endpoint string, requestId string) (string, error) {
 			"target": target,
 		})
 
-		return "0", errors.New("no prices found on DEXs for pair")
+		return "0", provenance, errors.New("no prices found on DEXs for pair")
 	}
 
 	dMax := float64(1)
@@ -68,6 +98,15 @@ func (o *OOOApi) QueryAdhoc(endpoint string, requestId string) (string, error) {
 		outliersRemoved = rawPrices
 	}
 
+	provenance.DMax = dMax
+	provenance.Method = "mean"
+	if chauvenetUsed {
+		provenance.Method = "chauvenet"
+	}
+	if removedJson, err := json.Marshal(removedValues(rawPrices, outliersRemoved)); err == nil {
+		provenance.RemovedValues = string(removedJson)
+	}
+
 	// calculate mean from data set with outliers removed
 	for _, oR := range outliersRemoved {
 		p := big.NewFloat(oR)
@@ -79,11 +118,37 @@ func (o *OOOApi) QueryAdhoc(endpoint string, requestId string) (string, error) {
 	}
 
 	if total.Cmp(big.NewInt(0)) <= 0 {
-		return "", errors.New("cannot calculate mean, price is zero")
+		return "", provenance, errors.New("cannot calculate mean, price is zero")
 	}
 
 	meanPrice := new(big.Int).Div(total, big.NewInt(int64(priceCount)))
 
+	numDexs, numPools := countSources(sources)
+	meanPriceFloat, _ := utils.WeiToEther(meanPrice).Float64()
+	// the last served price is only relevant to the current price
+	lastPrice := float64(0)
+	if at.IsZero() {
+		lastPrice = o.getLastServedPrice(base, target)
+	}
+
+	// dispersion is checked across DEXs, after outliers are removed, so that a single anomalous
+	// swap cannot cause every request for the pair to be rejected
+	err = CheckAdhocGuards(o.guards, numDexs, numPools, dexPrices(sources, outliersRemoved), meanPriceFloat, lastPrice)
+
+	if err != nil {
+		logger.WarnWithFields("ooo_api", "QueryAdhoc", "CheckAdhocGuards", err.Error(), logger.Fields{
+			"requestId":  requestId,
+			"base":       base,
+			"target":     target,
+			"num_dexs":   numDexs,
+			"num_pools":  numPools,
+			"price":      meanPriceFloat,
+			"last_price": lastPrice,
+		})
+
+		return "", provenance, err
+	}
+
 	logger.Debug("ooo_api", "QueryAdhoc", "", "price stats", logger.Fields{
 		"base":               base,
 		"target":             target,
@@ -96,9 +161,32 @@ func (o *OOOApi) QueryAdhoc(endpoint string, requestId string) (string, error) {
 		"final_wei_mean":     meanPrice.String(),
 		"chauvenet_used":     chauvenetUsed,
 		"d_max":              dMax,
+		"num_dexs":           numDexs,
+		"num_pools":          numPools,
 	})
 
-	return meanPrice.String(), nil
+	provenance.Price = meanPrice.String()
+
+	return meanPrice.String(), provenance, nil
+}
+
+// removedValues returns the values in rawPrices which are not in kept
+func removedValues(rawPrices []float64, kept []float64) []float64 {
+	removed := make([]float64, 0)
+	keptCount := make(map[float64]int)
+	for _, k := range kept {
+		keptCount[k]++
+	}
+
+	for _, p := range rawPrices {
+		if keptCount[p] > 0 {
+			keptCount[p]--
+			continue
+		}
+		removed = append(removed, p)
+	}
+
+	return removed
 }
 
 func removeOutliersFromData(rawPrices []float64, dMax float64) ([]float64, float64, float64, bool) {
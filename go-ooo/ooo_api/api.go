@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"time"
 
 	"go-ooo/config"
 	"go-ooo/database"
+	"go-ooo/database/models"
 	"go-ooo/logger"
 	"go-ooo/ooo_api/dex"
 	"go-ooo/ooo_api/dex/modules/bsc_pancakeswap_v3"
@@ -21,18 +23,79 @@ import (
 	"go-ooo/ooo_api/dex/modules/xdai_honeyswap"
 )
 
+// PriceSource fetches data from the Finchains OoO API. Path is relative to the API's
+// base URL, e.g. currency/ETH/USD/avg/1H
+type PriceSource interface {
+	BaseURL() string
+	Get(path string) ([]byte, error)
+}
+
+// HttpPriceSource is the default PriceSource, querying the Finchains API over HTTP
+type HttpPriceSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+var _ PriceSource = &HttpPriceSource{}
+
+// NewHttpPriceSource returns a PriceSource for the API at baseURL. If client is nil, a
+// client with a 15 second timeout is used
+func NewHttpPriceSource(baseURL string, client *http.Client) *HttpPriceSource {
+	if client == nil {
+		client = &http.Client{
+			Timeout: 15 * time.Second,
+		}
+	}
+
+	return &HttpPriceSource{
+		baseURL: baseURL,
+		client:  client,
+	}
+}
+
+func (p *HttpPriceSource) BaseURL() string {
+	return p.baseURL
+}
+
+func (p *HttpPriceSource) Get(path string) ([]byte, error) {
+	req, err := http.NewRequest("GET", fmt.Sprint(p.baseURL, "/", path), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
 type OOOApi struct {
-	baseURL          string
-	client           *http.Client
+	priceSource      PriceSource
 	db               *database.DB
 	ctx              context.Context
 	dexModuleManager *dex.Manager
+	guards           config.AdhocGuardsConfig
+	sources          config.SourcesConfig
+	xfund            *xfundOracle
 }
 
 func NewApi(ctx context.Context, cfg *config.Config, db *database.DB) (*OOOApi, error) {
+	return NewApiWithClients(ctx, cfg, db, NewHttpPriceSource(cfg.Jobs.OooApiUrl, nil), dex.NewHttpSubgraphClient(nil))
+}
 
-	dexModuleManager := dex.NewDexManager(
-		ctx, cfg, db,
+// NewApiWithClients creates an OOOApi which queries Finchains using priceSource, and the
+// DEX subgraphs using subgraph
+func NewApiWithClients(ctx context.Context, cfg *config.Config, db *database.DB, priceSource PriceSource,
+	subgraph dex.SubgraphClient) (*OOOApi, error) {
+
+	dexModuleManager := dex.NewDexManagerWithClient(
+		ctx, cfg, db, subgraph,
 		eth_shibaswap.NewDexModule(ctx, cfg),
 		eth_sushiswap.NewDexModule(ctx, cfg),
 		eth_uniswap_v2.NewDexModule(ctx, cfg),
@@ -42,15 +105,22 @@ func NewApi(ctx context.Context, cfg *config.Config, db *database.DB) (*OOOApi,
 		xdai_honeyswap.NewDexModule(ctx, cfg),
 	)
 
-	return &OOOApi{
-		baseURL: cfg.Jobs.OooApiUrl,
-		client: &http.Client{
-			Timeout: 15 * time.Second,
-		},
+	api := &OOOApi{
+		priceSource:      priceSource,
 		db:               db,
 		ctx:              ctx,
 		dexModuleManager: dexModuleManager,
-	}, nil
+		guards:           cfg.Guards,
+		sources:          cfg.Sources,
+	}
+	api.xfund = newXfundOracle(cfg.XfundPrice, api)
+
+	return api, nil
+}
+
+// DexManager returns the DEX module manager, e.g. to replace chain clients
+func (o *OOOApi) DexManager() *dex.Manager {
+	return o.dexModuleManager
 }
 
 func (o *OOOApi) UpdateDexPairs() {
@@ -58,22 +128,36 @@ func (o *OOOApi) UpdateDexPairs() {
 	o.dexModuleManager.UpdateAllPairsMetaDataFromDexs()
 }
 
-func (o *OOOApi) RouteQuery(endpoint string, requestId string) (string, error) {
+func (o *OOOApi) UpdateChainBlockTimes() {
+	o.dexModuleManager.UpdateChainBlockTimes()
+}
+
+// RouteQuery routes AdHoc endpoints to the DEX aggregator. Standard endpoints are queried
+// using the pair's configured source policy
+func (o *OOOApi) RouteQuery(endpoint string, requestId string) (PriceResult, error) {
+	return o.RouteQueryAtTime(endpoint, requestId, time.Time{})
+}
+
+// RouteQueryAtTime is the same as RouteQuery, but DEX prices are calculated for the window
+// ending at the given time. Used to replay historical requests
+func (o *OOOApi) RouteQueryAtTime(endpoint string, requestId string, at time.Time) (PriceResult, error) {
 	isAdHoc, err := IsAdhoc(endpoint)
 
 	if err != nil {
-		return "", err
+		return PriceResult{}, err
 	}
 
 	logger.Debug("ooo_api", "RouteQuery", "route", "", logger.Fields{
 		"request_id": requestId,
 		"is_adhoc":   isAdHoc,
+		"at":         at,
 	})
 
 	if isAdHoc {
-		return o.QueryAdhoc(endpoint, requestId)
+		price, provenance, err := o.queryAdhoc(endpoint, requestId, at)
+		return PriceResult{Price: price, Source: SourceDex, Provenance: []models.PriceProvenance{provenance}}, err
 	} else {
-		return o.QueryFinchainsEndpoint(endpoint, requestId)
+		return o.queryWithSourcePolicy(endpoint, requestId, at)
 	}
 }
 
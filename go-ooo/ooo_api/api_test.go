@@ -0,0 +1,139 @@
+package ooo_api_test
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/logger"
+	"go-ooo/ooo_api"
+	"go-ooo/ooo_api/dex"
+	dextypes "go-ooo/ooo_api/dex/types"
+	"go-ooo/utils/httprecorder"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// block used to generate the DEX price queries in the fixtures
+const testBlock = 20000000
+
+// fakeChain implements chains.Client, returning a fixed block number
+type fakeChain struct {
+	block uint64
+}
+
+func (f fakeChain) BlockNumber(ctx context.Context) (uint64, error) {
+	return f.block, nil
+}
+
+func (f fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = new(big.Int).SetUint64(f.block)
+	}
+	return &types.Header{Number: number, Time: number.Uint64() * 12}, nil
+}
+
+// newTestApi returns an OOOApi using a new sqlite DB, with all HTTP queries replayed
+// from the synthetic fixtures in testdata/fixtures
+func newTestApi(t *testing.T) *ooo_api.OOOApi {
+	api, _ := newTestApiWithDb(t)
+	return api
+}
+
+// newTestApiWithDb returns an OOOApi as newTestApi, and its DB
+func newTestApiWithDb(t *testing.T) (*ooo_api.OOOApi, *database.DB) {
+	return newTestApiWithClients(t, testConfig(), nil, nil)
+}
+
+// testConfig returns the default config, with a placeholder Graph API key which is redacted
+// from the fixture URLs
+func testConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.ApiKeys.GraphNetwork = "test-key"
+	return cfg
+}
+
+// newTestApiWithClients returns an OOOApi using cfg and a new sqlite DB. If priceSource or
+// subgraph are nil, their HTTP queries are replayed from the fixtures in testdata/fixtures
+func newTestApiWithClients(t *testing.T, cfg *config.Config, priceSource ooo_api.PriceSource,
+	subgraph dex.SubgraphClient) (*ooo_api.OOOApi, *database.DB) {
+	logger.SetLogLevel("fatal")
+
+	cfg.Database.Dialect = "sqlite"
+	cfg.Database.Storage = filepath.Join(t.TempDir(), "go-ooo.sqlite")
+
+	db, err := database.NewDb(cfg)
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+
+	recorder := httprecorder.New(filepath.Join("testdata", "fixtures"), cfg.ApiKeys.GraphNetwork)
+
+	if priceSource == nil {
+		priceSource = ooo_api.NewHttpPriceSource(cfg.Jobs.OooApiUrl, recorder.Client())
+	}
+	if subgraph == nil {
+		subgraph = dex.NewHttpSubgraphClient(recorder.Client())
+	}
+
+	api, err := ooo_api.NewApiWithClients(context.Background(), cfg, db, priceSource, subgraph)
+	require.NoError(t, err)
+	for _, c := range []string{dextypes.ChainEth, dextypes.ChainPolygon, dextypes.ChainBsc, dextypes.ChainXdai, dextypes.ChainShibarium} {
+		require.NoError(t, api.DexManager().SetChainClient(c, fakeChain{block: testBlock}))
+	}
+
+	return api, db
+}
+
+// approvePendingTokens approves all token contracts as canonical, as an operator would after review
+func approvePendingTokens(t *testing.T, db *database.DB) {
+	pending, err := db.GetPendingTokens("")
+	require.NoError(t, err)
+	for _, token := range pending {
+		_, err = db.SetTokenCanonical(token.Chain, token.ContractAddress, true)
+		require.NoError(t, err)
+	}
+}
+
+func TestQueryFinchainsEndpoint(t *testing.T) {
+	api := newTestApi(t)
+	api.UpdateSupportedPairs()
+
+	price, err := api.QueryFinchainsEndpoint("ETH.USD.PR.AVG", "1")
+	require.NoError(t, err)
+	require.Equal(t, "3001450000000000000000", price)
+
+	_, err = api.QueryFinchainsEndpoint("XYZ.USD.PR.AVG", "2")
+	require.EqualError(t, err, "pair not currently supported")
+}
+
+func TestQueryAdhoc(t *testing.T) {
+	api, db := newTestApiWithDb(t)
+	api.UpdateDexPairs()
+
+	// pairs are not priced until their tokens have been approved
+	_, err := api.QueryAdhoc("WETH.USDC.AD.2", "1")
+	require.EqualError(t, err, "no prices found on DEXs for pair")
+
+	approvePendingTokens(t, db)
+
+	res, err := api.RouteQuery("WETH.USDC.AD.2", "1")
+	require.NoError(t, err)
+	require.Equal(t, ooo_api.SourceDex, res.Source)
+	require.Equal(t, "3000600000000000022737", res.Price)
+
+	require.Len(t, res.Provenance, 1)
+	require.Equal(t, uint64(6), res.Provenance[0].GetRawPriceCount())
+	require.Equal(t, "chauvenet", res.Provenance[0].GetMethod())
+}
+
+func TestQueryAdhocNoPairs(t *testing.T) {
+	api := newTestApi(t)
+	api.UpdateDexPairs()
+
+	_, err := api.QueryAdhoc("XYZ.USDC.AD.2", "1")
+	require.EqualError(t, err, "no prices found on DEXs for pair")
+}
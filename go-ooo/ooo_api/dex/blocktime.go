@@ -0,0 +1,28 @@
+package dex
+
+import (
+	"go-ooo/logger"
+)
+
+// UpdateChainBlockTimes samples recent headers for each chain and updates
+// the moving average block time used to calculate AdHoc block ranges
+func (dm *Manager) UpdateChainBlockTimes() {
+	for name, ch := range dm.chains {
+		sample, err := ch.SampleBlockTime(dm.ctx)
+
+		if err != nil {
+			logger.ErrorWithFields("dex", "UpdateChainBlockTimes", "sample block time", err.Error(), logger.Fields{
+				"chain": name,
+			})
+			continue
+		}
+
+		logger.Debug("dex", "UpdateChainBlockTimes", "", "block time sampled", logger.Fields{
+			"chain":                  name,
+			"sample_secs":            sample,
+			"avg_block_time_secs":    ch.GetAvgBlockTime(),
+			"blocks_per_min":         ch.GetBlocksPerMin(),
+			"default_blocks_per_min": ch.BlocksPerMin,
+		})
+	}
+}
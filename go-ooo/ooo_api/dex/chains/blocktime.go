@@ -0,0 +1,143 @@
+package chains
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"time"
+)
+
+const (
+	// BlockTimeSampleSize is the number of blocks between the two headers used to
+	// measure a single block time sample
+	BlockTimeSampleSize = 100
+	// BlockTimeWindow is the number of samples kept for the moving average
+	BlockTimeWindow = 12
+)
+
+// SampleBlockTime queries the latest header, and the header BlockTimeSampleSize blocks
+// before it, and adds the resulting average block time to the moving average
+func (c *ChainDef) SampleBlockTime(ctx context.Context) (float64, error) {
+	if c.EthClient == nil {
+		return 0, errors.New("no eth client for chain")
+	}
+
+	latest, err := c.EthClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if latest.Number.Uint64() <= BlockTimeSampleSize {
+		return 0, errors.New("not enough blocks to sample")
+	}
+
+	earlierNum := new(big.Int).Sub(latest.Number, big.NewInt(BlockTimeSampleSize))
+	earlier, err := c.EthClient.HeaderByNumber(ctx, earlierNum)
+	if err != nil {
+		return 0, err
+	}
+
+	if latest.Time <= earlier.Time {
+		return 0, errors.New("invalid header timestamps")
+	}
+
+	sample := float64(latest.Time-earlier.Time) / float64(BlockTimeSampleSize)
+
+	c.AddBlockTimeSample(sample)
+
+	return sample, nil
+}
+
+// AddBlockTimeSample adds a block time sample, in seconds, to the moving average window
+func (c *ChainDef) AddBlockTimeSample(sample float64) {
+	if sample <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blockTimeSamples = append(c.blockTimeSamples, sample)
+	if len(c.blockTimeSamples) > BlockTimeWindow {
+		c.blockTimeSamples = c.blockTimeSamples[len(c.blockTimeSamples)-BlockTimeWindow:]
+	}
+	c.lastSampled = time.Now()
+}
+
+// GetAvgBlockTime returns the moving average block time in seconds, or 0 if no
+// samples have been taken yet
+func (c *ChainDef) GetAvgBlockTime() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.blockTimeSamples) == 0 {
+		return 0
+	}
+
+	sum := float64(0)
+	for _, s := range c.blockTimeSamples {
+		sum += s
+	}
+
+	return sum / float64(len(c.blockTimeSamples))
+}
+
+// GetLastSampled returns the time the last block time sample was taken
+func (c *ChainDef) GetLastSampled() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastSampled
+}
+
+// GetBlocksPerMin returns the number of blocks per minute derived from the measured
+// average block time. Falls back to the hard-coded BlocksPerMin if no samples exist
+func (c *ChainDef) GetBlocksPerMin() uint64 {
+	avg := c.GetAvgBlockTime()
+	if avg <= 0 {
+		return uint64(c.BlocksPerMin)
+	}
+
+	bpm := uint64(math.Round(60 / avg))
+	if bpm == 0 {
+		bpm = 1
+	}
+
+	return bpm
+}
+
+// BlockNumberAtTime returns the number of the last block with a timestamp at or before
+// the given unix timestamp
+func (c *ChainDef) BlockNumberAtTime(ctx context.Context, ts uint64) (uint64, error) {
+	if c.EthClient == nil {
+		return 0, errors.New("no eth client for chain")
+	}
+
+	latest, err := c.EthClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if latest.Time <= ts {
+		return latest.Number.Uint64(), nil
+	}
+
+	lo := uint64(0)
+	hi := latest.Number.Uint64()
+
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		header, err := c.EthClient.HeaderByNumber(ctx, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, err
+		}
+
+		if header.Time <= ts {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	return lo, nil
+}
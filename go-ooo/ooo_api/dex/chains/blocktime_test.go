@@ -0,0 +1,147 @@
+package chains_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"go-ooo/ooo_api/dex/chains"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient returns headers with a fixed block time
+type fakeClient struct {
+	latest    uint64
+	blockTime uint64
+}
+
+func (f *fakeClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return f.latest, nil
+}
+
+func (f *fakeClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	num := f.latest
+	if number != nil {
+		num = number.Uint64()
+	}
+	if num > f.latest {
+		return nil, errors.New("not found")
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(num), Time: 1000000 + num*f.blockTime}, nil
+}
+
+func TestBlocksPerMinFallback(t *testing.T) {
+	c := &chains.ChainDef{BlocksPerMin: 4}
+
+	require.Equal(t, float64(0), c.GetAvgBlockTime())
+	require.Equal(t, uint64(4), c.GetBlocksPerMin())
+
+	// invalid samples are ignored
+	c.AddBlockTimeSample(0)
+	c.AddBlockTimeSample(-2)
+	require.Equal(t, uint64(4), c.GetBlocksPerMin())
+	require.True(t, c.GetLastSampled().IsZero())
+
+	// no client
+	_, err := c.SampleBlockTime(context.Background())
+	require.Error(t, err)
+	require.Equal(t, uint64(4), c.GetBlocksPerMin())
+}
+
+func TestBlockTimeMovingAverage(t *testing.T) {
+	c := &chains.ChainDef{BlocksPerMin: 4}
+
+	c.AddBlockTimeSample(2)
+	c.AddBlockTimeSample(4)
+	require.Equal(t, float64(3), c.GetAvgBlockTime())
+	require.Equal(t, uint64(20), c.GetBlocksPerMin())
+	require.False(t, c.GetLastSampled().IsZero())
+
+	// only the last BlockTimeWindow samples are averaged
+	for i := 0; i < chains.BlockTimeWindow; i++ {
+		c.AddBlockTimeSample(12)
+	}
+	require.Equal(t, float64(12), c.GetAvgBlockTime())
+	require.Equal(t, uint64(5), c.GetBlocksPerMin())
+
+	// very slow chains have at least one block per min
+	for i := 0; i < chains.BlockTimeWindow; i++ {
+		c.AddBlockTimeSample(600)
+	}
+	require.Equal(t, uint64(1), c.GetBlocksPerMin())
+}
+
+func TestSampleBlockTime(t *testing.T) {
+	c := &chains.ChainDef{BlocksPerMin: 4, EthClient: &fakeClient{latest: 50, blockTime: 3}}
+
+	// not enough blocks
+	_, err := c.SampleBlockTime(context.Background())
+	require.Error(t, err)
+
+	c.EthClient = &fakeClient{latest: 5000, blockTime: 3}
+	sample, err := c.SampleBlockTime(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, float64(3), sample)
+	require.Equal(t, uint64(20), c.GetBlocksPerMin())
+}
+
+func TestBlockNumberAtTime(t *testing.T) {
+	backend := simulated.NewBackend(types.GenesisAlloc{})
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+
+	// mine blocks with irregular gaps between their timestamps
+	client := backend.Client()
+	for _, gap := range []time.Duration{12, 12, 30, 5, 60, 12, 1, 12} {
+		require.NoError(t, backend.AdjustTime(gap*time.Second))
+		backend.Commit()
+	}
+
+	ctx := context.Background()
+	latest, err := client.BlockNumber(ctx)
+	require.NoError(t, err)
+
+	headers := make([]*types.Header, latest+1)
+	for i := range headers {
+		headers[i], err = client.HeaderByNumber(ctx, big.NewInt(int64(i)))
+		require.NoError(t, err)
+	}
+
+	c := &chains.ChainDef{BlocksPerMin: 5, EthClient: client}
+
+	for i := uint64(1); i <= latest; i++ {
+		// exactly the block's time
+		num, err := c.BlockNumberAtTime(ctx, headers[i].Time)
+		require.NoError(t, err)
+		require.Equal(t, i, num, "block %d", i)
+
+		// between the previous block and this one
+		if headers[i].Time-headers[i-1].Time > 1 {
+			num, err = c.BlockNumberAtTime(ctx, headers[i].Time-1)
+			require.NoError(t, err)
+			require.Equal(t, i-1, num, "before block %d", i)
+		}
+	}
+
+	// after the latest block
+	num, err := c.BlockNumberAtTime(ctx, headers[latest].Time+3600)
+	require.NoError(t, err)
+	require.Equal(t, latest, num)
+
+	// at or before genesis
+	num, err = c.BlockNumberAtTime(ctx, headers[0].Time)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), num)
+}
+
+func TestBlockNumberAtTimeNoClient(t *testing.T) {
+	c := &chains.ChainDef{BlocksPerMin: 5}
+	_, err := c.BlockNumberAtTime(context.Background(), 1000)
+	require.EqualError(t, err, "no eth client for chain")
+}
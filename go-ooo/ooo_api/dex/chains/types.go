@@ -1,12 +1,29 @@
 package chains
 
-import "github.com/ethereum/go-ethereum/ethclient"
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Client is the subset of the eth client used to query block numbers and headers
+type Client interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
 
 type ChainDef struct {
 	ChainShort   string
 	ChainName    string
 	ChainId      string
-	BlocksPerMin int
+	BlocksPerMin int // default, used until the block time has been measured
 	RpcUrl       string
-	EthClient    *ethclient.Client
+	EthClient    Client
+
+	mu               sync.RWMutex
+	blockTimeSamples []float64
+	lastSampled      time.Time
 }
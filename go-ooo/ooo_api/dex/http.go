@@ -9,14 +9,43 @@ import (
 	"time"
 )
 
-func runQuery(query []byte, url string) ([]byte, error) {
+// SubgraphClient runs queries against DEX subgraphs. A nil query is sent as a GET request,
+// and is used to fetch the pair metadata files
+type SubgraphClient interface {
+	Query(query []byte, url string) ([]byte, error)
+}
+
+// HttpSubgraphClient is the default SubgraphClient, sending queries over HTTP
+type HttpSubgraphClient struct {
+	client *http.Client
+}
+
+var _ SubgraphClient = &HttpSubgraphClient{}
+
+// NewHttpSubgraphClient returns a SubgraphClient using the given http.Client. If client
+// is nil, a client with a 60 second timeout is used
+func NewHttpSubgraphClient(client *http.Client) *HttpSubgraphClient {
+	if client == nil {
+		client = &http.Client{
+			Timeout: 60 * time.Second,
+		}
+	}
+
+	return &HttpSubgraphClient{
+		client: client,
+	}
+}
+
+func (c *HttpSubgraphClient) Query(query []byte, url string) ([]byte, error) {
 
 	var req *http.Request
 	var err error
 
 	if query != nil {
 		req, err = http.NewRequest("POST", url, bytes.NewBuffer(query))
-		req.Header.Set("Content-Type", "application/json")
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 	} else {
 		req, err = http.NewRequest("GET", url, nil)
 	}
@@ -25,11 +54,7 @@ func runQuery(query []byte, url string) ([]byte, error) {
 		return nil, err
 	}
 
-	httpClient := http.Client{
-		Timeout: 60 * time.Second,
-	}
-
-	resp, err := httpClient.Do(req)
+	resp, err := c.client.Do(req)
 
 	if err != nil {
 		return nil, err
@@ -2,10 +2,10 @@ package dex
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"go-ooo/config"
 	"go-ooo/logger"
-	"net/http"
-	"time"
 
 	"go-ooo/database"
 	"go-ooo/ooo_api/dex/chains"
@@ -22,20 +22,28 @@ type Module interface {
 	GeneratePairsQuery(contractAddresses string) ([]byte, error)
 	ProcessPairsQueryResult(result []byte) ([]types.DexPair, error)
 	GenerateDexPricesQuery(pairContractAddress string, minutes, currentBlock, blocksPerMin uint64) ([]byte, uint64, error)
-	ProcessDexPricesResult(base, target string, numQueries uint64, result []byte) ([]float64, error)
+	// ProcessDexPricesResult returns the prices in the result, and the contract addresses of the
+	// pools which returned a price
+	ProcessDexPricesResult(base, target string, numQueries uint64, result []byte) ([]float64, []string, error)
 }
 
 type Manager struct {
-	ctx        context.Context
-	cfg        *config.Config
-	db         *database.DB
-	httpClient *http.Client
+	ctx      context.Context
+	cfg      *config.Config
+	db       *database.DB
+	subgraph SubgraphClient
 
 	chains  map[string]*chains.ChainDef
 	modules map[string]Module
 }
 
 func NewDexManager(ctx context.Context, cfg *config.Config, db *database.DB, modules ...Module) *Manager {
+	return NewDexManagerWithClient(ctx, cfg, db, NewHttpSubgraphClient(nil), modules...)
+}
+
+// NewDexManagerWithClient creates a Manager which runs all subgraph and pair metadata
+// queries using the given SubgraphClient
+func NewDexManagerWithClient(ctx context.Context, cfg *config.Config, db *database.DB, subgraph SubgraphClient, modules ...Module) *Manager {
 	moduleMap := make(map[string]Module)
 	chainMap := make(map[string]*chains.ChainDef)
 
@@ -61,14 +69,22 @@ func NewDexManager(ctx context.Context, cfg *config.Config, db *database.DB, mod
 	}
 
 	return &Manager{
-		ctx: ctx,
-		cfg: cfg,
-		db:  db,
-		httpClient: &http.Client{
-			Timeout: 15 * time.Second,
-		},
+		ctx:      ctx,
+		cfg:      cfg,
+		db:       db,
+		subgraph: subgraph,
 
 		chains:  chainMap,
 		modules: moduleMap,
 	}
 }
+
+// SetChainClient replaces the client used to query block numbers and headers for a chain
+func (dm *Manager) SetChainClient(chain string, client chains.Client) error {
+	ch, ok := dm.chains[chain]
+	if !ok {
+		return errors.New(fmt.Sprintf("chain %s not supported", chain))
+	}
+	ch.EthClient = client
+	return nil
+}
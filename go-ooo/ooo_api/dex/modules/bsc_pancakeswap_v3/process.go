@@ -24,7 +24,7 @@ func (d DexModule) processPairs(result []byte) ([]types.DexPair, error) {
 		return nil, errors.New(fmt.Sprintf("Error from GraphQL API: %s", decodedResponse.Errors[0].Message))
 	}
 
-	for _, pair := range decodedResponse.Data.Pairs {
+	for _, pair := range decodedResponse.Data.Pools {
 		standardPair := types.DexPair{
 			Id:       pair.Id,
 			Contract: pair.Id,
@@ -61,20 +61,23 @@ func (d DexModule) processPairs(result []byte) ([]types.DexPair, error) {
 	return pairs, nil
 }
 
-func (d DexModule) processPrices(base, target string, numQueries uint64, result []byte) ([]float64, error) {
+// processPrices returns the prices in the result, and the pools which returned a price
+func (d DexModule) processPrices(base, target string, numQueries uint64, result []byte) ([]float64, []string, error) {
 	var decodedResponse map[string]any
 	var prices []float64
+	var pools []string
+	seenPools := make(map[string]bool)
 
 	err := json.Unmarshal(result, &decodedResponse)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if decodedResponse["errors"] != nil {
 		retErrors := decodedResponse["errors"].([]interface{})
 		retErr := retErrors[0].(map[string]any)
-		return nil, errors.New(retErr["message"].(string))
+		return nil, nil, errors.New(retErr["message"].(string))
 	}
 
 	pairPricesRes := decodedResponse["data"].(map[string]any)
@@ -85,15 +88,19 @@ func (d DexModule) processPrices(base, target string, numQueries uint64, result
 			for _, pInst := range priceResArray {
 				price, err := d.getPrice(base, target, pInst.(map[string]any))
 				if err != nil {
-					return prices, err
+					return prices, pools, err
 				}
 				if price > 0 {
 					prices = append(prices, price)
+					if id, ok := pInst.(map[string]any)["id"].(string); ok && !seenPools[id] {
+						seenPools[id] = true
+						pools = append(pools, id)
+					}
 				}
 			}
 		}
 	}
-	return prices, nil
+	return prices, pools, nil
 }
 
 func (d DexModule) getPrice(base string, target string, pair map[string]any) (float64, error) {
@@ -35,7 +35,7 @@ type GraphQlPairContent struct {
 }
 
 type GraphQlPairs struct {
-	Pairs []GraphQlPairContent `json:"pairs,omitempty"`
+	Pools []GraphQlPairContent `json:"pools,omitempty"`
 }
 
 type GraphQlErrors struct {
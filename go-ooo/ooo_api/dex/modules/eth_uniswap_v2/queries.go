@@ -3,6 +3,7 @@ package eth_uniswap_v2
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -97,6 +98,8 @@ func (d DexModule) generatePricesQuery(pairContractAddress string, minutes, curr
 		q := fmt.Sprintf("%s: %s", i, s)
 		qs = append(qs, q)
 	}
+	// keep the query stable, so that recorded fixtures match
+	sort.Strings(qs)
 
 	jsonData := map[string]string{
 		"query": fmt.Sprintf(`{%s}`, strings.Join(qs, ",")),
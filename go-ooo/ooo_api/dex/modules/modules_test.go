@@ -0,0 +1,188 @@
+package modules_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-ooo/config"
+	"go-ooo/ooo_api/dex"
+	"go-ooo/ooo_api/dex/modules/bsc_pancakeswap_v3"
+	"go-ooo/ooo_api/dex/modules/eth_shibaswap"
+	"go-ooo/ooo_api/dex/modules/eth_sushiswap"
+	"go-ooo/ooo_api/dex/modules/eth_uniswap_v2"
+	"go-ooo/ooo_api/dex/modules/eth_uniswap_v3"
+	"go-ooo/ooo_api/dex/modules/polygon_pos_quickswap_v3"
+	"go-ooo/ooo_api/dex/modules/xdai_honeyswap"
+	"go-ooo/utils/httprecorder"
+
+	"github.com/stretchr/testify/require"
+)
+
+// block used to generate the price queries in the fixtures
+const (
+	testBlock        = 20000000
+	testBlocksPerMin = 5
+	testMinutes      = 2
+)
+
+type moduleTest struct {
+	module dex.Module
+	pool   string
+	base   string
+	target string
+	token0 string
+	token1 string
+	prices []float64 // base/target prices in the synthetic fixture, latest first
+}
+
+// placeholder Graph API key, redacted from the fixture URLs
+const graphApiKey = "test-key"
+
+func moduleTests() []moduleTest {
+	ctx := context.Background()
+	cfg := config.DefaultConfig()
+	cfg.ApiKeys.GraphNetwork = graphApiKey
+
+	return []moduleTest{
+		{
+			module: eth_uniswap_v2.NewDexModule(ctx, cfg),
+			pool:   "0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc",
+			base:   "WETH", target: "USDC", token0: "WETH", token1: "USDC",
+			prices: []float64{3001.25, 3000.5, 2999.75},
+		},
+		{
+			module: eth_sushiswap.NewDexModule(ctx, cfg),
+			pool:   "0x397ff1542f962076d0bfe58ea045ffa2d347aca0",
+			base:   "WETH", target: "USDC", token0: "WETH", token1: "USDC",
+			prices: []float64{3002.1, 3000.9, 2999.2},
+		},
+		{
+			module: eth_shibaswap.NewDexModule(ctx, cfg),
+			pool:   "0x24d3dd4a62e29770cf98810b09f89d3a90279e7a",
+			base:   "SHIB", target: "WETH", token0: "SHIB", token1: "WETH",
+			prices: []float64{0.0000000062, 0.0000000061, 0.0000000063},
+		},
+		{
+			module: eth_uniswap_v3.NewDexModule(ctx, cfg),
+			pool:   "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640",
+			base:   "WETH", target: "USDC", token0: "USDC", token1: "WETH",
+			prices: []float64{3000.8, 3000.1, 2999.9},
+		},
+		{
+			module: polygon_pos_quickswap_v3.NewDexModule(ctx, cfg),
+			pool:   "0xa374094527e1673a86de625aa59517c5de346d32",
+			base:   "WMATIC", target: "USDC", token0: "WMATIC", token1: "USDC",
+			prices: []float64{0.5213, 0.5209, 0.5217},
+		},
+		{
+			module: bsc_pancakeswap_v3.NewDexModule(ctx, cfg),
+			pool:   "0x36696169c63e42cd08ce11f5deebbcebae652050",
+			base:   "WBNB", target: "USDT", token0: "USDT", token1: "WBNB",
+			prices: []float64{590.12, 589.87, 590.4},
+		},
+		{
+			module: xdai_honeyswap.NewDexModule(ctx, cfg),
+			pool:   "0x4505b262dc053998c10685dc5f9098af8ae5c8ad",
+			base:   "HNY", target: "WXDAI", token0: "WXDAI", token1: "HNY",
+			prices: []float64{20.45, 20.51, 20.38},
+		},
+	}
+}
+
+func subgraphClient(name string) dex.SubgraphClient {
+	recorder := httprecorder.New(filepath.Join("testdata", name), graphApiKey)
+	return dex.NewHttpSubgraphClient(recorder.Client())
+}
+
+func TestProcessPairs(t *testing.T) {
+	for _, tt := range moduleTests() {
+		t.Run(tt.module.Name(), func(t *testing.T) {
+			query, err := tt.module.GeneratePairsQuery(fmt.Sprintf(`"%s"`, tt.pool))
+			require.NoError(t, err)
+
+			res, err := subgraphClient(tt.module.Name()).Query(query, tt.module.SubgraphUrl())
+			require.NoError(t, err)
+
+			pairs, err := tt.module.ProcessPairsQueryResult(res)
+			require.NoError(t, err)
+			require.Len(t, pairs, 1)
+
+			require.Equal(t, tt.pool, pairs[0].Contract)
+			require.Equal(t, tt.token0, pairs[0].Token0.Symbol)
+			require.Equal(t, tt.token1, pairs[0].Token1.Symbol)
+			require.NotEmpty(t, pairs[0].ReserveUSD)
+			require.NotEmpty(t, pairs[0].TxCount)
+		})
+	}
+}
+
+func TestProcessPrices(t *testing.T) {
+	for _, tt := range moduleTests() {
+		t.Run(tt.module.Name(), func(t *testing.T) {
+			query, numQueries, err := tt.module.GenerateDexPricesQuery(fmt.Sprintf(`"%s"`, tt.pool), testMinutes, testBlock, testBlocksPerMin)
+			require.NoError(t, err)
+			require.Equal(t, uint64(testMinutes+1), numQueries)
+
+			res, err := subgraphClient(tt.module.Name()).Query(query, tt.module.SubgraphUrl())
+			require.NoError(t, err)
+
+			prices, pools, err := tt.module.ProcessDexPricesResult(tt.base, tt.target, numQueries, res)
+			require.NoError(t, err)
+			require.Equal(t, []string{strings.ToLower(tt.pool)}, pools)
+			require.Len(t, prices, len(tt.prices))
+			for i, p := range tt.prices {
+				require.InEpsilon(t, p, prices[i], 1e-9)
+			}
+
+			// reversed pair returns the inverse price
+			prices, _, err = tt.module.ProcessDexPricesResult(tt.target, tt.base, numQueries, res)
+			require.NoError(t, err)
+			require.Len(t, prices, len(tt.prices))
+			for i, p := range tt.prices {
+				require.InEpsilon(t, 1/p, prices[i], 1e-6)
+			}
+		})
+	}
+}
+
+func TestProcessGraphQlError(t *testing.T) {
+	res := []byte(`{"errors":[{"message":"indexing_error"}]}`)
+
+	for _, tt := range moduleTests() {
+		t.Run(tt.module.Name(), func(t *testing.T) {
+			_, err := tt.module.ProcessPairsQueryResult(res)
+			require.Error(t, err)
+
+			_, _, err = tt.module.ProcessDexPricesResult(tt.base, tt.target, 1, res)
+			require.EqualError(t, err, "indexing_error")
+		})
+	}
+}
+
+// the v3 subgraphs return pairs in the pools field. PancakeSwap v3 and QuickSwap v3 previously
+// decoded the pairs field, so no pairs were ever found for them, without an error
+func TestProcessPairsV3Pools(t *testing.T) {
+	res := []byte(`{"data":{"pools":[{"id":"0x1","token0":{"id":"0xa","name":"Token A","symbol":"A"},` +
+		`"token1":{"id":"0xb","name":"Token B","symbol":"B"},"totalValueLockedUSD":"1000","txCount":"10"}]}}`)
+
+	ctx := context.Background()
+	cfg := config.DefaultConfig()
+
+	for _, module := range []dex.Module{
+		eth_uniswap_v3.NewDexModule(ctx, cfg),
+		polygon_pos_quickswap_v3.NewDexModule(ctx, cfg),
+		bsc_pancakeswap_v3.NewDexModule(ctx, cfg),
+	} {
+		t.Run(module.Name(), func(t *testing.T) {
+			pairs, err := module.ProcessPairsQueryResult(res)
+			require.NoError(t, err)
+			require.Len(t, pairs, 1)
+			require.Equal(t, "0x1", pairs[0].Contract)
+			require.Equal(t, "A", pairs[0].Token0.Symbol)
+			require.Equal(t, "B", pairs[0].Token1.Symbol)
+		})
+	}
+}
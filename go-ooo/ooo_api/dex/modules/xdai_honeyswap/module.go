@@ -68,6 +68,6 @@ func (d DexModule) GenerateDexPricesQuery(pairContractAddress string, minutes, c
 	return d.generatePricesQuery(pairContractAddress, minutes, currentBlock, blocksPerMin)
 }
 
-func (d DexModule) ProcessDexPricesResult(base, target string, numQueries uint64, result []byte) ([]float64, error) {
+func (d DexModule) ProcessDexPricesResult(base, target string, numQueries uint64, result []byte) ([]float64, []string, error) {
 	return d.processPrices(base, target, numQueries, result)
 }
@@ -19,7 +19,7 @@ func (dm *Manager) GetSupportedPairs() {
 			"url": dataUrl,
 		})
 
-		res, err := runQuery(nil, dataUrl)
+		res, err := dm.subgraph.Query(nil, dataUrl)
 
 		if err != nil {
 			logger.ErrorWithFields("dex", "GetSupportedPairs", "run refresh pairs query", err.Error(), logger.Fields{
@@ -77,7 +77,7 @@ func (dm *Manager) UpdateAllPairsMetaDataFromDexs() {
 			continue
 		}
 
-		res, err := runQuery(query, module.SubgraphUrl())
+		res, err := dm.subgraph.Query(query, module.SubgraphUrl())
 
 		if err != nil {
 			logger.ErrorWithFields("dex", "UpdateAllPairsMetaDataFromDexs", "run pairs query", err.Error(), logger.Fields{
@@ -108,3 +108,14 @@ func (dm *Manager) UpdateAllPairsMetaDataFromDexs() {
 		dm.updatePairsInDb(pairs, module.Chain(), module.Dex())
 	}
 }
+
+// HasPair returns true if the base/target pair exists on at least one supported DEX
+func (dm *Manager) HasPair(base, target string) bool {
+	for _, module := range dm.modules {
+		dbPairRes, _ := dm.db.FindByDexPairName(base, target, module.Chain(), module.Dex())
+		if len(dbPairRes) > 0 {
+			return true
+		}
+	}
+	return false
+}
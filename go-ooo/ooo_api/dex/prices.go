@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"go-ooo/logger"
 )
@@ -15,13 +16,23 @@ type DexInfo struct {
 }
 
 type DexResult struct {
-	Chain  string
-	Dex    string
-	Prices []float64
+	Chain  string    `json:"chain"`
+	Dex    string    `json:"dex"`
+	Pools  []string  `json:"pools"` // pools which returned prices
+	Prices []float64 `json:"prices"`
 }
 
-func (dm *Manager) GetPricesFromDexModules(base, target string, minutes uint64) []float64 {
+// GetPricesFromDexModules queries all DEXs with valid pairs for the base/target, and returns
+// the combined raw prices, along with the results from each DEX that returned prices
+func (dm *Manager) GetPricesFromDexModules(base, target string, minutes uint64) ([]float64, []DexResult) {
+	return dm.GetPricesFromDexModulesAtTime(base, target, minutes, time.Time{})
+}
+
+// GetPricesFromDexModulesAtTime queries DEX prices for the window ending at the given time,
+// using the block on each DEX's chain at that time. A zero time uses the current block
+func (dm *Manager) GetPricesFromDexModulesAtTime(base, target string, minutes uint64, at time.Time) ([]float64, []DexResult) {
 	var prices []float64
+	var sources []DexResult
 
 	resCh := make(chan DexResult)
 	errCh := make(chan error)
@@ -41,8 +52,14 @@ func (dm *Manager) GetPricesFromDexModules(base, target string, minutes uint64)
 			"minutes": minutes,
 		})
 
-		currentBlock, err := dm.chains[module.Chain()].EthClient.BlockNumber(dm.ctx)
-		blocksPerMin := uint64(dm.chains[module.Chain()].BlocksPerMin)
+		var currentBlock uint64
+		var err error
+		if at.IsZero() {
+			currentBlock, err = dm.chains[module.Chain()].EthClient.BlockNumber(dm.ctx)
+		} else {
+			currentBlock, err = dm.chains[module.Chain()].BlockNumberAtTime(dm.ctx, uint64(at.Unix()))
+		}
+		blocksPerMin := dm.chains[module.Chain()].GetBlocksPerMin()
 
 		if err != nil {
 			logger.ErrorWithFields("dex", "GetPricesFromDexModules", "get current block", err.Error(), logger.Fields{
@@ -120,7 +137,7 @@ func (dm *Manager) GetPricesFromDexModules(base, target string, minutes uint64)
 
 		validMods[module.Name()] = dexInfo
 
-		go getPrices(module, base, target, minutes, dexInfo, resCh, errCh)
+		go getPrices(dm.subgraph, module, base, target, minutes, dexInfo, resCh, errCh)
 	}
 
 	for _ = range validMods {
@@ -144,6 +161,7 @@ func (dm *Manager) GetPricesFromDexModules(base, target string, minutes uint64)
 
 			if len(r.Prices) > 0 {
 				prices = append(prices, r.Prices...)
+				sources = append(sources, r)
 				dexSuccess++
 			} else {
 				dexNoData++
@@ -162,10 +180,10 @@ func (dm *Manager) GetPricesFromDexModules(base, target string, minutes uint64)
 			"num_prices":  len(prices),
 		})
 
-	return prices
+	return prices, sources
 }
 
-func getPrices(module Module, base, target string, minutes uint64, dexInfo DexInfo, resCh chan<- DexResult, errCh chan<- error) {
+func getPrices(subgraph SubgraphClient, module Module, base, target string, minutes uint64, dexInfo DexInfo, resCh chan<- DexResult, errCh chan<- error) {
 	query, numQueries, err := module.GenerateDexPricesQuery(dexInfo.ContractAddresses, minutes, dexInfo.CurrentBlock, dexInfo.BlockPerMin)
 	if err != nil {
 		errMsg := fmt.Sprintf(`%s, %s, %s, %s. getPrices generate query error: %s`, module.Chain(), module.Dex(), base, target, err.Error())
@@ -174,7 +192,7 @@ func getPrices(module Module, base, target string, minutes uint64, dexInfo DexIn
 		return
 	}
 
-	dexResult, err := runQuery(query, module.SubgraphUrl())
+	dexResult, err := subgraph.Query(query, module.SubgraphUrl())
 	if err != nil {
 		errMsg := fmt.Sprintf(`%s, %s, %s, %s. getPrices run query error: %s`, module.Chain(), module.Dex(), base, target, err.Error())
 		resCh <- DexResult{}
@@ -182,7 +200,7 @@ func getPrices(module Module, base, target string, minutes uint64, dexInfo DexIn
 		return
 	}
 
-	dexPrices, err := module.ProcessDexPricesResult(base, target, numQueries, dexResult)
+	dexPrices, pricedPools, err := module.ProcessDexPricesResult(base, target, numQueries, dexResult)
 
 	if err != nil {
 		errMsg := fmt.Sprintf(`%s, %s, %s, %s. getPrices process query results error: %s`, module.Chain(), module.Dex(), base, target, err.Error())
@@ -194,6 +212,7 @@ func getPrices(module Module, base, target string, minutes uint64, dexInfo DexIn
 	resCh <- DexResult{
 		Chain:  module.Chain(),
 		Dex:    module.Dex(),
+		Pools:  pricedPools,
 		Prices: dexPrices,
 	}
 	errCh <- nil
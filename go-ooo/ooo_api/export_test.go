@@ -0,0 +1,8 @@
+package ooo_api
+
+var DexPrices = dexPrices
+
+// GetLastServedPrice exposes getLastServedPrice for testing
+func (o *OOOApi) GetLastServedPrice(base, target string) float64 {
+	return o.getLastServedPrice(base, target)
+}
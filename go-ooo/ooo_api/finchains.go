@@ -4,21 +4,35 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-ooo/database/models"
 	"go-ooo/logger"
-	"io/ioutil"
-	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 func (o *OOOApi) QueryFinchainsEndpoint(endpoint string, requestId string) (string, error) {
+	price, _, err := o.queryFinchains(endpoint, requestId)
+	return price, err
+}
+
+// queryFinchains queries the Finchains API, and returns the provenance record containing
+// the query and raw response
+func (o *OOOApi) queryFinchains(endpoint string, requestId string) (string, models.PriceProvenance, error) {
+	provenance := models.PriceProvenance{
+		RequestId: requestId,
+		Endpoint:  endpoint,
+		Source:    SourceFinchains,
+	}
+
 	// check valid
 
 	uri, err := o.buildQuery(endpoint)
 
 	if err != nil {
-		return "", err
+		return "", provenance, err
 	}
 
 	logger.Debug("ooo_api", "QueryFinchainsEndpoint", "buildQuery", "OoO API query built", logger.Fields{
@@ -27,57 +41,51 @@ func (o *OOOApi) QueryFinchainsEndpoint(endpoint string, requestId string) (stri
 		"uri":       uri,
 	})
 
-	req, err := http.NewRequest("GET", fmt.Sprint(o.baseURL, "/", uri), nil)
-
-	if err != nil {
-		return "", err
+	_, _, _, subtype, supp1, _, _, _ := ParseEndpoint(endpoint)
+	provenance.Window = cleanseTime(supp1)
+	if subtype == "LAT" {
+		provenance.Window = "latest"
 	}
+	sourcesJson, _ := json.Marshal([]string{fmt.Sprint(o.priceSource.BaseURL(), "/", uri)})
+	provenance.Sources = string(sourcesJson)
 
-	resp, err := o.client.Do(req)
+	body, err := o.priceSource.Get(uri)
 
 	if err != nil {
-		return "", err
+		return "", provenance, err
 	}
 
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		return "", err
+	provenance.Response = string(body)
+	provenance.WindowEnd = time.Now()
+	if windowDuration, err := time.ParseDuration(strings.ToLower(provenance.Window)); err == nil {
+		provenance.WindowStart = provenance.WindowEnd.Add(-windowDuration)
 	}
 
 	var result OoOAPIPriceQueryResult
 
 	err = json.Unmarshal(body, &result)
 	if err != nil {
-		return "", err
+		return "", provenance, err
 	}
 
-	return result.Price, nil
+	provenance.Price = result.Price
+	provenance.Method = result.OutlierMethod
+	provenance.DMax = float64(result.Dmax)
+
+	return result.Price, provenance, nil
 }
 
 func (o *OOOApi) UpdateSupportedPairs() {
 
 	logger.Info("ooo_api", "UpdateSupportedPairs", "", "begin update supported pairs")
 
-	req, err := http.NewRequest("GET", fmt.Sprint(o.baseURL, "/pairs"), nil)
-
-	if err != nil {
-		logger.Error("ooo_api", "UpdateSupportedPairs", "generate http request", err.Error())
-		return
-	}
-
-	resp, err := o.client.Do(req)
+	body, err := o.priceSource.Get("pairs")
 
 	if err != nil {
 		logger.Error("ooo_api", "UpdateSupportedPairs", "run http request", err.Error())
 		return
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-
 	var result []OoOAPIPairsResult
 
 	err = json.Unmarshal(body, &result)
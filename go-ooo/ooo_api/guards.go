@@ -0,0 +1,129 @@
+package ooo_api
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"go-ooo/config"
+	"go-ooo/ooo_api/dex"
+	"go-ooo/utils"
+
+	"github.com/montanaflynn/stats"
+)
+
+// PriceRejectedError is returned when an AdHoc price fails one of the manipulation guards
+type PriceRejectedError struct {
+	Reason string
+}
+
+func (e *PriceRejectedError) Error() string {
+	return fmt.Sprintf("price rejected: %s", e.Reason)
+}
+
+// countSources returns the number of distinct DEXs and pools which returned prices. Pools which
+// were queried but returned no prices are not counted
+func countSources(sources []dex.DexResult) (uint64, uint64) {
+	dexs := make(map[string]bool)
+	pools := make(map[string]bool)
+	for _, s := range sources {
+		if len(s.Prices) == 0 {
+			continue
+		}
+		dexs[fmt.Sprintf("%s-%s", s.Chain, s.Dex)] = true
+		for _, p := range s.Pools {
+			pools[fmt.Sprintf("%s-%s", s.Chain, p)] = true
+		}
+	}
+	return uint64(len(dexs)), uint64(len(pools))
+}
+
+// dexPrices returns the mean price from each DEX, using only the prices kept after outliers were
+// removed, so that a single anomalous swap does not dominate the dispersion between DEXs
+func dexPrices(sources []dex.DexResult, kept []float64) []float64 {
+	keptCount := make(map[float64]int)
+	for _, k := range kept {
+		keptCount[k]++
+	}
+
+	prices := make([]float64, 0, len(sources))
+	for _, s := range sources {
+		dexKept := make([]float64, 0, len(s.Prices))
+		for _, p := range s.Prices {
+			if keptCount[p] > 0 {
+				keptCount[p]--
+				dexKept = append(dexKept, p)
+			}
+		}
+		if len(dexKept) == 0 {
+			continue
+		}
+		mean, _ := stats.Mean(dexKept)
+		prices = append(prices, mean)
+	}
+
+	return prices
+}
+
+// CheckAdhocGuards checks the final price against the configured guards. prices are the mean
+// prices from each DEX, after outliers are removed. lastPrice is the last price served for the
+// pair, or 0 if there is none within the deviation window
+func CheckAdhocGuards(guards config.AdhocGuardsConfig, numDexs, numPools uint64, prices []float64,
+	price float64, lastPrice float64) error {
+
+	if numDexs < guards.MinDexs {
+		return &PriceRejectedError{Reason: fmt.Sprintf("prices from %d DEXs, minimum %d", numDexs, guards.MinDexs)}
+	}
+
+	if numPools < guards.MinPools {
+		return &PriceRejectedError{Reason: fmt.Sprintf("prices from %d pools, minimum %d", numPools, guards.MinPools)}
+	}
+
+	if guards.MaxDispersion > 0 && len(prices) > 1 {
+		mean, _ := stats.Mean(prices)
+		stdDev, _ := stats.StandardDeviation(prices)
+		if mean > 0 {
+			dispersion := stdDev / mean
+			if dispersion > guards.MaxDispersion {
+				return &PriceRejectedError{Reason: fmt.Sprintf("price dispersion %.4f exceeds %.4f", dispersion, guards.MaxDispersion)}
+			}
+		}
+	}
+
+	if guards.MaxDeviation > 0 && lastPrice > 0 {
+		deviation := math.Abs(price-lastPrice) / lastPrice
+		if deviation > guards.MaxDeviation {
+			return &PriceRejectedError{Reason: fmt.Sprintf("deviation %.4f from last served price exceeds %.4f", deviation, guards.MaxDeviation)}
+		}
+	}
+
+	return nil
+}
+
+// getLastServedPrice returns the last price served for the pair, if it was served within
+// the deviation window. A window of 0 has no time limit. Returns 0 otherwise
+func (o *OOOApi) getLastServedPrice(base, target string) float64 {
+	if o.guards.MaxDeviation <= 0 {
+		return 0
+	}
+
+	last, err := o.db.GetLastServedAdhocPrice(base, target)
+	if err != nil || last.ID == 0 {
+		return 0
+	}
+
+	window := time.Duration(o.guards.DeviationWindowMin) * time.Minute
+	if window > 0 && time.Since(last.UpdatedAt) > window {
+		return 0
+	}
+
+	wei, ok := new(big.Int).SetString(last.PriceResult, 10)
+	if !ok {
+		return 0
+	}
+
+	lastPrice, _ := utils.WeiToEther(wei).Float64()
+
+	return lastPrice
+}
@@ -0,0 +1,116 @@
+package ooo_api_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-ooo/config"
+	"go-ooo/database/models"
+	"go-ooo/ooo_api"
+	"go-ooo/ooo_api/dex"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testGuards() config.AdhocGuardsConfig {
+	return config.AdhocGuardsConfig{
+		MinPools:           2,
+		MinDexs:            2,
+		MaxDispersion:      0.1,
+		MaxDeviation:       0.2,
+		DeviationWindowMin: 60,
+	}
+}
+
+func TestCheckAdhocGuardsPass(t *testing.T) {
+	err := ooo_api.CheckAdhocGuards(testGuards(), 2, 3, []float64{1.0, 1.01, 0.99}, 1.0, 0.95)
+	require.NoError(t, err)
+}
+
+func TestCheckAdhocGuardsMinDexs(t *testing.T) {
+	err := ooo_api.CheckAdhocGuards(testGuards(), 1, 3, []float64{1.0, 1.01, 0.99}, 1.0, 0)
+
+	var rejectedErr *ooo_api.PriceRejectedError
+	require.True(t, errors.As(err, &rejectedErr))
+	require.Equal(t, "prices from 1 DEXs, minimum 2", rejectedErr.Reason)
+}
+
+func TestCheckAdhocGuardsMinPools(t *testing.T) {
+	err := ooo_api.CheckAdhocGuards(testGuards(), 2, 1, []float64{1.0, 1.01, 0.99}, 1.0, 0)
+
+	var rejectedErr *ooo_api.PriceRejectedError
+	require.True(t, errors.As(err, &rejectedErr))
+	require.Equal(t, "prices from 1 pools, minimum 2", rejectedErr.Reason)
+}
+
+func TestCheckAdhocGuardsDispersion(t *testing.T) {
+	err := ooo_api.CheckAdhocGuards(testGuards(), 2, 3, []float64{1.0, 1.5, 0.5}, 1.0, 0)
+
+	var rejectedErr *ooo_api.PriceRejectedError
+	require.True(t, errors.As(err, &rejectedErr))
+	require.Contains(t, rejectedErr.Reason, "price dispersion")
+}
+
+func TestCheckAdhocGuardsDeviation(t *testing.T) {
+	err := ooo_api.CheckAdhocGuards(testGuards(), 2, 3, []float64{1.0, 1.01, 0.99}, 1.0, 0.5)
+
+	var rejectedErr *ooo_api.PriceRejectedError
+	require.True(t, errors.As(err, &rejectedErr))
+	require.Contains(t, rejectedErr.Reason, "deviation")
+}
+
+func TestCheckAdhocGuardsDisabled(t *testing.T) {
+	guards := config.AdhocGuardsConfig{}
+	err := ooo_api.CheckAdhocGuards(guards, 0, 0, []float64{1.0, 1.5, 0.5}, 1.0, 0.1)
+	require.NoError(t, err)
+}
+
+func TestDexPrices(t *testing.T) {
+	sources := []dex.DexResult{
+		{Chain: "eth", Dex: "uniswapv3", Prices: []float64{1.0, 1.02, 50.0}},
+		{Chain: "polygon", Dex: "quickswapv3", Prices: []float64{0.98, 1.0}},
+		{Chain: "bsc", Dex: "pancakeswapv3", Prices: []float64{90.0}},
+	}
+	// 50.0 and 90.0 were removed as outliers
+	kept := []float64{1.0, 1.02, 0.98, 1.0}
+
+	prices := ooo_api.DexPrices(sources, kept)
+	require.Len(t, prices, 2)
+	require.InDelta(t, 1.01, prices[0], 1e-9)
+	require.InDelta(t, 0.99, prices[1], 1e-9)
+
+	// a single anomalous swap does not cause the price to be rejected
+	require.NoError(t, ooo_api.CheckAdhocGuards(testGuards(), 2, 3, prices, 1.0, 0))
+}
+
+func TestLastServedPrice(t *testing.T) {
+	served := func(t *testing.T, windowMins uint64, age time.Duration) float64 {
+		cfg := testConfig()
+		cfg.Guards.MaxDeviation = 0.2
+		cfg.Guards.DeviationWindowMin = windowMins
+		api, db := newTestApiWithClients(t, cfg, nil, nil)
+
+		req := models.DataRequests{
+			RequestId:       "01",
+			IsAdhoc:         true,
+			EndpointDecoded: "BTC.USD.AD",
+			PriceResult:     "2000000000000000000",
+			RequestStatus:   models.REQUEST_STATUS_SUCCESS,
+		}
+		require.NoError(t, db.Create(&req).Error)
+		require.NoError(t, db.Model(&req).UpdateColumn("updated_at", time.Now().Add(-age)).Error)
+
+		return api.GetLastServedPrice("BTC", "USD")
+	}
+
+	t.Run("within window", func(t *testing.T) {
+		require.Equal(t, 2.0, served(t, 60, 30*time.Minute))
+	})
+	t.Run("outside window", func(t *testing.T) {
+		require.Equal(t, 0.0, served(t, 60, 2*time.Hour))
+	})
+	t.Run("zero window has no time limit", func(t *testing.T) {
+		require.Equal(t, 2.0, served(t, 0, 24*time.Hour))
+	})
+}
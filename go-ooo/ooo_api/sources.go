@@ -0,0 +1,253 @@
+package ooo_api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"go-ooo/config"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+)
+
+const (
+	SourceFinchains = "finchains"
+	SourceDex       = "dex"
+)
+
+// PriceResult is the price returned by RouteQuery, along with the source(s) it was obtained from
+// and the provenance records for each source queried
+type PriceResult struct {
+	Price      string
+	Source     string
+	Provenance []models.PriceProvenance
+}
+
+// getSourcePolicy returns the source policy for the pair, or the default policy if no
+// override has been configured. Viper lower-cases map keys, so the lookup is case-insensitive
+func (o *OOOApi) getSourcePolicy(base, target string) config.SourcePolicyConfig {
+	policy := o.sources.Default
+	if p, ok := o.sources.Pairs[strings.ToLower(fmt.Sprintf("%s-%s", base, target))]; ok {
+		if p.MaxDivergence == 0 {
+			p.MaxDivergence = policy.MaxDivergence
+		}
+		policy = p
+	}
+
+	if len(policy.Order) == 0 {
+		policy.Order = []string{SourceFinchains}
+	}
+
+	return policy
+}
+
+// queryWithSourcePolicy runs a standard price query using the pair's source policy
+func (o *OOOApi) queryWithSourcePolicy(endpoint string, requestId string, at time.Time) (PriceResult, error) {
+	base, target, _, _, _, _, _, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return PriceResult{}, err
+	}
+
+	policy := o.getSourcePolicy(base, target)
+
+	logger.Debug("ooo_api", "queryWithSourcePolicy", "getSourcePolicy", "", logger.Fields{
+		"request_id":     requestId,
+		"order":          strings.Join(policy.Order, ","),
+		"cross_check":    policy.CrossCheck,
+		"max_divergence": policy.MaxDivergence,
+	})
+
+	if policy.CrossCheck && len(policy.Order) > 1 {
+		return o.queryCrossCheck(endpoint, requestId, policy, at)
+	}
+
+	var errs []string
+	var rejectedErr *PriceRejectedError
+
+	for _, source := range policy.Order {
+		price, provenance, err := o.queryFromSource(source, endpoint, requestId, at)
+		if err == nil && price != "" {
+			return PriceResult{Price: price, Source: source, Provenance: []models.PriceProvenance{provenance}}, nil
+		}
+
+		if err == nil {
+			err = errors.New("empty price returned")
+		}
+
+		logger.WarnWithFields("ooo_api", "queryWithSourcePolicy", "queryFromSource",
+			"source failed. Trying next source", logger.Fields{
+				"request_id": requestId,
+				"source":     source,
+				"err":        err.Error(),
+			})
+
+		errors.As(err, &rejectedErr)
+		errs = append(errs, fmt.Sprintf("%s: %s", source, err.Error()))
+	}
+
+	// a guard rejection is terminal, so should take precedence over transient errors
+	if rejectedErr != nil {
+		return PriceResult{}, rejectedErr
+	}
+
+	return PriceResult{}, errors.New(strings.Join(errs, "; "))
+}
+
+// queryCrossCheck queries the first two sources in the policy. If both return a price and they
+// diverge by more than MaxDivergence the price is rejected. If only one source returns a
+// price, the request fails unless the policy allows a single source, in which case that
+// price is used without cross-checking
+func (o *OOOApi) queryCrossCheck(endpoint string, requestId string, policy config.SourcePolicyConfig, at time.Time) (PriceResult, error) {
+	primarySrc := policy.Order[0]
+	secondarySrc := policy.Order[1]
+
+	primary, primaryProv, primaryErr := o.queryFromSource(primarySrc, endpoint, requestId, at)
+	secondary, secondaryProv, secondaryErr := o.queryFromSource(secondarySrc, endpoint, requestId, at)
+
+	if primary == "" && primaryErr == nil {
+		primaryErr = errors.New("empty price returned")
+	}
+	if secondary == "" && secondaryErr == nil {
+		secondaryErr = errors.New("empty price returned")
+	}
+
+	if primaryErr != nil && secondaryErr != nil {
+		return PriceResult{}, fmt.Errorf("%s: %s; %s: %s", primarySrc, primaryErr.Error(), secondarySrc, secondaryErr.Error())
+	}
+
+	if primaryErr != nil || secondaryErr != nil {
+		result := PriceResult{Price: primary, Source: primarySrc, Provenance: []models.PriceProvenance{primaryProv}}
+		failedSrc, failedErr := secondarySrc, secondaryErr
+		if primaryErr != nil {
+			result = PriceResult{Price: secondary, Source: secondarySrc, Provenance: []models.PriceProvenance{secondaryProv}}
+			failedSrc, failedErr = primarySrc, primaryErr
+		}
+
+		if !policy.AllowSingleSource {
+			return PriceResult{}, fmt.Errorf("unable to cross-check price. %s: %s", failedSrc, failedErr.Error())
+		}
+
+		logger.WarnWithFields("ooo_api", "queryCrossCheck", "queryFromSource",
+			"source failed. Using single source without cross-check", logger.Fields{
+				"request_id": requestId,
+				"source":     failedSrc,
+				"err":        failedErr.Error(),
+			})
+
+		return result, nil
+	}
+
+	divergence, err := PriceDivergence(primary, secondary)
+	if err != nil {
+		return PriceResult{}, err
+	}
+
+	logger.Debug("ooo_api", "queryCrossCheck", "PriceDivergence", "", logger.Fields{
+		"request_id":  requestId,
+		primarySrc:    primary,
+		secondarySrc:  secondary,
+		"divergence":  divergence,
+		"max_allowed": policy.MaxDivergence,
+	})
+
+	if policy.MaxDivergence > 0 && divergence > policy.MaxDivergence {
+		return PriceResult{}, &PriceRejectedError{
+			Reason: fmt.Sprintf("%s and %s prices diverge by %.4f, max %.4f", primarySrc, secondarySrc, divergence, policy.MaxDivergence),
+		}
+	}
+
+	return PriceResult{
+		Price:      primary,
+		Source:     fmt.Sprintf("%s+%s", primarySrc, secondarySrc),
+		Provenance: []models.PriceProvenance{primaryProv, secondaryProv},
+	}, nil
+}
+
+// queryFromSource queries a single source for the standard endpoint. at is only used by the
+// dex source, since Finchains can only return current prices
+func (o *OOOApi) queryFromSource(source, endpoint, requestId string, at time.Time) (string, models.PriceProvenance, error) {
+	switch source {
+	case SourceFinchains:
+		return o.queryFinchains(endpoint, requestId)
+	case SourceDex:
+		base, target, qType, subtype, supp1, supp2, _, err := ParseEndpoint(endpoint)
+		if err != nil {
+			return "", models.PriceProvenance{}, err
+		}
+		// only fall back for queries which Finchains would answer, so the DEX price answers the same question
+		if qType != "PR" {
+			return "", models.PriceProvenance{}, errors.New("query type not currently supported")
+		}
+		if _, err = getPriceSubType(subtype, supp1, supp2); err != nil {
+			return "", models.PriceProvenance{}, err
+		}
+		minutes, err := windowToMinutes(subtype, supp1)
+		if err != nil {
+			return "", models.PriceProvenance{}, err
+		}
+		base, target = o.dexSymbol(base), o.dexSymbol(target)
+		if !o.dexModuleManager.HasPair(base, target) {
+			return "", models.PriceProvenance{}, errors.New("pair not found on any DEX")
+		}
+		adhocEndpoint := fmt.Sprintf("%s.%s.AD.%d", base, target, minutes)
+		return o.queryAdhoc(adhocEndpoint, requestId, at)
+	default:
+		return "", models.PriceProvenance{}, fmt.Errorf("unknown price source %s", source)
+	}
+}
+
+// dexSymbol returns the symbol used on the DEXs for a Finchains symbol, e.g. WETH for ETH. Symbols
+// with no mapping are returned unchanged. Viper lower-cases map keys, so the lookup is case-insensitive
+func (o *OOOApi) dexSymbol(symbol string) string {
+	if s, ok := o.sources.DexSymbols[strings.ToLower(symbol)]; ok && s != "" {
+		return strings.ToUpper(s)
+	}
+	return symbol
+}
+
+// windowToMinutes converts a standard endpoint's time window into the number of minutes
+// to use for the DEX query. Windows default to 1H, as on Finchains. DEX queries are limited to
+// 60 minutes, so longer windows are rejected rather than answered with a shorter average
+func windowToMinutes(subtype, supp1 string) (uint64, error) {
+	if subtype == "LAT" {
+		return 0, nil
+	}
+
+	window := cleanseTime(supp1)
+	switch window {
+	case "5M":
+		return 5, nil
+	case "10M":
+		return 10, nil
+	case "30M":
+		return 30, nil
+	case "1H":
+		return 60, nil
+	default:
+		return 0, fmt.Errorf("window %s is longer than the 60 minutes supported by the DEXs", window)
+	}
+}
+
+// PriceDivergence returns the relative difference between two prices, given in wei
+func PriceDivergence(a, b string) (float64, error) {
+	aF, ok := new(big.Float).SetString(a)
+	if !ok {
+		return 0, fmt.Errorf("invalid price %s", a)
+	}
+	bF, ok := new(big.Float).SetString(b)
+	if !ok {
+		return 0, fmt.Errorf("invalid price %s", b)
+	}
+
+	aVal, _ := aF.Float64()
+	bVal, _ := bF.Float64()
+
+	if bVal == 0 {
+		return 0, errors.New("cannot cross-check zero price")
+	}
+
+	return math.Abs(aVal-bVal) / bVal, nil
+}
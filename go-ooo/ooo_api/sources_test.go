@@ -0,0 +1,193 @@
+package ooo_api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"go-ooo/config"
+	"go-ooo/ooo_api"
+	"go-ooo/ooo_api/dex"
+	"go-ooo/utils/httprecorder"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceDivergence(t *testing.T) {
+	d, err := ooo_api.PriceDivergence("1050000000000000000", "1000000000000000000")
+	require.NoError(t, err)
+	require.InDelta(t, 0.05, d, 0.000001)
+
+	d, err = ooo_api.PriceDivergence("950000000000000000", "1000000000000000000")
+	require.NoError(t, err)
+	require.InDelta(t, 0.05, d, 0.000001)
+}
+
+func TestPriceDivergenceInvalid(t *testing.T) {
+	_, err := ooo_api.PriceDivergence("abc", "1000000000000000000")
+	require.Error(t, err)
+
+	_, err = ooo_api.PriceDivergence("1000000000000000000", "0")
+	require.Error(t, err)
+}
+
+// failingPriceSource is a PriceSource for a Finchains API which is down
+type failingPriceSource struct{}
+
+func (failingPriceSource) BaseURL() string {
+	return "https://crypto.finchains.io/api"
+}
+
+func (failingPriceSource) Get(path string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+// fixedPriceSubgraph returns the price for every USDC-WETH pool queried. Pair metadata
+// requests are replayed from the fixtures in testdata/fixtures
+type fixedPriceSubgraph struct {
+	price    float64
+	metadata dex.SubgraphClient
+}
+
+var poolQueryRe = regexp.MustCompile(`(p\d+): pairs\([^)]*id_in: \[\\?"(0x[0-9a-f]+)\\?"\]`)
+
+func (f fixedPriceSubgraph) Query(query []byte, url string) ([]byte, error) {
+	if query == nil {
+		return f.metadata.Query(query, url)
+	}
+
+	data := make(map[string][]map[string]interface{})
+	for _, m := range poolQueryRe.FindAllSubmatch(query, -1) {
+		data[string(m[1])] = []map[string]interface{}{{
+			"id":          string(m[2]),
+			"token0":      map[string]string{"id": "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", "name": "USD Coin", "symbol": "USDC"},
+			"token0Price": strconv.FormatFloat(f.price, 'f', -1, 64),
+			"token1":      map[string]string{"id": "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", "name": "Wrapped Ether", "symbol": "WETH"},
+			"token1Price": strconv.FormatFloat(1/f.price, 'f', -1, 64),
+		}}
+	}
+
+	return json.Marshal(map[string]interface{}{"data": data})
+}
+
+// newSourcesTestApi returns an OOOApi using policy for all pairs, the DEX pools priced at dexPrice,
+// and Finchains either replayed from the fixtures, or down
+func newSourcesTestApi(t *testing.T, policy config.SourcePolicyConfig, dexPrice float64, finchainsDown bool) *ooo_api.OOOApi {
+	cfg := testConfig()
+	cfg.Sources.Default = policy
+
+	var priceSource ooo_api.PriceSource
+	if finchainsDown {
+		priceSource = failingPriceSource{}
+	}
+
+	recorder := httprecorder.New(filepath.Join("testdata", "fixtures"), cfg.ApiKeys.GraphNetwork)
+	subgraph := fixedPriceSubgraph{price: dexPrice, metadata: dex.NewHttpSubgraphClient(recorder.Client())}
+
+	api, db := newTestApiWithClients(t, cfg, priceSource, subgraph)
+	api.UpdateSupportedPairs()
+	api.UpdateDexPairs()
+	approvePendingTokens(t, db)
+
+	return api
+}
+
+// the Finchains ETH/USD fixture price
+const finchainsEthUsd = "3001450000000000000000"
+
+func TestSourceFallback(t *testing.T) {
+	policy := config.SourcePolicyConfig{Order: []string{ooo_api.SourceFinchains, ooo_api.SourceDex}}
+
+	// Finchains is used when available
+	api := newSourcesTestApi(t, policy, 3000, false)
+	res, err := api.RouteQuery("ETH.USD.PR.AVG", "1")
+	require.NoError(t, err)
+	require.Equal(t, ooo_api.SourceFinchains, res.Source)
+	require.Equal(t, finchainsEthUsd, res.Price)
+
+	// ETH/USD falls back to WETH/USDC on the DEXs
+	api = newSourcesTestApi(t, policy, 3000, true)
+	res, err = api.RouteQuery("ETH.USD.PR.AVG", "2")
+	require.NoError(t, err)
+	require.Equal(t, ooo_api.SourceDex, res.Source)
+	require.Equal(t, "3000000000000000000000", res.Price)
+	require.Len(t, res.Provenance, 1)
+}
+
+func TestSourceCrossCheck(t *testing.T) {
+	policy := config.SourcePolicyConfig{
+		Order:         []string{ooo_api.SourceFinchains, ooo_api.SourceDex},
+		CrossCheck:    true,
+		MaxDivergence: 0.05,
+	}
+
+	api := newSourcesTestApi(t, policy, 3000, false)
+	res, err := api.RouteQuery("ETH.USD.PR.AVG", "1")
+	require.NoError(t, err)
+	require.Equal(t, "finchains+dex", res.Source)
+	require.Equal(t, finchainsEthUsd, res.Price)
+	require.Len(t, res.Provenance, 2)
+}
+
+func TestSourceCrossCheckDivergence(t *testing.T) {
+	policy := config.SourcePolicyConfig{
+		Order:         []string{ooo_api.SourceFinchains, ooo_api.SourceDex},
+		CrossCheck:    true,
+		MaxDivergence: 0.05,
+	}
+
+	api := newSourcesTestApi(t, policy, 3500, false)
+	_, err := api.RouteQuery("ETH.USD.PR.AVG", "1")
+	require.Error(t, err)
+
+	var rejectedErr *ooo_api.PriceRejectedError
+	require.ErrorAs(t, err, &rejectedErr)
+	require.Contains(t, err.Error(), "finchains and dex prices diverge")
+}
+
+func TestSourceCrossCheckOneSourceDown(t *testing.T) {
+	policy := config.SourcePolicyConfig{
+		Order:         []string{ooo_api.SourceFinchains, ooo_api.SourceDex},
+		CrossCheck:    true,
+		MaxDivergence: 0.05,
+	}
+
+	// a single source is refused by default
+	api := newSourcesTestApi(t, policy, 3000, true)
+	_, err := api.RouteQuery("ETH.USD.PR.AVG", "1")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unable to cross-check price. finchains:")
+
+	// unless explicitly allowed
+	policy.AllowSingleSource = true
+	api = newSourcesTestApi(t, policy, 3000, true)
+	res, err := api.RouteQuery("ETH.USD.PR.AVG", "2")
+	require.NoError(t, err)
+	require.Equal(t, ooo_api.SourceDex, res.Source)
+	require.Equal(t, "3000000000000000000000", res.Price)
+}
+
+func TestSourceDexUnsupportedQuery(t *testing.T) {
+	policy := config.SourcePolicyConfig{Order: []string{ooo_api.SourceFinchains, ooo_api.SourceDex}}
+	api := newSourcesTestApi(t, policy, 3000, true)
+
+	for endpoint, expected := range map[string]string{
+		"ETH.USD.EX.AVG":     "query type not currently supported",
+		"ETH.USD.PR.HI":      "unsupported sub type for PR",
+		"ETH.USD.PR.AVG.24H": "window 24H is longer than the 60 minutes supported by the DEXs",
+		"ETH.USD.PR.AVI.2H":  "window 2H is longer than the 60 minutes supported by the DEXs",
+	} {
+		_, err := api.RouteQuery(endpoint, "1")
+		require.ErrorContains(t, err, "dex: "+expected, endpoint)
+	}
+
+	// supported windows are served by the DEXs
+	for _, endpoint := range []string{"ETH.USD.PR.AVG.30M", "ETH.USD.PR.AVC.1H.3", "ETH.USD.PR.LAT"} {
+		res, err := api.RouteQuery(endpoint, "2")
+		require.NoError(t, err, endpoint)
+		require.Equal(t, ooo_api.SourceDex, res.Source)
+	}
+}
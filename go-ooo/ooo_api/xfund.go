@@ -0,0 +1,296 @@
+package ooo_api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go-ooo/config"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+	"go-ooo/utils"
+)
+
+// CoinGeckoXfundUrl is the CoinGecko simple price endpoint for xFUND in ETH and USD
+const CoinGeckoXfundUrl = "https://api.coingecko.com/api/v3/simple/price?ids=xfund&vs_currencies=eth%2Cusd"
+
+// XfundPriceSource returns the current price of 1 xFUND in ETH
+type XfundPriceSource interface {
+	Name() string
+	XfundPriceEth() (float64, error)
+}
+
+// CoinGeckoXfundSource queries the xFUND price from the CoinGecko API
+type CoinGeckoXfundSource struct {
+	url    string
+	client *http.Client
+}
+
+var _ XfundPriceSource = &CoinGeckoXfundSource{}
+
+// NewCoinGeckoXfundSource returns an XfundPriceSource for the CoinGecko simple price endpoint
+// at url. If client is nil, a client with a 15 second timeout is used
+func NewCoinGeckoXfundSource(url string, client *http.Client) *CoinGeckoXfundSource {
+	if client == nil {
+		client = &http.Client{
+			Timeout: 15 * time.Second,
+		}
+	}
+
+	return &CoinGeckoXfundSource{
+		url:    url,
+		client: client,
+	}
+}
+
+func (c *CoinGeckoXfundSource) Name() string {
+	return config.XfundSourceCoinGecko
+}
+
+func (c *CoinGeckoXfundSource) XfundPriceEth() (float64, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.New(fmt.Sprintf("coingecko returned %s", resp.Status))
+	}
+
+	cgResp := go_ooo_types.CoinGeckoResponse{}
+	if err = json.Unmarshal(body, &cgResp); err != nil {
+		return 0, err
+	}
+
+	if cgResp.Xfund.Eth <= 0 {
+		return 0, errors.New("coingecko returned no xFUND price")
+	}
+
+	return cgResp.Xfund.Eth, nil
+}
+
+// StaticXfundSource returns a fixed xFUND price
+type StaticXfundSource struct {
+	price float64
+}
+
+var _ XfundPriceSource = &StaticXfundSource{}
+
+func NewStaticXfundSource(price float64) *StaticXfundSource {
+	return &StaticXfundSource{
+		price: price,
+	}
+}
+
+func (s *StaticXfundSource) Name() string {
+	return config.XfundSourceStatic
+}
+
+func (s *StaticXfundSource) XfundPriceEth() (float64, error) {
+	if s.price <= 0 {
+		return 0, errors.New("static xFUND price not set")
+	}
+	return s.price, nil
+}
+
+// dexXfundSource calculates the xFUND price using the DEX engine, in the same way as AdHoc
+// requests are priced
+type dexXfundSource struct {
+	api     *OOOApi
+	base    string
+	target  string
+	minutes uint64
+}
+
+var _ XfundPriceSource = &dexXfundSource{}
+
+func (d *dexXfundSource) Name() string {
+	return config.XfundSourceDex
+}
+
+func (d *dexXfundSource) XfundPriceEth() (float64, error) {
+	endpoint := fmt.Sprintf("%s.%s.AD.%d", d.base, d.target, d.minutes)
+
+	price, _, err := d.api.queryAdhoc(endpoint, "xfund-price", time.Time{})
+	if err != nil {
+		return 0, err
+	}
+
+	wei, ok := new(big.Int).SetString(price, 10)
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("invalid DEX price %s", price))
+	}
+
+	priceEth, _ := utils.WeiToEther(wei).Float64()
+	return priceEth, nil
+}
+
+// xfundOracle caches the xFUND price from the first source to return one, and records each
+// price fetched in the database
+type xfundOracle struct {
+	sources  []XfundPriceSource
+	cacheTtl time.Duration
+
+	mu        sync.Mutex
+	price     float64
+	source    string
+	fetchedAt time.Time
+}
+
+func newXfundOracle(cfg config.XfundPriceConfig, api *OOOApi) *xfundOracle {
+	sources := make([]XfundPriceSource, 0, len(cfg.Sources))
+	for _, s := range cfg.Sources {
+		switch s {
+		case config.XfundSourceDex:
+			sources = append(sources, &dexXfundSource{
+				api:     api,
+				base:    cfg.DexBase,
+				target:  cfg.DexTarget,
+				minutes: cfg.DexMinutes,
+			})
+		case config.XfundSourceCoinGecko:
+			sources = append(sources, NewCoinGeckoXfundSource(CoinGeckoXfundUrl, nil))
+		case config.XfundSourceStatic:
+			sources = append(sources, NewStaticXfundSource(cfg.StaticPrice))
+		}
+	}
+
+	return &xfundOracle{
+		sources:  sources,
+		cacheTtl: time.Duration(cfg.CacheMins) * time.Minute,
+	}
+}
+
+// SetXfundPriceSources replaces the configured xFUND price sources, and clears the cached price
+func (o *OOOApi) SetXfundPriceSources(sources ...XfundPriceSource) {
+	o.xfund.mu.Lock()
+	defer o.xfund.mu.Unlock()
+
+	o.xfund.sources = sources
+	o.xfund.price = 0
+	o.xfund.source = ""
+	o.xfund.fetchedAt = time.Time{}
+}
+
+// GetXfundPriceEth returns the current price of 1 xFUND in ETH, and the source it was obtained
+// from. The cached price is returned if it was fetched within the cache period
+func (o *OOOApi) GetXfundPriceEth() (float64, string, error) {
+	o.xfund.mu.Lock()
+	defer o.xfund.mu.Unlock()
+
+	if o.xfund.price > 0 && time.Since(o.xfund.fetchedAt) < o.xfund.cacheTtl {
+		return o.xfund.price, o.xfund.source, nil
+	}
+
+	return o.fetchXfundPrice()
+}
+
+// UpdateXfundPrice fetches and records the current xFUND price, regardless of the cache
+func (o *OOOApi) UpdateXfundPrice() {
+	o.xfund.mu.Lock()
+	defer o.xfund.mu.Unlock()
+
+	_, _, err := o.fetchXfundPrice()
+	if err != nil {
+		logger.Error("ooo_api", "UpdateXfundPrice", "", err.Error())
+	}
+}
+
+// fetchXfundPrice queries each source in order until one returns a price. If all fail, the
+// last price fetched is returned, however old. The caller must hold the lock
+func (o *OOOApi) fetchXfundPrice() (float64, string, error) {
+	for _, source := range o.xfund.sources {
+		price, err := source.XfundPriceEth()
+		if err == nil && price <= 0 {
+			err = errors.New("price is zero")
+		}
+		if err != nil {
+			logger.WarnWithFields("ooo_api", "fetchXfundPrice", "", "xFUND price source failed", logger.Fields{
+				"source": source.Name(),
+				"error":  err.Error(),
+			})
+			continue
+		}
+
+		o.xfund.price = price
+		o.xfund.source = source.Name()
+		o.xfund.fetchedAt = time.Now()
+
+		if err = o.db.InsertXfundPrice(price, source.Name()); err != nil {
+			logger.Error("ooo_api", "fetchXfundPrice", "insert xFUND price", err.Error())
+		}
+
+		logger.Debug("ooo_api", "fetchXfundPrice", "", "xFUND price updated", logger.Fields{
+			"source":    source.Name(),
+			"price_eth": price,
+		})
+
+		return price, source.Name(), nil
+	}
+
+	if o.xfund.price > 0 {
+		logger.WarnWithFields("ooo_api", "fetchXfundPrice", "", "no xFUND price source available. Using last price", logger.Fields{
+			"source":     o.xfund.source,
+			"fetched_at": o.xfund.fetchedAt,
+		})
+		return o.xfund.price, o.xfund.source, nil
+	}
+
+	return 0, "", errors.New("no xFUND price source returned a price")
+}
+
+// XfundPriceHistory looks up the xFUND price recorded at a given time
+type XfundPriceHistory struct {
+	prices  []models.XfundPrices
+	current float64
+}
+
+// GetXfundPriceHistory loads the xFUND prices recorded between from and to. The current price
+// is used for times with no recorded price
+func (o *OOOApi) GetXfundPriceHistory(from time.Time, to time.Time) (*XfundPriceHistory, error) {
+	prices, err := o.db.GetXfundPriceHistory(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	current, _, err := o.GetXfundPriceEth()
+	if err != nil && len(prices) == 0 {
+		return nil, err
+	}
+
+	return &XfundPriceHistory{
+		prices:  prices,
+		current: current,
+	}, nil
+}
+
+// PriceAt returns the last price recorded at or before t. If there is none, the first price
+// recorded after t is used, or the current price if no prices have been recorded
+func (h *XfundPriceHistory) PriceAt(t time.Time) float64 {
+	if len(h.prices) == 0 {
+		return h.current
+	}
+
+	// index of the first price recorded after t
+	i := sort.Search(len(h.prices), func(i int) bool {
+		return h.prices[i].CreatedAt.After(t)
+	})
+
+	if i == 0 {
+		return h.prices[0].GetPriceEth()
+	}
+	return h.prices[i-1].GetPriceEth()
+}
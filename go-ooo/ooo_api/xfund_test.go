@@ -0,0 +1,93 @@
+package ooo_api_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-ooo/ooo_api"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeXfundSource returns a fixed price or error, and counts the queries made
+type fakeXfundSource struct {
+	price float64
+	err   error
+	calls int
+}
+
+func (f *fakeXfundSource) Name() string {
+	return "fake"
+}
+
+func (f *fakeXfundSource) XfundPriceEth() (float64, error) {
+	f.calls++
+	return f.price, f.err
+}
+
+func TestXfundPriceFallbackAndCache(t *testing.T) {
+	api := newTestApi(t)
+
+	failing := &fakeXfundSource{err: errors.New("unavailable")}
+	fake := &fakeXfundSource{price: 0.0002}
+	api.SetXfundPriceSources(failing, fake, ooo_api.NewStaticXfundSource(0.0001))
+
+	price, source, err := api.GetXfundPriceEth()
+	require.NoError(t, err)
+	require.Equal(t, 0.0002, price)
+	require.Equal(t, "fake", source)
+
+	// cached
+	fake.price = 0.0003
+	price, _, err = api.GetXfundPriceEth()
+	require.NoError(t, err)
+	require.Equal(t, 0.0002, price)
+	require.Equal(t, 1, fake.calls)
+
+	// update ignores the cache
+	api.UpdateXfundPrice()
+	price, _, err = api.GetXfundPriceEth()
+	require.NoError(t, err)
+	require.Equal(t, 0.0003, price)
+
+	// static fallback
+	fake.err = errors.New("unavailable")
+	api.UpdateXfundPrice()
+	price, source, err = api.GetXfundPriceEth()
+	require.NoError(t, err)
+	require.Equal(t, 0.0001, price)
+	require.Equal(t, "static", source)
+
+	api.SetXfundPriceSources(failing)
+	_, _, err = api.GetXfundPriceEth()
+	require.Error(t, err)
+}
+
+func TestXfundPriceHistory(t *testing.T) {
+	api := newTestApi(t)
+
+	fake := &fakeXfundSource{price: 0.0001}
+	api.SetXfundPriceSources(fake)
+
+	start := time.Now()
+	api.UpdateXfundPrice()
+	time.Sleep(10 * time.Millisecond)
+	middle := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	fake.price = 0.0002
+	api.UpdateXfundPrice()
+	end := time.Now()
+
+	history, err := api.GetXfundPriceHistory(start, end)
+	require.NoError(t, err)
+
+	require.Equal(t, 0.0001, history.PriceAt(start.Add(-time.Hour)))
+	require.Equal(t, 0.0001, history.PriceAt(middle))
+	require.Equal(t, 0.0002, history.PriceAt(end))
+
+	// only the last price before the range is loaded
+	history, err = api.GetXfundPriceHistory(end.Add(time.Hour), end.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 0.0002, history.PriceAt(end.Add(90*time.Minute)))
+}
@@ -0,0 +1,137 @@
+package replay
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	"go-ooo/ooo_api"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// PriceQuerier fetches the price for an endpoint at a given time. It is satisfied by *ooo_api.OOOApi
+type PriceQuerier interface {
+	RouteQueryAtTime(endpoint string, requestId string, at time.Time) (ooo_api.PriceResult, error)
+}
+
+// HeaderReader fetches block headers. It is satisfied by *ethclient.Client
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Result is the outcome of re-pricing a single historical request
+type Result struct {
+	RequestId    string     `json:"request_id"`
+	Endpoint     string     `json:"endpoint"`
+	RequestBlock uint64     `json:"request_block"`
+	PricedAt     *time.Time `json:"priced_at,omitempty"` // nil if priced at the latest block
+	StoredPrice  string     `json:"stored_price"`
+	StoredSource string     `json:"stored_source"`
+	ReplayPrice  string     `json:"replay_price"`
+	ReplaySource string     `json:"replay_source"`
+	Deviation    float64    `json:"deviation"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// Replayer re-runs RouteQuery for requests stored in the database
+type Replayer struct {
+	ctx            context.Context
+	oooApi         PriceQuerier
+	client         HeaderReader
+	atRequestBlock bool
+}
+
+// NewReplayer creates a Replayer. If atRequestBlock is true, DEX prices are calculated for
+// the window ending at the time of each request's block, rather than the latest block
+func NewReplayer(ctx context.Context, cfg *config.Config, db *database.DB, atRequestBlock bool) (*Replayer, error) {
+	oooApi, err := ooo_api.NewApi(ctx, cfg, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var client HeaderReader
+	if atRequestBlock {
+		ethClient, err := ethclient.Dial(cfg.Chain.EthWsHost)
+		if err != nil {
+			return nil, err
+		}
+		client = ethClient
+	}
+
+	oooApi.UpdateChainBlockTimes()
+
+	return NewReplayerWithClients(ctx, oooApi, client, atRequestBlock), nil
+}
+
+// NewReplayerWithClients creates a Replayer which prices requests using oooApi. client is used
+// to get the time of each request's block, and is only required if atRequestBlock is true
+func NewReplayerWithClients(ctx context.Context, oooApi PriceQuerier, client HeaderReader, atRequestBlock bool) *Replayer {
+	return &Replayer{
+		ctx:            ctx,
+		oooApi:         oooApi,
+		client:         client,
+		atRequestBlock: atRequestBlock,
+	}
+}
+
+// Replay re-prices each request and returns the deviation from the stored price
+func (r *Replayer) Replay(requests []models.DataRequests) []Result {
+	results := make([]Result, 0, len(requests))
+
+	for _, req := range requests {
+		results = append(results, r.replayRequest(req))
+	}
+
+	return results
+}
+
+func (r *Replayer) replayRequest(req models.DataRequests) Result {
+	result := Result{
+		RequestId:    req.GetRequestId(),
+		Endpoint:     req.GetEndpointDecoded(),
+		RequestBlock: req.GetRequestBlockNumber(),
+		StoredPrice:  req.GetPriceResult(),
+		StoredSource: req.GetPriceSource(),
+	}
+
+	var at time.Time
+	if r.atRequestBlock {
+		header, err := r.client.HeaderByNumber(r.ctx, new(big.Int).SetUint64(req.GetRequestBlockNumber()))
+		if err != nil {
+			logger.ErrorWithFields("replay", "replayRequest", "get request block header", err.Error(), logger.Fields{
+				"request_id": req.GetRequestId(),
+				"block":      req.GetRequestBlockNumber(),
+			})
+			result.Error = err.Error()
+			return result
+		}
+		at = time.Unix(int64(header.Time), 0)
+		result.PricedAt = &at
+	}
+
+	priceResult, err := r.oooApi.RouteQueryAtTime(req.GetEndpointDecoded(), req.GetRequestId(), at)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.ReplayPrice = priceResult.Price
+	result.ReplaySource = priceResult.Source
+
+	if result.StoredPrice != "" {
+		deviation, err := ooo_api.PriceDivergence(result.ReplayPrice, result.StoredPrice)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Deviation = deviation
+	}
+
+	return result
+}
@@ -0,0 +1,156 @@
+package replay_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	"go-ooo/ooo_api"
+	"go-ooo/replay"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeApi returns a fixed price for each endpoint, and records the time each request was priced at
+type fakeApi struct {
+	mu     sync.Mutex
+	prices map[string]string
+	at     map[string]time.Time
+}
+
+func (f *fakeApi) RouteQueryAtTime(endpoint string, requestId string, at time.Time) (ooo_api.PriceResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.at[requestId] = at
+
+	price, ok := f.prices[endpoint]
+	if !ok {
+		return ooo_api.PriceResult{}, errors.New("pair not currently supported")
+	}
+	return ooo_api.PriceResult{Price: price, Source: ooo_api.SourceDex}, nil
+}
+
+func newTestDb(t *testing.T) *database.DB {
+	logger.SetLogLevel("fatal")
+
+	cfg := config.DefaultConfig()
+	cfg.Database.Dialect = "sqlite"
+	cfg.Database.Storage = filepath.Join(t.TempDir(), "go-ooo.sqlite")
+
+	db, err := database.NewDb(cfg)
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+
+	return db
+}
+
+func TestReplay(t *testing.T) {
+	backend := simulated.NewBackend(types.GenesisAlloc{})
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+	for i := 0; i < 5; i++ {
+		require.NoError(t, backend.AdjustTime(12*time.Second))
+		backend.Commit()
+	}
+	client := backend.Client()
+
+	db := newTestDb(t)
+	for _, req := range []models.DataRequests{
+		{RequestId: "01", EndpointDecoded: "ETH.USD.PR.AVG", RequestBlockNumber: 0, PriceResult: "1000000000000000000", PriceSource: "finchains"},
+		{RequestId: "02", EndpointDecoded: "BTC.USD.PR.AVG", RequestBlockNumber: 2, PriceResult: "2000000000000000000", PriceSource: "finchains"},
+		{RequestId: "03", EndpointDecoded: "XYZ.USD.PR.AVG", RequestBlockNumber: 3, PriceResult: "3000000000000000000", PriceSource: "finchains"},
+		{RequestId: "04", EndpointDecoded: "ETH.USD.PR.AVG", RequestBlockNumber: 4},
+	} {
+		require.NoError(t, db.Create(&req).Error)
+	}
+
+	// block 0 is a valid start of the range, and unpriced requests are not replayed
+	requests, err := db.GetPricedRequestsInBlockRange(0, 3)
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+
+	api := &fakeApi{
+		prices: map[string]string{
+			"ETH.USD.PR.AVG": "1100000000000000000",
+			"BTC.USD.PR.AVG": "1900000000000000000",
+		},
+		at: make(map[string]time.Time),
+	}
+
+	results := replay.NewReplayerWithClients(context.Background(), api, client, true).Replay(requests)
+	require.Len(t, results, 3)
+
+	require.Equal(t, "01", results[0].RequestId)
+	require.Equal(t, "1100000000000000000", results[0].ReplayPrice)
+	require.Equal(t, ooo_api.SourceDex, results[0].ReplaySource)
+	require.InDelta(t, 0.1, results[0].Deviation, 0.000001)
+	require.Empty(t, results[0].Error)
+
+	require.Equal(t, "02", results[1].RequestId)
+	require.InDelta(t, 0.05, results[1].Deviation, 0.000001)
+
+	require.Equal(t, "pair not currently supported", results[2].Error)
+	require.Empty(t, results[2].ReplayPrice)
+
+	// each request is priced at the time of its block
+	for _, res := range results {
+		header, err := client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(res.RequestBlock))
+		require.NoError(t, err)
+		require.NotNil(t, res.PricedAt)
+		require.Equal(t, int64(header.Time), res.PricedAt.Unix())
+		require.Equal(t, *res.PricedAt, api.at[res.RequestId])
+	}
+}
+
+func TestReplayLatest(t *testing.T) {
+	api := &fakeApi{
+		prices: map[string]string{"ETH.USD.PR.AVG": "1000000000000000000"},
+		at:     make(map[string]time.Time),
+	}
+
+	requests := []models.DataRequests{
+		{RequestId: "01", EndpointDecoded: "ETH.USD.PR.AVG", RequestBlockNumber: 10, PriceResult: "1000000000000000000"},
+	}
+
+	// without a client, requests are priced at the latest block
+	results := replay.NewReplayerWithClients(context.Background(), api, nil, false).Replay(requests)
+	require.Len(t, results, 1)
+	require.Empty(t, results[0].Error)
+	require.Zero(t, results[0].Deviation)
+	require.Nil(t, results[0].PricedAt)
+
+	out, err := json.Marshal(results[0])
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "priced_at")
+	require.True(t, api.at["01"].IsZero())
+}
+
+func TestReplayHeaderError(t *testing.T) {
+	backend := simulated.NewBackend(types.GenesisAlloc{})
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+
+	api := &fakeApi{prices: map[string]string{}, at: make(map[string]time.Time)}
+	requests := []models.DataRequests{
+		{RequestId: "01", EndpointDecoded: "ETH.USD.PR.AVG", RequestBlockNumber: 1000, PriceResult: "1000000000000000000"},
+	}
+
+	results := replay.NewReplayerWithClients(context.Background(), api, backend.Client(), true).Replay(requests)
+	require.Len(t, results, 1)
+	require.NotEmpty(t, results[0].Error)
+	require.NotContains(t, api.at, "01")
+}
@@ -1,46 +1,59 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
+
+	"golang.org/x/term"
 )
 
+// maxPasswordAttempts is the number of times the password is prompted for before start-up fails
+const maxPasswordAttempts = 3
+
 func (s *Server) inputKey() (err error) {
-	fmt.Println("")
-	fmt.Println("Please enter the cli/HTTP key, which was provided to you by Oracle")
-	fmt.Print("Key: ")
-	var key string
-	fmt.Scanf("%s\n", &key)
-	err = s.keystore.CheckToken(key)
-	if err == nil {
-		fmt.Println("Okay, let's continue...")
-		return
-	} else {
+	for i := 0; i < maxPasswordAttempts; i++ {
+		fmt.Println("")
+		fmt.Println("Please enter the cli/HTTP key, which was provided to you by Oracle")
+		fmt.Print("Key: ")
+		key, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println("")
+		if err != nil {
+			return err
+		}
+		if err = s.keystore.CheckToken(strings.TrimSpace(string(key))); err == nil {
+			fmt.Println("Okay, let's continue...")
+			return nil
+		}
 		fmt.Println("I'm not sure I can decrypt your keystore with this key.")
-		err = s.inputKey()
-		return
 	}
-	return
+	return errors.New("cannot decrypt keystore: too many attempts")
 }
 
 func (s *Server) auth() (err error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("keystore password required. Use --pass-file, --pass-env or the " +
+			SystemdCredentialName + " systemd credential when not running in a terminal")
+	}
 	fmt.Println("")
 	fmt.Println("Let's verify it's you")
 	err = s.inputKey()
 	return
 }
 
-func getPasswordFromFileOrFlag(flagValue string) string {
+// GetPasswordFromFileOrFlag returns the contents of the file at flagValue, or flagValue
+// itself if it cannot be read
+func GetPasswordFromFileOrFlag(flagValue string) string {
 	file, err := os.Open(flagValue)
-	password := ""
 	if err != nil {
-		password = flagValue
+		return strings.TrimSpace(flagValue)
 	}
 
 	defer file.Close()
 
+	password := flagValue
 	data, err := ioutil.ReadAll(file)
 	if err == nil {
 		password = string(data)
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SystemdCredentialName is the name of the systemd credential the keystore password is read
+// from when no other source is given, e.g. LoadCredential=go-ooo-pass:/etc/go-ooo/pass
+const SystemdCredentialName = "go-ooo-pass"
+
+// PasswordSource is where the keystore decryption password is read from
+type PasswordSource struct {
+	Pass string // the password, or the location of a file containing it
+	File string // location of a file containing the password, or "-" for stdin
+	Env  string // name of an environment variable containing the password
+}
+
+// IsExplicit returns true if the password is read from a file or environment variable, in
+// which case a missing or incorrect password is not prompted for
+func (p PasswordSource) IsExplicit() bool {
+	return p.File != "" || p.Env != ""
+}
+
+// Read returns the password from the first source set, in the order File, Env, Pass. If
+// none are set, the systemd credential is read if it exists. An empty password is returned
+// if no source is available
+func (p PasswordSource) Read() (string, error) {
+	switch {
+	case p.File == "-":
+		return readPasswordFromReader(os.Stdin, "stdin")
+	case p.File != "":
+		return readPasswordFile(p.File)
+	case p.Env != "":
+		password, ok := os.LookupEnv(p.Env)
+		if !ok {
+			return "", errors.New(fmt.Sprintf("environment variable %s is not set", p.Env))
+		}
+		password = strings.TrimSpace(password)
+		if password == "" {
+			return "", errors.New(fmt.Sprintf("environment variable %s is empty", p.Env))
+		}
+		return password, nil
+	case p.Pass != "":
+		return GetPasswordFromFileOrFlag(p.Pass), nil
+	}
+
+	if credFile, ok := systemdCredentialFile(); ok {
+		return readPasswordFile(credFile)
+	}
+
+	return "", nil
+}
+
+// String describes the source, for error messages
+func (p PasswordSource) String() string {
+	switch {
+	case p.File == "-":
+		return "stdin"
+	case p.File != "":
+		return "file " + p.File
+	case p.Env != "":
+		return "environment variable " + p.Env
+	case p.Pass != "":
+		return "--pass"
+	}
+	if _, ok := systemdCredentialFile(); ok {
+		return "systemd credential " + SystemdCredentialName
+	}
+	return "none"
+}
+
+// systemdCredentialFile returns the location of the systemd credential, if it exists. systemd
+// sets $CREDENTIALS_DIRECTORY for units with LoadCredential or SetCredential
+func systemdCredentialFile() (string, bool) {
+	credDir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if credDir == "" {
+		return "", false
+	}
+	credFile := filepath.Join(credDir, SystemdCredentialName)
+	if _, err := os.Stat(credFile); err != nil {
+		return "", false
+	}
+	return credFile, true
+}
+
+func readPasswordFile(location string) (string, error) {
+	file, err := os.Open(location)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("cannot read password file: %s", err.Error()))
+	}
+	defer file.Close()
+
+	return readPasswordFromReader(file, location)
+}
+
+// readPasswordFromReader returns the first line read, so that a password can be piped to
+// stdin, or a file with a trailing newline can be used
+func readPasswordFromReader(r io.Reader, name string) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.New(fmt.Sprintf("cannot read password from %s: %s", name, err.Error()))
+	}
+
+	password := strings.TrimSpace(line)
+	if password == "" {
+		return "", errors.New(fmt.Sprintf("password from %s is empty", name))
+	}
+	return password, nil
+}
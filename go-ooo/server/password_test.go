@@ -0,0 +1,52 @@
+package server_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-ooo/server"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordSourceRead(t *testing.T) {
+	dir := t.TempDir()
+	passFile := filepath.Join(dir, "pass.txt")
+	require.NoError(t, os.WriteFile(passFile, []byte("filepass\n"), 0600))
+
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	t.Setenv("TEST_GO_OOO_PASS", " envpass ")
+
+	pass, err := server.PasswordSource{File: passFile}.Read()
+	require.NoError(t, err)
+	require.Equal(t, "filepass", pass)
+
+	// a missing file is an error, not the password
+	_, err = server.PasswordSource{File: filepath.Join(dir, "missing.txt")}.Read()
+	require.Error(t, err)
+
+	pass, err = server.PasswordSource{Env: "TEST_GO_OOO_PASS"}.Read()
+	require.NoError(t, err)
+	require.Equal(t, "envpass", pass)
+
+	_, err = server.PasswordSource{Env: "TEST_GO_OOO_PASS_UNSET"}.Read()
+	require.Error(t, err)
+
+	// --pass falls back to the literal value
+	pass, err = server.PasswordSource{Pass: "flagpass"}.Read()
+	require.NoError(t, err)
+	require.Equal(t, "flagpass", pass)
+
+	pass, err = server.PasswordSource{}.Read()
+	require.NoError(t, err)
+	require.Equal(t, "", pass)
+
+	credDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(credDir, server.SystemdCredentialName), []byte("credpass"), 0600))
+	t.Setenv("CREDENTIALS_DIRECTORY", credDir)
+
+	pass, err = server.PasswordSource{}.Read()
+	require.NoError(t, err)
+	require.Equal(t, "credpass", pass)
+}
@@ -2,28 +2,34 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"go-ooo/config"
 	"go-ooo/database"
 	"go-ooo/keystore"
 	"go-ooo/logger"
 	"go-ooo/service"
+	"go-ooo/signer"
 	"go-ooo/version"
 )
 
 type Server struct {
-	srv         *service.Service
-	ctx         context.Context
-	srvCtx      *Context
-	Vers        version.Info
-	keystore    *keystore.Keystorage
-	db          *database.DB
-	decryptPass string
+	srv            *service.Service
+	ctx            context.Context
+	srvCtx         *Context
+	Vers           version.Info
+	keystore       *keystore.Keystorage
+	signer         signer.Signer
+	retiringSigner signer.Signer // previous provider account after a key rotation, if any
+	db             *database.DB
+	decryptPass    PasswordSource
+	dryRun         bool
 }
 
-func NewServer(srcCtx *Context, decryptPass string) (*Server, error) {
+func NewServer(srcCtx *Context, decryptPass PasswordSource, dryRun bool) (*Server, error) {
 	ctx := context.Background()
 
 	return &Server{
@@ -31,6 +37,7 @@ func NewServer(srcCtx *Context, decryptPass string) (*Server, error) {
 		srvCtx:      srcCtx,
 		Vers:        version.NewInfo(),
 		decryptPass: decryptPass,
+		dryRun:      dryRun,
 	}, nil
 }
 
@@ -45,7 +52,7 @@ func (s *Server) Run() {
 
 func (s *Server) initServer() {
 	s.initDatabase()
-	s.initKeystore()
+	s.initSigner()
 	s.initService()
 	s.initSignal()
 }
@@ -63,6 +70,51 @@ func (s *Server) initSignal() {
 	}()
 }
 
+func (s *Server) initSigner() {
+	cfg := s.srvCtx.Config
+
+	if cfg.Signer.Type == config.SignerTypeClef {
+		if cfg.Keystore.RetiringAccount != "" {
+			panic(fmt.Sprintf("retiring account %s is set, but is only served by the keystore signer",
+				cfg.Keystore.RetiringAccount))
+		}
+
+		logger.InfoWithFields("app", "initSigner", "", "initialise external signer", logger.Fields{
+			"clef_url": cfg.Signer.ClefUrl,
+		})
+
+		clefSigner, err := signer.NewClefSigner(cfg.Signer.ClefUrl, cfg.Signer.Address)
+		if err != nil {
+			panic(err)
+		}
+		s.signer = clefSigner
+		return
+	}
+
+	s.initKeystore()
+
+	keystoreSigner, err := signer.NewKeystoreSigner(s.keystore.GetSelectedPrivateKey())
+	if err != nil {
+		panic(err)
+	}
+	s.signer = keystoreSigner
+
+	if cfg.Keystore.RetiringAccount == "" {
+		return
+	}
+
+	retiringKey, err := s.keystore.GetByAccount(cfg.Keystore.RetiringAccount)
+	if err != nil {
+		panic(err)
+	}
+
+	retiringSigner, err := signer.NewKeystoreSigner(retiringKey.GetPrivate())
+	if err != nil {
+		panic(err)
+	}
+	s.retiringSigner = retiringSigner
+}
+
 func (s *Server) initKeystore() {
 
 	cfg := s.srvCtx.Config
@@ -73,20 +125,36 @@ func (s *Server) initKeystore() {
 		"keystore": cfg.Keystore.File,
 	})
 
-	ks, err := keystore.NewKeyStorage(cfg.Keystore.File)
-	if err != nil {
-		logger.Warn("app", "initKeystore", "open keystorage",
-			"can't read keystorage, creating a new one...")
+	var ks *keystore.Keystorage
+	var err error
+	if cfg.Keystore.Type == config.KeystoreTypeV3 {
+		ks, err = keystore.NewV3KeyStorage(cfg.Keystore.File)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		ks, err = keystore.NewKeyStorage(cfg.Keystore.File)
+		if err != nil {
+			logger.Warn("app", "initKeystore", "open keystorage",
+				"can't read keystorage, creating a new one...")
+		}
 	}
 
 	s.keystore = ks
 
-	decryptPassword := ""
-	if s.decryptPass != "" {
-		decryptPassword = getPasswordFromFileOrFlag(s.decryptPass)
+	decryptPassword, err := s.decryptPass.Read()
+	if err != nil {
+		panic(err)
+	}
+
+	if decryptPassword != "" {
+		err = s.keystore.CheckToken(decryptPassword)
+		if err != nil && s.decryptPass.IsExplicit() {
+			panic(fmt.Sprintf("cannot decrypt keystore with password from %s", s.decryptPass))
+		}
 	}
 
-	if decryptPassword == "" || (s.keystore.CheckToken(decryptPassword) != nil) {
+	if decryptPassword == "" || err != nil {
 		err = s.auth()
 		if err != nil {
 			panic(err)
@@ -117,10 +185,22 @@ func (s *Server) initDatabase() {
 func (s *Server) initService() {
 	logger.Info("app", "initService", "", "initialise service")
 
-	srv, err := service.NewService(s.ctx, s.srvCtx.Config, []byte(s.keystore.GetSelectedPrivateKey()),
-		s.db, s.keystore.KeyStore.GetToken())
+	// the decryption password is only available when using the keystore
+	authToken := ""
+	if s.keystore != nil {
+		authToken = s.keystore.KeyStore.GetToken()
+	}
+
+	srv, err := service.NewService(s.ctx, s.srvCtx.Config, s.signer, s.db, authToken)
 	if err != nil {
 		panic(err)
 	}
+	if s.retiringSigner != nil {
+		err = srv.SetRetiringSigner(s.retiringSigner)
+		if err != nil {
+			panic(err)
+		}
+	}
+	srv.SetDryRun(s.dryRun)
 	s.srv = srv
 }
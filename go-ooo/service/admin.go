@@ -0,0 +1,31 @@
+package service
+
+import (
+	go_ooo_types "go-ooo/types"
+)
+
+// processAdminTask runs the admin task, and records it in the audit log
+func (s *Service) processAdminTask(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	resp := s.runAdminTask(task)
+	s.recordAdminAudit(task, resp)
+	return resp
+}
+
+// runAdminTask handles admin tasks which only require the database, and passes
+// any other tasks to the OoO Router service for on-chain processing
+func (s *Service) runAdminTask(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	switch task.Task {
+	case "list_pending_tokens":
+		return s.listPendingTokens(task)
+	case "approve_token":
+		return s.setTokenCanonical(task, true)
+	case "revoke_token":
+		return s.setTokenCanonical(task, false)
+	case "show_provenance":
+		return s.showPriceProvenance(task)
+	case "dry_run_report":
+		return s.dryRunReport(task)
+	default:
+		return s.oooRouterService.ProcessAdminTask(task)
+	}
+}
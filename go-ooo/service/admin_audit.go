@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+)
+
+// recordAdminAudit appends the task and its result to the admin audit log. Any Tx sent by
+// the task is checked for its on-chain outcome by updateAdminAuditOutcomes
+func (s *Service) recordAdminAudit(task go_ooo_types.AdminTask, resp go_ooo_types.AdminTaskResponse) {
+	params, err := json.Marshal(task)
+	if err != nil {
+		logger.Error("service", "recordAdminAudit", "marshal params", err.Error())
+	}
+
+	entry := models.AdminAudit{
+		TokenId:  task.TokenId,
+		Task:     task.Task,
+		Params:   string(params),
+		Success:  resp.Success,
+		Result:   resp.Result,
+		Error:    resp.Error,
+		TxHash:   resp.TxHash,
+		TxStatus: models.AUDIT_TX_STATUS_NONE,
+	}
+
+	if resp.TxHash != "" {
+		entry.TxStatus = models.AUDIT_TX_STATUS_PENDING
+	}
+
+	err = s.db.InsertAdminAudit(entry)
+	if err != nil {
+		logger.ErrorWithFields("service", "recordAdminAudit", "insert audit entry", err.Error(), logger.Fields{
+			"task":     task.Task,
+			"token_id": task.TokenId,
+			"tx":       resp.TxHash,
+		})
+	}
+}
+
+// updateAdminAuditOutcomes records the on-chain outcome of Txs sent by admin tasks, once
+// they have enough block confirmations
+func (s *Service) updateAdminAuditOutcomes() {
+	pending, err := s.db.GetAdminAuditPendingTxs()
+	if err != nil {
+		logger.Error("service", "updateAdminAuditOutcomes", "get pending txs", err.Error())
+		return
+	}
+
+	for _, entry := range pending {
+		confirmed, success, blockNumber, gasUsed, err := s.oooRouterService.GetTxOutcome(entry.GetTxHash())
+		if err != nil {
+			logger.ErrorWithFields("service", "updateAdminAuditOutcomes", "get tx outcome", err.Error(), logger.Fields{
+				"tx": entry.GetTxHash(),
+			})
+			continue
+		}
+
+		if !confirmed {
+			continue
+		}
+
+		status := models.AUDIT_TX_STATUS_CONFIRMED
+		if !success {
+			status = models.AUDIT_TX_STATUS_FAILED
+		}
+
+		err = s.db.UpdateAdminAuditTxOutcome(entry.GetId(), status, blockNumber, gasUsed)
+		if err != nil {
+			logger.ErrorWithFields("service", "updateAdminAuditOutcomes", "update tx outcome", err.Error(), logger.Fields{
+				"tx": entry.GetTxHash(),
+			})
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+)
+
+type shadowDeviation struct {
+	requestId string
+	endpoint  string
+	shadow    string
+	onChain   string
+	deviation float64
+}
+
+// dryRunReport compares the prices recorded by a dry-run node with those fulfilled on chain
+// by the production node
+func (s *Service) dryRunReport(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	var resp go_ooo_types.AdminTaskResponse
+	resp.AdminTask = task
+
+	shadows, err := s.db.GetShadowFulfilments()
+
+	if err != nil {
+		logger.Error("service", "dryRunReport", "get shadow fulfilments", err.Error())
+		resp.Error = err.Error()
+		resp.Success = false
+		return resp
+	}
+
+	var compared []shadowDeviation
+	exactMatches := 0
+	notFulfilledOnChain := 0
+	notPriced := 0
+	totalDeviation := float64(0)
+
+	for _, sh := range shadows {
+		if sh.GetPrice() == "" {
+			// fulfilled by the production node, but not priced by this node
+			notPriced++
+			continue
+		}
+
+		if sh.GetOnChainPrice() == "" {
+			notFulfilledOnChain++
+			continue
+		}
+
+		if sh.GetPrice() == sh.GetOnChainPrice() {
+			exactMatches++
+		}
+
+		d := shadowDeviation{
+			requestId: sh.GetRequestId(),
+			endpoint:  sh.GetEndpointDecoded(),
+			shadow:    sh.GetPrice(),
+			onChain:   sh.GetOnChainPrice(),
+			deviation: relativeDeviation(sh.GetPrice(), sh.GetOnChainPrice()),
+		}
+
+		totalDeviation += d.deviation
+		compared = append(compared, d)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\nDry-run Report\n\n")
+	sb.WriteString(fmt.Sprintf("Shadow records                  : %d\n", len(shadows)))
+	sb.WriteString(fmt.Sprintf("Compared with on-chain          : %d\n", len(compared)))
+	sb.WriteString(fmt.Sprintf("Exact matches                   : %d\n", exactMatches))
+	sb.WriteString(fmt.Sprintf("Priced, not fulfilled on-chain  : %d\n", notFulfilledOnChain))
+	sb.WriteString(fmt.Sprintf("Fulfilled on-chain, not priced  : %d\n", notPriced))
+
+	if len(compared) > 0 {
+		sort.Slice(compared, func(i, j int) bool {
+			return compared[i].deviation > compared[j].deviation
+		})
+
+		sb.WriteString(fmt.Sprintf("Mean deviation                  : %.4f%%\n", totalDeviation/float64(len(compared))*100))
+		sb.WriteString(fmt.Sprintf("Max deviation                   : %.4f%%\n", compared[0].deviation*100))
+
+		sb.WriteString("\nLargest deviations\n\n")
+		for i, d := range compared {
+			if i >= 10 {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("  %s %-20s shadow: %s on-chain: %s (%.4f%%)\n",
+				d.requestId, d.endpoint, d.shadow, d.onChain, d.deviation*100))
+		}
+	}
+
+	resp.Result = sb.String()
+	resp.Success = true
+
+	return resp
+}
+
+// relativeDeviation returns the deviation of a from b, where both are prices in wei
+func relativeDeviation(a, b string) float64 {
+	aF, ok := new(big.Float).SetString(a)
+	if !ok {
+		return 0
+	}
+	bF, ok := new(big.Float).SetString(b)
+	if !ok {
+		return 0
+	}
+
+	aVal, _ := aF.Float64()
+	bVal, _ := bF.Float64()
+
+	if bVal == 0 {
+		return 0
+	}
+
+	return math.Abs(aVal-bVal) / bVal
+}
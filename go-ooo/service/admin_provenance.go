@@ -0,0 +1,77 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+)
+
+func (s *Service) showPriceProvenance(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	var resp go_ooo_types.AdminTaskResponse
+	resp.AdminTask = task
+
+	request, err := s.db.FindByRequestId(task.RequestId)
+
+	if err != nil {
+		logger.ErrorWithFields("service", "showPriceProvenance", "find request", err.Error(), logger.Fields{
+			"request_id": task.RequestId,
+		})
+		resp.Error = err.Error()
+		resp.Success = false
+		return resp
+	}
+
+	provenance, err := s.db.GetPriceProvenance(task.RequestId)
+
+	if err != nil {
+		logger.ErrorWithFields("service", "showPriceProvenance", "get provenance", err.Error(), logger.Fields{
+			"request_id": task.RequestId,
+		})
+		resp.Error = err.Error()
+		resp.Success = false
+		return resp
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\nRequest ID     : %s\n", request.RequestId))
+	sb.WriteString(fmt.Sprintf("Endpoint       : %s\n", request.EndpointDecoded))
+	sb.WriteString(fmt.Sprintf("Price          : %s\n", request.PriceResult))
+	sb.WriteString(fmt.Sprintf("Source         : %s\n", request.PriceSource))
+	sb.WriteString(fmt.Sprintf("Status         : %s\n", request.GetRequestStatusString()))
+
+	if len(provenance) == 0 {
+		sb.WriteString("\nno provenance records found\n")
+	}
+
+	for i, p := range provenance {
+		sb.WriteString(fmt.Sprintf("\n[%d] %s\n", i+1, p.Source))
+		sb.WriteString(fmt.Sprintf("  Fetched       : %s\n", p.CreatedAt.Format(time.RFC3339)))
+		sb.WriteString(fmt.Sprintf("  Endpoint      : %s\n", p.Endpoint))
+		sb.WriteString(fmt.Sprintf("  Price         : %s\n", p.Price))
+		sb.WriteString(fmt.Sprintf("  Method        : %s\n", p.Method))
+		sb.WriteString(fmt.Sprintf("  dMax          : %v\n", p.DMax))
+		sb.WriteString(fmt.Sprintf("  Window        : %s\n", p.Window))
+		if !p.WindowStart.IsZero() {
+			sb.WriteString(fmt.Sprintf("  Window Start  : %s\n", p.WindowStart.Format(time.RFC3339)))
+		}
+		if !p.WindowEnd.IsZero() {
+			sb.WriteString(fmt.Sprintf("  Window End    : %s\n", p.WindowEnd.Format(time.RFC3339)))
+		}
+		sb.WriteString(fmt.Sprintf("  Raw Prices    : %d\n", p.RawPriceCount))
+		if p.RemovedValues != "" {
+			sb.WriteString(fmt.Sprintf("  Removed       : %s\n", p.RemovedValues))
+		}
+		sb.WriteString(fmt.Sprintf("  Sources       : %s\n", p.Sources))
+		if p.Response != "" {
+			sb.WriteString(fmt.Sprintf("  Response      : %s\n", p.Response))
+		}
+	}
+
+	resp.Result = sb.String()
+	resp.Success = true
+
+	return resp
+}
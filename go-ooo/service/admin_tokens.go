@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+)
+
+func (s *Service) listPendingTokens(task go_ooo_types.AdminTask) go_ooo_types.AdminTaskResponse {
+	var resp go_ooo_types.AdminTaskResponse
+	resp.AdminTask = task
+
+	tokens, err := s.db.GetPendingTokens(task.Chain)
+
+	if err != nil {
+		logger.Error("service", "listPendingTokens", "get pending tokens", err.Error())
+		resp.Error = err.Error()
+		resp.Success = false
+		return resp
+	}
+
+	if len(tokens) == 0 {
+		resp.Result = "no tokens pending review"
+		resp.Success = true
+		return resp
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d token(s) pending review\n", len(tokens)))
+	for _, t := range tokens {
+		canonical, _ := s.db.FindCanonicalToken(t.Chain, t.TokenSymbol)
+		current := "none"
+		if canonical.ID != 0 {
+			current = canonical.ContractAddress
+		}
+		sb.WriteString(fmt.Sprintf("  %-12s %-12s %s (current canonical: %s)\n",
+			t.Chain, t.TokenSymbol, t.ContractAddress, current))
+	}
+
+	resp.Result = sb.String()
+	resp.Success = true
+
+	return resp
+}
+
+func (s *Service) setTokenCanonical(task go_ooo_types.AdminTask, canonical bool) go_ooo_types.AdminTaskResponse {
+	var resp go_ooo_types.AdminTaskResponse
+	resp.AdminTask = task
+
+	logger.InfoWithFields("service", "setTokenCanonical", "", "begin", logger.Fields{
+		"chain":     task.Chain,
+		"contract":  task.ToOrConsumer,
+		"canonical": canonical,
+	})
+
+	token, err := s.db.SetTokenCanonical(task.Chain, task.ToOrConsumer, canonical)
+
+	if err != nil {
+		logger.ErrorWithFields("service", "setTokenCanonical", "set token canonical", err.Error(), logger.Fields{
+			"chain":     task.Chain,
+			"contract":  task.ToOrConsumer,
+			"canonical": canonical,
+		})
+		resp.Error = err.Error()
+		resp.Success = false
+		return resp
+	}
+
+	if canonical {
+		resp.Result = fmt.Sprintf("%s %s approved as canonical on %s", token.TokenSymbol, token.ContractAddress, token.Chain)
+	} else {
+		resp.Result = fmt.Sprintf("%s %s revoked on %s", token.TokenSymbol, token.ContractAddress, token.Chain)
+	}
+	resp.Success = true
+
+	return resp
+}
@@ -1,11 +1,17 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/params"
+	"go-ooo/database"
 	"go-ooo/database/models"
+	"go-ooo/logger"
 	go_ooo_types "go-ooo/types"
 	"math"
 	"math/big"
+	"time"
 )
 
 func (s *Service) ProcessAnalyticsTask(task go_ooo_types.AnalyticsTask) go_ooo_types.AnalyticsTaskResponse {
@@ -17,6 +23,10 @@ func (s *Service) ProcessAnalyticsTask(task go_ooo_types.AnalyticsTask) go_ooo_t
 		Result:  go_ooo_types.AnalyticsResult{},
 	}
 
+	if task.Report != "" {
+		return s.processProfitReport(task)
+	}
+
 	jobs, err := s.db.GetLastXSuccessfulRequests(task.NumTxs, task.Consumer)
 
 	if err != nil {
@@ -31,6 +41,15 @@ func (s *Service) ProcessAnalyticsTask(task go_ooo_types.AnalyticsTask) go_ooo_t
 		return resp
 	}
 
+	// Txs which reverted or were replaced since the oldest request analysed also cost gas. jobs
+	// are ordered newest first
+	failed, err := s.db.GetFailedFulfilmentsBetween(jobs[len(jobs)-1].CreatedAt, time.Now(), task.Consumer)
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		return resp
+	}
+
 	mostGasUsedContract := ""
 	leastGasUSedContract := ""
 
@@ -43,14 +62,44 @@ func (s *Service) ProcessAnalyticsTask(task go_ooo_types.AnalyticsTask) go_ooo_t
 		leastGasUSedContract = lgu.Consumer
 	}
 
+	// the caller may supply the price, otherwise the current price is used for the suggested
+	// fee, and the price at the time of each fulfilment for profit/loss
+	priceSource := "caller"
+	priceAt := func(time.Time) float64 {
+		return task.CurrXfundPrice
+	}
+
+	if task.CurrXfundPrice <= 0 {
+		task.CurrXfundPrice, priceSource, err = s.oooApi.GetXfundPriceEth()
+		if err != nil {
+			resp.Success = false
+			resp.Error = fmt.Sprintf("cannot get xFUND price: %s", err.Error())
+			return resp
+		}
+
+		if !task.SuggestFee {
+			// jobs are ordered newest first
+			history, err := s.oooApi.GetXfundPriceHistory(jobs[len(jobs)-1].UpdatedAt, jobs[0].UpdatedAt)
+			if err != nil {
+				resp.Success = false
+				resp.Error = fmt.Sprintf("cannot get xFUND price history: %s", err.Error())
+				return resp
+			}
+			priceAt = history.PriceAt
+		}
+	}
+
 	var analyticsData go_ooo_types.AnalyticsData
 
 	if task.SuggestFee {
-		suggestedFee, profit := runSuggestFee(jobs, task)
+		suggestedFee, profit := runSuggestFee(jobs, failed, task)
 		analyticsData.SuggestedFee = suggestedFee
 		analyticsData.Earnings.ProfitLossEth = profit
+		analyticsData.Earnings.CurrentXfundPriceEth = task.CurrXfundPrice
+		analyticsData.Earnings.XfundPriceSource = priceSource
 	} else {
-		analyticsData = runAnalytics(jobs, task)
+		analyticsData = runAnalytics(jobs, failed, task, priceAt)
+		analyticsData.Earnings.XfundPriceSource = priceSource
 
 		if task.Consumer == "" {
 			analyticsData.MostGasUsedConsumer = mostGasUsedContract
@@ -76,11 +125,107 @@ func (s *Service) ProcessAnalyticsTask(task go_ooo_types.AnalyticsTask) go_ooo_t
 	return resp
 }
 
-func runSuggestFee(rows []models.DataRequests, task go_ooo_types.AnalyticsTask) (float64, float64) {
+// gasBackfillBatch is the maximum number of fulfilment Txs of each kind whose receipts are
+// fetched each time backfillGas runs
+const gasBackfillBatch = 100
+
+// failedTxNotMinedAfter is how long after a failed fulfilment Tx was recorded that it is marked
+// as not mined if it still has no receipt. By then, a replaced or dropped Tx will not be mined
+const failedTxNotMinedAfter = time.Hour
+
+// TxGasReader returns the gas used and gas price of a mined Tx, or ethereum.NotFound if the Tx
+// has not been mined
+type TxGasReader interface {
+	GetTxGasUsage(txHash string) (uint64, uint64, error)
+}
+
+// runGasBackfill back-fills missing gas usage now, and then each time the gasBackfillTicker
+// ticks, until the service is stopped. It runs in its own goroutine, so that fetching receipts
+// does not block the job queue
+func (s *Service) runGasBackfill() {
+	backfillGas(s.db, s.oooRouterService, time.Now())
+	for {
+		select {
+		case t := <-s.gasBackfillTicker.C:
+			backfillGas(s.db, s.oooRouterService, t)
+		case <-s.gasBackfillDone:
+			return
+		}
+	}
+}
+
+// backfillGas fetches the receipts of fulfilment Txs with no gas used or gas price recorded, and
+// stores the values, so that analytics and fee adjustment include their cost. Failed Txs which
+// were never sent cost nothing and are left at zero. Failed Txs which have no receipt
+// failedTxNotMinedAfter after they were recorded are marked as not mined, and not fetched again
+func backfillGas(db *database.DB, receipts TxGasReader, now time.Time) {
+	jobs, err := db.GetRequestsMissingGas(gasBackfillBatch)
+	if err != nil {
+		logger.Error("service", "backfillGas", "GetRequestsMissingGas", err.Error())
+		return
+	}
+
+	for _, job := range jobs {
+		gasUsed, gasPrice, err := receipts.GetTxGasUsage(job.FulfillTxHash)
+		if err != nil {
+			logger.WarnWithFields("service", "backfillGas", "get fulfilment receipt", err.Error(), logger.Fields{
+				"request_id": job.RequestId,
+				"tx_hash":    job.FulfillTxHash,
+			})
+			continue
+		}
+
+		if err = db.UpdateFulfillmentGas(job.RequestId, gasUsed, gasPrice); err != nil {
+			logger.Error("service", "backfillGas", "UpdateFulfillmentGas", err.Error())
+		}
+	}
+
+	failed, err := db.GetFailedFulfilmentsMissingGas(gasBackfillBatch)
+	if err != nil {
+		logger.Error("service", "backfillGas", "GetFailedFulfilmentsMissingGas", err.Error())
+		return
+	}
+
+	for _, f := range failed {
+		gasUsed, gasPrice, err := receipts.GetTxGasUsage(f.TxHash)
+		if errors.Is(err, ethereum.NotFound) {
+			if now.Sub(f.CreatedAt) < failedTxNotMinedAfter {
+				// may still be mined
+				continue
+			}
+			if err = db.SetFailedFulfilmentNotMined(f.ID); err != nil {
+				logger.Error("service", "backfillGas", "SetFailedFulfilmentNotMined", err.Error())
+			}
+			continue
+		}
+		if err != nil {
+			logger.WarnWithFields("service", "backfillGas", "get failed fulfilment receipt", err.Error(), logger.Fields{
+				"request_id": f.RequestId,
+				"tx_hash":    f.TxHash,
+			})
+			continue
+		}
+
+		if err = db.UpdateFailedFulfilmentGas(f.ID, gasUsed, gasPrice); err != nil {
+			logger.Error("service", "backfillGas", "UpdateFailedFulfilmentGas", err.Error())
+		}
+	}
+}
+
+// runSuggestFee returns the lowest fee, rounded up to 0.0001 xFUND, which covers the mean cost
+// of a successful fulfilment, including the gas spent on failed Txs, and the profit it makes
+func runSuggestFee(rows []models.DataRequests, failed []database.FailedFulfilmentCost,
+	task go_ooo_types.AnalyticsTask) (float64, float64) {
+
 	numRows := float64(len(rows))
 
 	costSum := big.NewFloat(0)
 
+	for _, f := range failed {
+		cost := new(big.Float).Mul(new(big.Float).SetUint64(f.GasUsed), new(big.Float).SetUint64(f.GasPrice))
+		costSum = new(big.Float).Add(costSum, cost)
+	}
+
 	for _, reqRow := range rows {
 
 		gasVal := int64(reqRow.FulfillGasUsed)
@@ -119,7 +264,11 @@ func runSuggestFee(rows []models.DataRequests, task go_ooo_types.AnalyticsTask)
 	return finalSuggestion, profit
 }
 
-func runAnalytics(rows []models.DataRequests, task go_ooo_types.AnalyticsTask) go_ooo_types.AnalyticsData {
+// runAnalytics calculates gas, cost and earnings statistics for the fulfilments. Fees are
+// converted to ETH using the xFUND price returned by priceAt for the time of each fulfilment.
+// The gas spent on failed Txs is included in the total cost and profit/loss
+func runAnalytics(rows []models.DataRequests, failed []database.FailedFulfilmentCost, task go_ooo_types.AnalyticsTask,
+	priceAt func(time.Time) float64) go_ooo_types.AnalyticsData {
 
 	numRows := uint64(len(rows))
 
@@ -139,6 +288,7 @@ func runAnalytics(rows []models.DataRequests, task go_ooo_types.AnalyticsTask) g
 	costSum := big.NewFloat(0)
 
 	totalFees := big.NewFloat(0)
+	totalFeesEth := big.NewFloat(0)
 
 	for _, reqRow := range rows {
 
@@ -191,6 +341,19 @@ func runAnalytics(rows []models.DataRequests, task go_ooo_types.AnalyticsTask) g
 			reqFee = big.NewFloat(0).Mul(big.NewFloat(task.SimulationParams.XfundFee), big.NewFloat(params.GWei))
 		}
 		totalFees = big.NewFloat(0).Add(totalFees, reqFee)
+
+		reqFeeXfund := new(big.Float).Quo(reqFee, big.NewFloat(params.GWei))
+		reqFeeEth := new(big.Float).Mul(reqFeeXfund, big.NewFloat(priceAt(reqRow.UpdatedAt)))
+		totalFeesEth = big.NewFloat(0).Add(totalFeesEth, reqFeeEth)
+	}
+
+	failedCostSum := big.NewFloat(0)
+	for _, f := range failed {
+		gasPriceVal := f.GasPrice
+		if task.Simulate {
+			gasPriceVal = task.SimulationParams.GasPrice * 1e9
+		}
+		failedCostSum = new(big.Float).Add(failedCostSum, gasCostNative(f.GasUsed, gasPriceVal))
 	}
 
 	// gas
@@ -214,17 +377,18 @@ func runAnalytics(rows []models.DataRequests, task go_ooo_types.AnalyticsTask) g
 	costMaxEth := new(big.Float).Quo(costMax, big.NewFloat(params.Ether))
 	costMeanEth := new(big.Float).Quo(costMean, big.NewFloat(params.Ether))
 	totalCostEth := new(big.Float).Quo(costSum, big.NewFloat(params.Ether))
+	totalCostEth = new(big.Float).Add(totalCostEth, failedCostSum)
 
 	cMin, _ := costMinEth.Float64()
 	cMax, _ := costMaxEth.Float64()
 	cMean, _ := costMeanEth.Float64()
 	tCost, _ := totalCostEth.Float64()
+	fCost, _ := failedCostSum.Float64()
 
 	// fees
 	totalFeesTokens := new(big.Float).Quo(totalFees, big.NewFloat(params.GWei))
 	totalFeesXfund, _ := totalFeesTokens.Float64()
 
-	totalFeesEth := new(big.Float).Mul(big.NewFloat(totalFeesXfund), big.NewFloat(task.CurrXfundPrice))
 	totalFeesEthFloat64, _ := totalFeesEth.Float64()
 
 	profitLoss := new(big.Float).Sub(totalFeesEth, totalCostEth)
@@ -251,8 +415,10 @@ func runAnalytics(rows []models.DataRequests, task go_ooo_types.AnalyticsTask) g
 			TotalFeesEarnedXfund: totalFeesXfund,
 			TotalFeesEarnedEth:   totalFeesEthFloat64,
 			TotalCostsEth:        tCost,
+			FailedTxCostsEth:     fCost,
 			ProfitLossEth:        profitLossFloat64,
 		},
-		NumberAnalysed: numRows,
+		NumberAnalysed:  numRows,
+		NumberFailedTxs: uint64(len(failed)),
 	}
 }
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-ooo/database"
+	"go-ooo/database/models"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	ReportPeriodDay   = "day"
+	ReportPeriodWeek  = "week"
+	ReportPeriodMonth = "month"
+
+	ReportFormatJson = "json"
+	ReportFormatCsv  = "csv"
+)
+
+// profitReportHeader is the header row of a CSV profitability report
+var profitReportHeader = []string{
+	"period", "period_start", "consumer", "requests", "fees_xfund", "fees_native",
+	"gas_cost_native", "failed_txs", "failed_tx_cost_native", "net_profit_native",
+}
+
+// processProfitReport runs a profitability report for the task's period and date range
+func (s *Service) processProfitReport(task go_ooo_types.AnalyticsTask) go_ooo_types.AnalyticsTaskResponse {
+	resp := go_ooo_types.AnalyticsTaskResponse{
+		Task:    task,
+		Success: false,
+		Error:   "",
+		Result:  go_ooo_types.AnalyticsResult{},
+	}
+
+	from, to, err := reportRange(task.Report, task.From, task.To, time.Now())
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	if task.Format != "" && task.Format != ReportFormatJson && task.Format != ReportFormatCsv {
+		resp.Error = fmt.Sprintf("invalid report format %s. Must be json or csv", task.Format)
+		return resp
+	}
+
+	jobs, err := s.db.GetSuccessfulRequestsBetween(from, to, task.Consumer)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	failed, err := s.db.GetFailedFulfilmentsBetween(from, to, task.Consumer)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	// the caller may supply the price, otherwise the price at the time of each fulfilment is used
+	priceAt := func(time.Time) float64 {
+		return task.CurrXfundPrice
+	}
+
+	if task.CurrXfundPrice <= 0 && len(jobs) > 0 {
+		history, err := s.oooApi.GetXfundPriceHistory(from, to)
+		if err != nil {
+			resp.Error = fmt.Sprintf("cannot get xFUND price history: %s", err.Error())
+			return resp
+		}
+		priceAt = history.PriceAt
+	}
+
+	resp.Task.From = from
+	resp.Task.To = to
+	resp.Success = true
+	resp.Result = go_ooo_types.AnalyticsResult{
+		Filters: go_ooo_types.AnalyticsFilter{
+			ConsumerContract: task.Consumer,
+		},
+		Report: runProfitReport(jobs, failed, task.Report, task.ByConsumer, priceAt),
+	}
+
+	return resp
+}
+
+// reportRange validates the report period, and returns the start and end of the report. from is
+// moved back to the start of its period. If from is not set, the report covers the last 30 days,
+// 12 weeks or 12 months. If to is not set, now is used
+func reportRange(period string, from time.Time, to time.Time, now time.Time) (time.Time, time.Time, error) {
+	if to.IsZero() {
+		to = now
+	}
+	to = to.UTC()
+
+	if from.IsZero() {
+		switch period {
+		case ReportPeriodDay:
+			from = to.AddDate(0, 0, -29)
+		case ReportPeriodWeek:
+			from = to.AddDate(0, 0, -7*11)
+		case ReportPeriodMonth:
+			// not AddDate, which would move e.g. the 31st into the following month
+			from = time.Date(to.Year(), to.Month()-11, 1, 0, 0, 0, 0, time.UTC)
+		default:
+			return from, to, errors.New(fmt.Sprintf("invalid report period %s. Must be day, week or month", period))
+		}
+	} else if period != ReportPeriodDay && period != ReportPeriodWeek && period != ReportPeriodMonth {
+		return from, to, errors.New(fmt.Sprintf("invalid report period %s. Must be day, week or month", period))
+	}
+
+	_, from = reportPeriod(from, period)
+
+	if !from.Before(to) {
+		return from, to, errors.New("report start must be before the end")
+	}
+
+	return from, to, nil
+}
+
+// reportPeriod returns the name and start of the period containing t, in UTC. Weeks are ISO
+// weeks, starting on Monday
+func reportPeriod(t time.Time, period string) (string, time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch period {
+	case ReportPeriodWeek:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week), start
+	case ReportPeriodMonth:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start
+	default:
+		return day.Format("2006-01-02"), day
+	}
+}
+
+// runProfitReport totals the fulfilments and failed fulfilment Txs for each period, and each
+// consumer within the period if byConsumer is true. Fees are converted to the native currency
+// using the xFUND price returned by priceAt for the time of each fulfilment. Periods with no
+// activity are omitted
+func runProfitReport(jobs []models.DataRequests, failed []database.FailedFulfilmentCost, period string,
+	byConsumer bool, priceAt func(time.Time) float64) []go_ooo_types.ProfitReportRow {
+
+	type bucket struct {
+		row        go_ooo_types.ProfitReportRow
+		fees       *big.Float
+		feesNative *big.Float
+		gasCost    *big.Float
+		failedCost *big.Float
+	}
+
+	buckets := make(map[string]*bucket)
+
+	getBucket := func(t time.Time, consumer string) *bucket {
+		name, start := reportPeriod(t, period)
+		if !byConsumer {
+			consumer = ""
+		}
+		key := name + "/" + consumer
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{
+				row: go_ooo_types.ProfitReportRow{
+					Period:      name,
+					PeriodStart: start,
+					Consumer:    consumer,
+				},
+				fees:       big.NewFloat(0),
+				feesNative: big.NewFloat(0),
+				gasCost:    big.NewFloat(0),
+				failedCost: big.NewFloat(0),
+			}
+			buckets[key] = b
+		}
+		return b
+	}
+
+	for _, reqRow := range jobs {
+		b := getBucket(reqRow.UpdatedAt, reqRow.Consumer)
+		b.row.Requests++
+
+		reqFeeXfund := new(big.Float).Quo(new(big.Float).SetUint64(reqRow.Fee), big.NewFloat(params.GWei))
+		b.fees = new(big.Float).Add(b.fees, reqFeeXfund)
+		reqFeeNative := new(big.Float).Mul(reqFeeXfund, big.NewFloat(priceAt(reqRow.UpdatedAt)))
+		b.feesNative = new(big.Float).Add(b.feesNative, reqFeeNative)
+
+		b.gasCost = new(big.Float).Add(b.gasCost, gasCostNative(reqRow.FulfillGasUsed, reqRow.FulfillGasPrice))
+	}
+
+	for _, f := range failed {
+		b := getBucket(f.CreatedAt, f.Consumer)
+		b.row.FailedTxs++
+		b.failedCost = new(big.Float).Add(b.failedCost, gasCostNative(f.GasUsed, f.GasPrice))
+	}
+
+	report := make([]go_ooo_types.ProfitReportRow, 0, len(buckets))
+	for _, b := range buckets {
+		net := new(big.Float).Sub(b.feesNative, b.gasCost)
+		net = new(big.Float).Sub(net, b.failedCost)
+
+		b.row.FeesXfund, _ = b.fees.Float64()
+		b.row.FeesNative, _ = b.feesNative.Float64()
+		b.row.GasCostNative, _ = b.gasCost.Float64()
+		b.row.FailedTxCostNative, _ = b.failedCost.Float64()
+		b.row.NetProfitNative, _ = net.Float64()
+
+		report = append(report, b.row)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if !report[i].PeriodStart.Equal(report[j].PeriodStart) {
+			return report[i].PeriodStart.Before(report[j].PeriodStart)
+		}
+		return report[i].Consumer < report[j].Consumer
+	})
+
+	return report
+}
+
+// gasCostNative returns the cost of a Tx in the native currency
+func gasCostNative(gasUsed uint64, gasPrice uint64) *big.Float {
+	cost := new(big.Float).Mul(new(big.Float).SetUint64(gasUsed), new(big.Float).SetUint64(gasPrice))
+	return new(big.Float).Quo(cost, big.NewFloat(params.Ether))
+}
+
+// ProfitReportCsv returns the report as CSV, with a header row
+func ProfitReportCsv(report []go_ooo_types.ProfitReportRow) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(profitReportHeader); err != nil {
+		return nil, err
+	}
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	for _, r := range report {
+		record := []string{
+			r.Period,
+			r.PeriodStart.Format(time.RFC3339),
+			r.Consumer,
+			strconv.FormatUint(r.Requests, 10),
+			formatFloat(r.FeesXfund),
+			formatFloat(r.FeesNative),
+			formatFloat(r.GasCostNative),
+			strconv.FormatUint(r.FailedTxs, 10),
+			formatFloat(r.FailedTxCostNative),
+			formatFloat(r.NetProfitNative),
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,217 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/service"
+	go_ooo_types "go-ooo/types"
+)
+
+func date(year int, month time.Month, day int, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestReportPeriod(t *testing.T) {
+	tests := []struct {
+		name          string
+		t             time.Time
+		period        string
+		expectedName  string
+		expectedStart time.Time
+	}{
+		{"day", date(2024, 3, 5, 13), service.ReportPeriodDay, "2024-03-05", date(2024, 3, 5, 0)},
+		{"day converted to UTC", time.Date(2024, 3, 5, 23, 0, 0, 0, time.FixedZone("UTC-2", -2*3600)),
+			service.ReportPeriodDay, "2024-03-06", date(2024, 3, 6, 0)},
+		{"week on Monday", date(2024, 3, 4, 0), service.ReportPeriodWeek, "2024-W10", date(2024, 3, 4, 0)},
+		{"week on Sunday", date(2024, 3, 10, 23), service.ReportPeriodWeek, "2024-W10", date(2024, 3, 4, 0)},
+		{"week Sunday to Monday", date(2024, 3, 11, 0), service.ReportPeriodWeek, "2024-W11", date(2024, 3, 11, 0)},
+		{"week 53 across the year end", date(2021, 1, 3, 12), service.ReportPeriodWeek, "2020-W53", date(2020, 12, 28, 0)},
+		{"week 1 starting in the previous year", date(2024, 12, 31, 12), service.ReportPeriodWeek, "2025-W01", date(2024, 12, 30, 0)},
+		{"month start", date(2024, 3, 1, 0), service.ReportPeriodMonth, "2024-03", date(2024, 3, 1, 0)},
+		{"month end", date(2024, 2, 29, 23), service.ReportPeriodMonth, "2024-02", date(2024, 2, 1, 0)},
+		{"month across the year end", date(2024, 12, 31, 23), service.ReportPeriodMonth, "2024-12", date(2024, 12, 1, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, start := service.ReportPeriod(tt.t, tt.period)
+			require.Equal(t, tt.expectedName, name)
+			require.Equal(t, tt.expectedStart, start)
+		})
+	}
+}
+
+func TestReportRange(t *testing.T) {
+	now := date(2024, 3, 31, 15)
+
+	tests := []struct {
+		name         string
+		period       string
+		from         time.Time
+		to           time.Time
+		expectedFrom time.Time
+		expectedTo   time.Time
+		err          string
+	}{
+		{name: "default day", period: service.ReportPeriodDay,
+			expectedFrom: date(2024, 3, 2, 0), expectedTo: now},
+		{name: "default week", period: service.ReportPeriodWeek,
+			expectedFrom: date(2024, 1, 8, 0), expectedTo: now},
+		{name: "default month", period: service.ReportPeriodMonth,
+			expectedFrom: date(2023, 4, 1, 0), expectedTo: now},
+		{name: "default from before to", period: service.ReportPeriodMonth, to: date(2024, 1, 15, 0),
+			expectedFrom: date(2023, 2, 1, 0), expectedTo: date(2024, 1, 15, 0)},
+		{name: "from moved to the start of its week", period: service.ReportPeriodWeek, from: date(2024, 3, 10, 12),
+			expectedFrom: date(2024, 3, 4, 0), expectedTo: now},
+		{name: "from moved to the start of its month", period: service.ReportPeriodMonth, from: date(2024, 3, 10, 12),
+			expectedFrom: date(2024, 3, 1, 0), expectedTo: now},
+		{name: "invalid period", period: "year", err: "invalid report period year"},
+		{name: "invalid period with from", period: "year", from: date(2024, 3, 1, 0), err: "invalid report period year"},
+		{name: "from after to", period: service.ReportPeriodDay, from: date(2024, 3, 20, 0), to: date(2024, 3, 10, 0),
+			err: "report start must be before the end"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := service.ReportRange(tt.period, tt.from, tt.to, now)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedFrom, from)
+			require.Equal(t, tt.expectedTo, to)
+		})
+	}
+}
+
+func TestRunProfitReport(t *testing.T) {
+	const (
+		consumerA = "0xA"
+		consumerB = "0xB"
+	)
+
+	// 1 xFUND fee, and 0.001 ETH gas
+	job := func(at time.Time, consumer string) models.DataRequests {
+		return models.DataRequests{
+			Consumer:        consumer,
+			Fee:             1_000_000_000,
+			FulfillGasUsed:  100_000,
+			FulfillGasPrice: 10_000_000_000,
+			Model:           gorm.Model{UpdatedAt: at},
+		}
+	}
+	// 0.0005 ETH gas
+	failedTx := func(at time.Time, consumer string) database.FailedFulfilmentCost {
+		return database.FailedFulfilmentCost{
+			Consumer:  consumer,
+			GasUsed:   50_000,
+			GasPrice:  10_000_000_000,
+			CreatedAt: at,
+		}
+	}
+	priceAt := func(at time.Time) float64 {
+		// the price doubles in 2025
+		if at.Year() >= 2025 {
+			return 0.004
+		}
+		return 0.002
+	}
+
+	jobs := []models.DataRequests{
+		job(date(2024, 12, 29, 23), consumerA), // Sunday, 2024-W52
+		job(date(2024, 12, 30, 0), consumerB),  // Monday, 2025-W01
+		job(date(2025, 1, 1, 12), consumerA),   // 2025-W01
+	}
+	failed := []database.FailedFulfilmentCost{
+		failedTx(date(2024, 12, 30, 1), consumerA), // 2025-W01
+		failedTx(date(2025, 1, 7, 0), consumerB),   // failed only, 2025-W02
+	}
+
+	tests := []struct {
+		name       string
+		period     string
+		byConsumer bool
+		expected   []go_ooo_types.ProfitReportRow
+	}{
+		{
+			name:   "week across the year end",
+			period: service.ReportPeriodWeek,
+			expected: []go_ooo_types.ProfitReportRow{
+				{Period: "2024-W52", PeriodStart: date(2024, 12, 23, 0), Requests: 1, FeesXfund: 1, FeesNative: 0.002,
+					GasCostNative: 0.001, NetProfitNative: 0.001},
+				{Period: "2025-W01", PeriodStart: date(2024, 12, 30, 0), Requests: 2, FeesXfund: 2, FeesNative: 0.006,
+					GasCostNative: 0.002, FailedTxs: 1, FailedTxCostNative: 0.0005, NetProfitNative: 0.0035},
+				{Period: "2025-W02", PeriodStart: date(2025, 1, 6, 0), FailedTxs: 1, FailedTxCostNative: 0.0005,
+					NetProfitNative: -0.0005},
+			},
+		},
+		{
+			name:       "week by consumer",
+			period:     service.ReportPeriodWeek,
+			byConsumer: true,
+			expected: []go_ooo_types.ProfitReportRow{
+				{Period: "2024-W52", PeriodStart: date(2024, 12, 23, 0), Consumer: consumerA, Requests: 1, FeesXfund: 1,
+					FeesNative: 0.002, GasCostNative: 0.001, NetProfitNative: 0.001},
+				{Period: "2025-W01", PeriodStart: date(2024, 12, 30, 0), Consumer: consumerA, Requests: 1, FeesXfund: 1,
+					FeesNative: 0.004, GasCostNative: 0.001, FailedTxs: 1, FailedTxCostNative: 0.0005, NetProfitNative: 0.0025},
+				{Period: "2025-W01", PeriodStart: date(2024, 12, 30, 0), Consumer: consumerB, Requests: 1, FeesXfund: 1,
+					FeesNative: 0.002, GasCostNative: 0.001, NetProfitNative: 0.001},
+				{Period: "2025-W02", PeriodStart: date(2025, 1, 6, 0), Consumer: consumerB, FailedTxs: 1,
+					FailedTxCostNative: 0.0005, NetProfitNative: -0.0005},
+			},
+		},
+		{
+			name:   "month",
+			period: service.ReportPeriodMonth,
+			expected: []go_ooo_types.ProfitReportRow{
+				{Period: "2024-12", PeriodStart: date(2024, 12, 1, 0), Requests: 2, FeesXfund: 2, FeesNative: 0.004,
+					GasCostNative: 0.002, FailedTxs: 1, FailedTxCostNative: 0.0005, NetProfitNative: 0.0015},
+				{Period: "2025-01", PeriodStart: date(2025, 1, 1, 0), Requests: 1, FeesXfund: 1, FeesNative: 0.004,
+					GasCostNative: 0.001, FailedTxs: 1, FailedTxCostNative: 0.0005, NetProfitNative: 0.0025},
+			},
+		},
+		{
+			name:   "day",
+			period: service.ReportPeriodDay,
+			expected: []go_ooo_types.ProfitReportRow{
+				{Period: "2024-12-29", PeriodStart: date(2024, 12, 29, 0), Requests: 1, FeesXfund: 1, FeesNative: 0.002,
+					GasCostNative: 0.001, NetProfitNative: 0.001},
+				{Period: "2024-12-30", PeriodStart: date(2024, 12, 30, 0), Requests: 1, FeesXfund: 1, FeesNative: 0.002,
+					GasCostNative: 0.001, FailedTxs: 1, FailedTxCostNative: 0.0005, NetProfitNative: 0.0005},
+				{Period: "2025-01-01", PeriodStart: date(2025, 1, 1, 0), Requests: 1, FeesXfund: 1, FeesNative: 0.004,
+					GasCostNative: 0.001, NetProfitNative: 0.003},
+				{Period: "2025-01-07", PeriodStart: date(2025, 1, 7, 0), FailedTxs: 1, FailedTxCostNative: 0.0005,
+					NetProfitNative: -0.0005},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := service.RunProfitReport(jobs, failed, tt.period, tt.byConsumer, priceAt)
+			require.Len(t, report, len(tt.expected))
+			for i, expected := range tt.expected {
+				row := report[i]
+				require.Equal(t, expected.Period, row.Period)
+				require.Equal(t, expected.PeriodStart, row.PeriodStart)
+				require.Equal(t, expected.Consumer, row.Consumer)
+				require.Equal(t, expected.Requests, row.Requests)
+				require.Equal(t, expected.FailedTxs, row.FailedTxs)
+				require.InDelta(t, expected.FeesXfund, row.FeesXfund, 1e-12)
+				require.InDelta(t, expected.FeesNative, row.FeesNative, 1e-12)
+				require.InDelta(t, expected.GasCostNative, row.GasCostNative, 1e-12)
+				require.InDelta(t, expected.FailedTxCostNative, row.FailedTxCostNative, 1e-12)
+				require.InDelta(t, expected.NetProfitNative, row.NetProfitNative, 1e-12)
+			}
+		})
+	}
+
+	require.Empty(t, service.RunProfitReport(nil, nil, service.ReportPeriodDay, false, priceAt))
+}
@@ -0,0 +1,103 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/stretchr/testify/require"
+
+	"go-ooo/database/models"
+	"go-ooo/service"
+)
+
+// receiptReader returns the gas usage of the Txs in receipts, ethereum.NotFound for the other
+// Txs, and records which Txs were queried
+type receiptReader struct {
+	receipts map[string][2]uint64
+	err      error
+	queried  []string
+}
+
+func (r *receiptReader) GetTxGasUsage(txHash string) (uint64, uint64, error) {
+	r.queried = append(r.queried, txHash)
+	if r.err != nil {
+		return 0, 0, r.err
+	}
+	receipt, ok := r.receipts[txHash]
+	if !ok {
+		return 0, 0, ethereum.NotFound
+	}
+	return receipt[0], receipt[1], nil
+}
+
+func TestBackfillGas(t *testing.T) {
+	db := newTestDb(t)
+
+	require.NoError(t, db.Create(&models.DataRequests{RequestId: "01", Consumer: "0xa", FulfillTxHash: "0xaa",
+		JobStatus: models.JOB_STATUS_SUCCESS}).Error)
+	// already recorded
+	require.NoError(t, db.Create(&models.DataRequests{RequestId: "02", Consumer: "0xa", FulfillTxHash: "0xbb",
+		FulfillGasUsed: 1, FulfillGasPrice: 1, JobStatus: models.JOB_STATUS_SUCCESS}).Error)
+
+	require.NoError(t, db.InsertNewFailedFulfilment("01", "0xcc", 0, 0, "tx reverted"))
+	require.NoError(t, db.InsertNewFailedFulfilment("01", "0xdd", 0, 0, models.FAIL_REASON_TX_REPLACED))
+	// never sent
+	require.NoError(t, db.InsertNewFailedFulfilment("01", "", 0, 0, "tx reverted"))
+
+	receipts := &receiptReader{receipts: map[string][2]uint64{
+		"0xaa": {30000, 20},
+		"0xcc": {25000, 30},
+	}}
+
+	// a transient error is retried
+	service.BackfillGas(db, &receiptReader{err: errors.New("connection refused")}, time.Now().Add(time.Hour*2))
+	failed, err := db.GetFailedFulfilments("01")
+	require.NoError(t, err)
+	require.False(t, failed[1].GetNotMined())
+
+	// 0xdd may still be mined
+	service.BackfillGas(db, receipts, time.Now())
+	require.Equal(t, []string{"0xaa", "0xcc", "0xdd"}, receipts.queried)
+
+	req, err := db.FindByRequestId("01")
+	require.NoError(t, err)
+	require.Equal(t, uint64(30000), req.GetFulfillGasUsed())
+	require.Equal(t, uint64(20), req.GetFulfillGasPrice())
+
+	failed, err = db.GetFailedFulfilments("01")
+	require.NoError(t, err)
+	require.Equal(t, uint64(25000), failed[0].GetGasUsed())
+	require.Equal(t, uint64(30), failed[0].GetGasPrice())
+	require.False(t, failed[1].GetNotMined())
+
+	// 0xdd is marked as not mined once it is old enough
+	receipts.queried = nil
+	service.BackfillGas(db, receipts, time.Now().Add(time.Hour*2))
+	require.Equal(t, []string{"0xdd"}, receipts.queried)
+
+	failed, err = db.GetFailedFulfilments("01")
+	require.NoError(t, err)
+	require.True(t, failed[1].GetNotMined())
+	require.Equal(t, uint64(0), failed[1].GetGasUsed())
+
+	// and is not fetched again
+	receipts.queried = nil
+	service.BackfillGas(db, receipts, time.Now().Add(time.Hour*3))
+	require.Empty(t, receipts.queried)
+}
+
+func TestGasBackfillStops(t *testing.T) {
+	stop, returned := service.StartGasBackfillTestService(newTestDb(t))
+
+	// let the ticker tick
+	time.Sleep(10 * time.Millisecond)
+	stop()
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("gas back-fill did not stop")
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"go-ooo/apitoken"
+	"go-ooo/logger"
+)
+
+const (
+	// echo context key holding the ID of the token used to authenticate the request
+	contextTokenId = "token_id"
+	// echo context key holding the scopes granted to the token
+	contextScopes = "token_scopes"
+	// ID recorded for requests authenticated using the service's decryption password
+	defaultTokenId = "default"
+)
+
+// validateApiKey authenticates the request using either the service's decryption password,
+// which is granted all scopes, or a named API token which has not expired or been revoked.
+// The decryption password is not set when using an external signer
+func (s *Service) validateApiKey(key string, c echo.Context) (bool, error) {
+	if s.authToken != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.authToken)) == 1 {
+		c.Set(contextTokenId, defaultTokenId)
+		c.Set(contextScopes, apitoken.AllScopes)
+		return true, nil
+	}
+
+	token, err := s.db.FindApiTokenByHash(apitoken.Hash(key))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		logger.Error("service", "validateApiKey", "db.FindApiTokenByHash", err.Error())
+		return false, err
+	}
+
+	now := time.Now()
+	if !token.IsActive(now) {
+		logger.WarnWithFields("service", "validateApiKey", "check token", "inactive token used", logger.Fields{
+			"token_id": token.GetName(),
+			"status":   token.GetStatusString(now),
+		})
+		return false, nil
+	}
+
+	_ = s.db.UpdateApiTokenLastUsed(token.GetId(), now)
+
+	c.Set(contextTokenId, token.GetName())
+	c.Set(contextScopes, token.GetScopes())
+	return true, nil
+}
+
+// hasScope returns true if the token used to authenticate the request was granted the scope
+func hasScope(c echo.Context, scope string) bool {
+	scopes, _ := c.Get(contextScopes).([]string)
+	return apitoken.HasScope(scopes, scope)
+}
+
+// requireScope returns middleware which rejects requests whose token does not have the scope
+func requireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !hasScope(c, scope) {
+				return c.JSON(http.StatusForbidden, fmt.Sprintf("token does not have the %s scope", scope))
+			}
+			return next(c)
+		}
+	}
+}
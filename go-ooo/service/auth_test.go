@@ -0,0 +1,146 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"go-ooo/apitoken"
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/logger"
+	"go-ooo/service"
+)
+
+const testPassword = "decryption-password"
+
+func newTestDb(t *testing.T) *database.DB {
+	logger.SetLogLevel("fatal")
+
+	cfg := config.DefaultConfig()
+	cfg.Database.Dialect = "sqlite"
+	cfg.Database.Storage = filepath.Join(t.TempDir(), "go-ooo.sqlite")
+
+	db, err := database.NewDb(cfg)
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+
+	return db
+}
+
+// newToken creates a named API token with the scopes, and returns it
+func newToken(t *testing.T, db *database.DB, name string, scopes ...string) string {
+	token, hash, err := apitoken.Generate()
+	require.NoError(t, err)
+	require.NoError(t, db.InsertApiToken(name, hash, scopes, nil))
+	return token
+}
+
+func doRequest(e *echo.Echo, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+key)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthKeys(t *testing.T) {
+	db := newTestDb(t)
+	e := service.NewEchoTestService(db, testPassword)
+	readToken := newToken(t, db, "grafana", apitoken.ScopeRead)
+
+	// the decryption password and a valid token are accepted
+	rec := doRequest(e, http.MethodGet, "/requests/01", testPassword, "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/requests/01", readToken, "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// unknown keys, including a prefix of the password, are not
+	for _, key := range []string{"wrong", testPassword[:5], testPassword + "x"} {
+		rec = doRequest(e, http.MethodGet, "/requests/01", key, "")
+		require.Equal(t, http.StatusUnauthorized, rec.Code, key)
+	}
+
+	// revoked tokens are not accepted
+	require.NoError(t, db.RevokeApiToken("grafana"))
+	rec = doRequest(e, http.MethodGet, "/requests/01", readToken, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthNoPassword(t *testing.T) {
+	db := newTestDb(t)
+	readToken := newToken(t, db, "grafana", apitoken.ScopeRead)
+
+	// no decryption password when using an external signer, so only tokens are accepted
+	e := service.NewEchoTestService(db, "")
+	rec := doRequest(e, http.MethodGet, "/requests/01", readToken, "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/requests/01", testPassword, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthDbError(t *testing.T) {
+	db := newTestDb(t)
+	e := service.NewEchoTestService(db, testPassword)
+	readToken := newToken(t, db, "grafana", apitoken.ScopeRead)
+
+	sqlDb, err := db.DB.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDb.Close())
+
+	// the lookup error is not treated as a valid token
+	rec := doRequest(e, http.MethodGet, "/requests/01", readToken, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireScope(t *testing.T) {
+	db := newTestDb(t)
+	e := service.NewEchoTestService(db, testPassword)
+	analyticsToken := newToken(t, db, "reports", apitoken.ScopeAnalytics)
+
+	rec := doRequest(e, http.MethodGet, "/requests/01", analyticsToken, "")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "token does not have the read scope")
+
+	rec = doRequest(e, http.MethodGet, "/jobs", analyticsToken, "")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	readToken := newToken(t, db, "grafana", apitoken.ScopeRead)
+	rec = doRequest(e, http.MethodPost, "/analytics", readToken, `{"limit":10}`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "token does not have the analytics scope")
+}
+
+func TestAddAdminTaskScope(t *testing.T) {
+	db := newTestDb(t)
+	e := service.NewEchoTestService(db, testPassword)
+	feesToken := newToken(t, db, "fees-bot", apitoken.ScopeFees)
+
+	for task, scope := range map[string]string{
+		"withdraw":            apitoken.ScopeWithdraw,
+		"retry_request":       apitoken.ScopeAdmin,
+		"query_granular_fees": apitoken.ScopeRead,
+	} {
+		rec := doRequest(e, http.MethodPost, "/admin", feesToken, `{"task":"`+task+`"}`)
+		require.Equal(t, http.StatusForbidden, rec.Code, task)
+		require.Contains(t, rec.Body.String(), "token does not have the "+scope+" scope", task)
+	}
+
+	// refused tasks are audited
+	entries, err := db.GetAdminAudit(database.AdminAuditFilter{TokenId: "fees-bot"})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for _, entry := range entries {
+		require.False(t, entry.GetSuccess())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"go-ooo/apitoken"
 	"go-ooo/logger"
 	go_ooo_types "go-ooo/types"
 	"net/http"
@@ -13,15 +14,36 @@ import (
 func (s *Service) initEcho() {
 	logger.Info("service", "initEcho", "", "initialise echo")
 
-	s.echoService.Use(middleware.Recover())
-	s.echoService.Use(middleware.KeyAuth(func(key string, c echo.Context) (bool, error) {
-		return key == s.authToken, nil
+	s.registerRoutes()
+
+	address := fmt.Sprintf("%s:%s", s.cfg.Serve.Host, s.cfg.Serve.Port)
+
+	if s.tlsConfig == nil {
+		s.echoService.Logger.Fatal(s.echoService.Start(address))
+		return
+	}
+
+	logger.InfoWithFields("service", "initEcho", "", "serving over TLS", logger.Fields{
+		"client_certs": s.tlsConfig.ClientCAs != nil,
+	})
+
+	s.echoService.Logger.Fatal(s.echoService.StartServer(&http.Server{
+		Addr:      address,
+		TLSConfig: s.tlsConfig,
 	}))
+}
 
-	s.echoService.POST("/admin", s.AddAdminTask)
-	s.echoService.POST("/analytics", s.AddAnalyticsTask)
+// registerRoutes adds the authentication middleware and the API's routes to the echo service
+func (s *Service) registerRoutes() {
+	s.echoService.Use(middleware.Recover())
+	s.echoService.Use(middleware.KeyAuth(s.validateApiKey))
 
-	s.echoService.Logger.Fatal(s.echoService.Start(fmt.Sprintf("%s:%s", s.cfg.Serve.Host, s.cfg.Serve.Port)))
+	// admin task scopes are checked per task in AddAdminTask
+	s.echoService.POST("/admin", s.AddAdminTask)
+	s.echoService.POST("/analytics", s.AddAnalyticsTask, requireScope(apitoken.ScopeAnalytics))
+	s.echoService.GET("/requests", s.ListRequests, requireScope(apitoken.ScopeRead))
+	s.echoService.GET("/requests/:request_id", s.ShowRequest, requireScope(apitoken.ScopeRead))
+	s.echoService.GET("/jobs", s.ListPendingJobs, requireScope(apitoken.ScopeRead))
 }
 
 func (s *Service) AddAdminTask(c echo.Context) error {
@@ -29,10 +51,35 @@ func (s *Service) AddAdminTask(c echo.Context) error {
 
 	json.NewDecoder(c.Request().Body).Decode(&request)
 
+	request.TokenId, _ = c.Get(contextTokenId).(string)
+
+	scope := apitoken.TaskScope(request.Task)
+	if !hasScope(c, scope) {
+		logger.WarnWithFields("service", "AddAdminTask", "check scope", "token does not have scope for task", logger.Fields{
+			"token_id": request.TokenId,
+			"task":     request.Task,
+			"scope":    scope,
+		})
+
+		resp := go_ooo_types.AdminTaskResponse{
+			AdminTask: request,
+			Success:   false,
+			Error:     fmt.Sprintf("token does not have the %s scope", scope),
+		}
+		s.recordAdminAudit(request, resp)
+
+		return c.JSON(http.StatusForbidden, resp.Error)
+	}
+
 	logger.InfoWithFields("service", "AddAdminTask", "", "admin task received", logger.Fields{
+		"token_id":       request.TokenId,
 		"task":           request.Task,
 		"fee_or_amount":  request.FeeOrAmount,
 		"to_or_consumer": request.ToOrConsumer,
+		"chain":          request.Chain,
+		"request_id":     request.RequestId,
+		"price":          request.Price,
+		"reason":         request.Reason,
 	})
 
 	// send received task to chanel for processing
@@ -64,6 +111,13 @@ func (s *Service) AddAnalyticsTask(c echo.Context) error {
 	for {
 		select {
 		case tr := <-s.analyticsTasksResp:
+			if tr.Success && tr.Task.Report != "" && tr.Task.Format == ReportFormatCsv {
+				report, err := ProfitReportCsv(tr.Result.Report)
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, err.Error())
+				}
+				return c.Blob(http.StatusOK, "text/csv", report)
+			}
 			if tr.Success {
 				return c.JSON(http.StatusOK, tr)
 			}
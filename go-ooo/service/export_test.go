@@ -0,0 +1,62 @@
+package service
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"go-ooo/database"
+	go_ooo_types "go-ooo/types"
+)
+
+// NewEchoTestService returns the HTTP API for a Service which only has a DB, for testing
+// authentication and the handlers which do not need a chain connection
+func NewEchoTestService(db *database.DB, authToken string) *echo.Echo {
+	s := &Service{
+		db:             db,
+		authToken:      authToken,
+		echoService:    echo.New(),
+		adminTasks:     make(chan go_ooo_types.AdminTask, 1),
+		adminTasksResp: make(chan go_ooo_types.AdminTaskResponse, 1),
+	}
+	s.registerRoutes()
+	return s.echoService
+}
+
+var (
+	TargetFee             = targetFee
+	ExceedsHysteresis     = exceedsHysteresis
+	MeanFulfilmentCostEth = meanFulfilmentCostEth
+)
+
+var (
+	ReportRange     = reportRange
+	ReportPeriod    = reportPeriod
+	RunProfitReport = runProfitReport
+)
+
+var BackfillGas = backfillGas
+
+// StartGasBackfillTestService runs the gas back-fill for a Service which only has a DB. It
+// returns a function which stops the back-fill as Stop does, and a channel which is closed
+// once the back-fill goroutine has returned
+func StartGasBackfillTestService(db *database.DB) (func(), <-chan struct{}) {
+	s := &Service{
+		db:                db,
+		gasBackfillTicker: time.NewTicker(time.Millisecond),
+		gasBackfillDone:   make(chan struct{}),
+	}
+
+	returned := make(chan struct{})
+	go func() {
+		s.runGasBackfill()
+		close(returned)
+	}()
+
+	stop := func() {
+		s.gasBackfillTicker.Stop()
+		close(s.gasBackfillDone)
+	}
+
+	return stop, returned
+}
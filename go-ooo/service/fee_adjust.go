@@ -0,0 +1,160 @@
+package service
+
+import (
+	"math"
+	"math/big"
+	"time"
+
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// feeAdjustTokenId is recorded in the admin audit log for fee changes made by automatic fee adjustment
+const feeAdjustTokenId = "auto-fee-adjust"
+
+// adjustFee recalculates the global fee from recent fulfilment costs and the xFUND price, and
+// sets it if it differs from the current fee by more than the configured hysteresis
+func (s *Service) adjustFee() {
+	cfg := s.cfg.FeeAdjust
+
+	jobs, err := s.db.GetLastXSuccessfulRequests(cfg.Window, "")
+	if err != nil {
+		logger.Error("service", "adjustFee", "get fulfilments", err.Error())
+		return
+	}
+
+	if len(jobs) == 0 || len(jobs) < cfg.MinSamples {
+		logger.InfoWithFields("service", "adjustFee", "", "not enough fulfilments to calculate fee", logger.Fields{
+			"fulfilments": len(jobs),
+			"min_samples": cfg.MinSamples,
+		})
+		return
+	}
+
+	// jobs are ordered newest first
+	failed, err := s.db.GetFailedFulfilmentsBetween(jobs[len(jobs)-1].CreatedAt, time.Now(), "")
+	if err != nil {
+		logger.Error("service", "adjustFee", "get failed fulfilments", err.Error())
+		return
+	}
+
+	xfundPrice, priceSource, err := s.oooApi.GetXfundPriceEth()
+	if err != nil {
+		logger.Error("service", "adjustFee", "get xFUND price", err.Error())
+		return
+	}
+
+	fees, err := s.oooRouterService.GetProviderFees(nil)
+	if err != nil {
+		logger.Error("service", "adjustFee", "get current fee", err.Error())
+		return
+	}
+	if fees.MinFee == 0 {
+		logger.Warn("service", "adjustFee", "", "provider is not registered. Skipping fee adjustment")
+		return
+	}
+
+	meanCost := meanFulfilmentCostEth(jobs, failed)
+	newFee := targetFee(meanCost, xfundPrice, cfg)
+
+	fields := logger.Fields{
+		"current_fee":     fees.MinFee,
+		"new_fee":         newFee,
+		"mean_cost_eth":   meanCost,
+		"xfund_price_eth": xfundPrice,
+		"price_source":    priceSource,
+		"fulfilments":     len(jobs),
+		"failed_txs":      len(failed),
+	}
+
+	if !exceedsHysteresis(fees.MinFee, newFee, cfg.Hysteresis) {
+		logger.Debug("service", "adjustFee", "", "fee within hysteresis. No change", fields)
+		return
+	}
+
+	if cfg.Mode != config.FeeAdjustModeApply || s.oooRouterService.IsDryRun() {
+		logger.WarnWithFields("service", "adjustFee", "", "fee adjustment suggested. Set fee_adjust.mode = \"apply\" "+
+			"to apply automatically, or run 'go-ooo admin setFee'", fields)
+		return
+	}
+
+	resp := s.processAdminTask(go_ooo_types.AdminTask{
+		Task:        "set_fee",
+		FeeOrAmount: newFee,
+		TokenId:     feeAdjustTokenId,
+	})
+
+	if !resp.Success {
+		logger.ErrorWithFields("service", "adjustFee", "set fee", resp.Error, fields)
+		return
+	}
+
+	fields["tx_hash"] = resp.TxHash
+	logger.InfoWithFields("service", "adjustFee", "", "global fee adjusted", fields)
+}
+
+// meanFulfilmentCostEth returns the mean gas cost, in ETH, of the fulfilments, including the gas
+// spent on failed Txs. Zero if there are no fulfilments
+func meanFulfilmentCostEth(rows []models.DataRequests, failed []database.FailedFulfilmentCost) float64 {
+	if len(rows) == 0 {
+		return 0
+	}
+
+	costSum := big.NewFloat(0)
+
+	for _, f := range failed {
+		cost := new(big.Float).Mul(new(big.Float).SetUint64(f.GasUsed), new(big.Float).SetUint64(f.GasPrice))
+		costSum = new(big.Float).Add(costSum, cost)
+	}
+
+	for _, reqRow := range rows {
+		cost := new(big.Float).Mul(new(big.Float).SetUint64(reqRow.FulfillGasUsed), new(big.Float).SetUint64(reqRow.FulfillGasPrice))
+		costSum = new(big.Float).Add(costSum, cost)
+	}
+
+	totalCostEth := new(big.Float).Quo(costSum, big.NewFloat(params.Ether))
+	meanCost, _ := new(big.Float).Quo(totalCostEth, big.NewFloat(float64(len(rows)))).Float64()
+
+	return meanCost
+}
+
+// targetFee returns the fee, in the lowest xFUND denomination, which covers the mean cost plus
+// the target margin, rounded up to the nearest 0.0001 xFUND as suggestFee does, and bounded
+// by the configured min and max fees
+func targetFee(meanCostEth float64, xfundPriceEth float64, cfg config.FeeAdjustConfig) uint64 {
+	breakEvenXfund := meanCostEth / xfundPriceEth
+	// round to 6 decimal places of 0.0001 xFUND first, so that floating point error does not
+	// round an exact multiple up, e.g. 0.0033 to 0.0034
+	steps := math.Round(breakEvenXfund*(1+cfg.TargetMargin)*10000*1e6) / 1e6
+	targetXfund := math.Ceil(steps) / 10000
+
+	fee := uint64(math.Round(targetXfund * params.GWei))
+
+	if fee < cfg.MinFee {
+		fee = cfg.MinFee
+	}
+	if cfg.MaxFee > 0 && fee > cfg.MaxFee {
+		fee = cfg.MaxFee
+	}
+	if fee == 0 {
+		// the Router does not accept a zero fee
+		fee = 1
+	}
+
+	return fee
+}
+
+// exceedsHysteresis returns true if newFee differs from currentFee by more than the hysteresis,
+// as a fraction of currentFee
+func exceedsHysteresis(currentFee uint64, newFee uint64, hysteresis float64) bool {
+	if currentFee == newFee {
+		return false
+	}
+	change := math.Abs(float64(newFee)-float64(currentFee)) / float64(currentFee)
+	return change > hysteresis
+}
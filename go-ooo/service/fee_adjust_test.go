@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-ooo/config"
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/service"
+)
+
+func TestTargetFee(t *testing.T) {
+	tests := []struct {
+		name          string
+		meanCostEth   float64
+		xfundPriceEth float64
+		cfg           config.FeeAdjustConfig
+		expected      uint64
+	}{
+		{
+			name:          "break even",
+			meanCostEth:   0.001,
+			xfundPriceEth: 0.0001,
+			expected:      10_000_000_000,
+		},
+		{
+			name:          "margin",
+			meanCostEth:   0.001,
+			xfundPriceEth: 0.0001,
+			cfg:           config.FeeAdjustConfig{TargetMargin: 0.2},
+			expected:      12_000_000_000,
+		},
+		{
+			name:          "rounded up to 0.0001 xFUND",
+			meanCostEth:   0.00000123,
+			xfundPriceEth: 1,
+			expected:      100_000,
+		},
+		{
+			name:          "exact multiple of 0.0001 xFUND is not rounded up",
+			meanCostEth:   0.003,
+			xfundPriceEth: 1,
+			cfg:           config.FeeAdjustConfig{TargetMargin: 0.1},
+			expected:      3_300_000,
+		},
+		{
+			name:          "bounded by min fee",
+			meanCostEth:   0.00000123,
+			xfundPriceEth: 1,
+			cfg:           config.FeeAdjustConfig{MinFee: 1_000_000},
+			expected:      1_000_000,
+		},
+		{
+			name:          "bounded by max fee",
+			meanCostEth:   0.001,
+			xfundPriceEth: 0.0001,
+			cfg:           config.FeeAdjustConfig{MaxFee: 5_000_000_000},
+			expected:      5_000_000_000,
+		},
+		{
+			name:          "zero max fee is unbounded",
+			meanCostEth:   0.001,
+			xfundPriceEth: 0.0001,
+			cfg:           config.FeeAdjustConfig{MinFee: 1, MaxFee: 0},
+			expected:      10_000_000_000,
+		},
+		{
+			name:          "zero fee floored to one",
+			meanCostEth:   0,
+			xfundPriceEth: 1,
+			expected:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, service.TargetFee(tt.meanCostEth, tt.xfundPriceEth, tt.cfg))
+		})
+	}
+}
+
+func TestExceedsHysteresis(t *testing.T) {
+	tests := []struct {
+		name       string
+		currentFee uint64
+		newFee     uint64
+		hysteresis float64
+		expected   bool
+	}{
+		{name: "equal", currentFee: 1000, newFee: 1000, hysteresis: 0.1, expected: false},
+		{name: "equal with zero hysteresis", currentFee: 1000, newFee: 1000, hysteresis: 0, expected: false},
+		{name: "any change with zero hysteresis", currentFee: 1000, newFee: 1001, hysteresis: 0, expected: true},
+		{name: "increase within", currentFee: 1000, newFee: 1099, hysteresis: 0.1, expected: false},
+		{name: "increase at the boundary", currentFee: 1000, newFee: 1100, hysteresis: 0.1, expected: false},
+		{name: "increase beyond", currentFee: 1000, newFee: 1101, hysteresis: 0.1, expected: true},
+		{name: "decrease within", currentFee: 1000, newFee: 901, hysteresis: 0.1, expected: false},
+		{name: "decrease at the boundary", currentFee: 1000, newFee: 900, hysteresis: 0.1, expected: false},
+		{name: "decrease beyond", currentFee: 1000, newFee: 899, hysteresis: 0.1, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, service.ExceedsHysteresis(tt.currentFee, tt.newFee, tt.hysteresis))
+		})
+	}
+}
+
+func TestMeanFulfilmentCostEth(t *testing.T) {
+	fulfilment := func(gasUsed, gasPrice uint64) models.DataRequests {
+		return models.DataRequests{FulfillGasUsed: gasUsed, FulfillGasPrice: gasPrice}
+	}
+
+	tests := []struct {
+		name     string
+		rows     []models.DataRequests
+		failed   []database.FailedFulfilmentCost
+		expected float64
+	}{
+		{
+			name:     "no fulfilments",
+			expected: 0,
+		},
+		{
+			name:     "one fulfilment",
+			rows:     []models.DataRequests{fulfilment(100_000, 10_000_000_000)},
+			expected: 0.001,
+		},
+		{
+			name: "mean of fulfilments",
+			rows: []models.DataRequests{
+				fulfilment(100_000, 10_000_000_000),
+				fulfilment(100_000, 30_000_000_000),
+			},
+			expected: 0.002,
+		},
+		{
+			name: "failed Txs are spread across the fulfilments",
+			rows: []models.DataRequests{
+				fulfilment(100_000, 10_000_000_000),
+				fulfilment(100_000, 10_000_000_000),
+			},
+			failed: []database.FailedFulfilmentCost{
+				{GasUsed: 50_000, GasPrice: 20_000_000_000},
+			},
+			expected: 0.0015,
+		},
+		{
+			name: "failed Txs without fulfilments are ignored",
+			failed: []database.FailedFulfilmentCost{
+				{GasUsed: 50_000, GasPrice: 20_000_000_000},
+			},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.InDelta(t, tt.expected, service.MeanFulfilmentCostEth(tt.rows, tt.failed), 1e-15)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"go-ooo/database"
+	"go-ooo/database/models"
+	"go-ooo/logger"
+	go_ooo_types "go-ooo/types"
+)
+
+// default maximum number of requests returned by ListRequests
+const defaultRequestsLimit = 100
+
+func requestInfo(r models.DataRequests) go_ooo_types.RequestInfo {
+	return go_ooo_types.RequestInfo{
+		RequestId:                   r.GetRequestId(),
+		Consumer:                    r.GetConsumer(),
+		Endpoint:                    r.GetEndpointDecoded(),
+		IsAdhoc:                     r.GetIsAdHoc(),
+		Fee:                         r.GetFee(),
+		RequestBlockNumber:          r.GetRequestBlockNumber(),
+		RequestTxHash:               r.GetRequestTxHash(),
+		PriceResult:                 r.GetPriceResult(),
+		PriceSource:                 r.GetPriceSource(),
+		FulfillTxHash:               r.GetFulfillTxHash(),
+		FulfillConfirmedBlockNumber: r.GetFulfillBlockNumber(),
+		FulfillGasUsed:              r.GetFulfillGasUsed(),
+		FulfillGasPrice:             r.GetFulfillGasPrice(),
+		FulfillmentAttempts:         r.GetFulfillmentAttempts(),
+		RequestStatus:               r.GetRequestStatusString(),
+		JobStatus:                   r.GetJobStatusString(),
+		StatusReason:                r.GetStatusReason(),
+		CreatedAt:                   r.CreatedAt,
+		UpdatedAt:                   r.UpdatedAt,
+	}
+}
+
+func requestsFilterFromQuery(c echo.Context) (database.RequestsFilter, error) {
+	filter := database.RequestsFilter{
+		Consumer: c.QueryParam("consumer"),
+		Limit:    defaultRequestsLimit,
+	}
+
+	if s := c.QueryParam("status"); s != "" {
+		status, err := models.RequestStatusFromString(s)
+		if err != nil {
+			return filter, err
+		}
+		filter.Status = &status
+	}
+
+	if s := c.QueryParam("from_block"); s != "" {
+		block, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return filter, err
+		}
+		filter.FromBlock = block
+	}
+
+	if s := c.QueryParam("to_block"); s != "" {
+		block, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return filter, err
+		}
+		filter.ToBlock = block
+	}
+
+	if s := c.QueryParam("adhoc"); s != "" {
+		adhoc, err := strconv.ParseBool(s)
+		if err != nil {
+			return filter, err
+		}
+		filter.Adhoc = &adhoc
+	}
+
+	if s := c.QueryParam("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil {
+			return filter, err
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// ListRequests returns requests matching the status, consumer, from_block, to_block,
+// adhoc and limit query parameters, most recent first
+func (s *Service) ListRequests(c echo.Context) error {
+	filter, err := requestsFilterFromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	requests, err := s.db.GetRequests(filter)
+	if err != nil {
+		logger.Error("service", "ListRequests", "db.GetRequests", err.Error())
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	res := make([]go_ooo_types.RequestInfo, 0, len(requests))
+	for _, r := range requests {
+		res = append(res, requestInfo(r))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ShowRequest returns a single request, along with its failed fulfilment history
+func (s *Service) ShowRequest(c echo.Context) error {
+	requestId := c.Param("request_id")
+
+	request, err := s.db.FindByRequestId(requestId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, "request not found")
+	}
+	if err != nil {
+		logger.Error("service", "ShowRequest", "db.FindByRequestId", err.Error())
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	failed, err := s.db.GetFailedFulfilments(requestId)
+	if err != nil {
+		logger.Error("service", "ShowRequest", "db.GetFailedFulfilments", err.Error())
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	res := go_ooo_types.RequestDetail{
+		Request:           requestInfo(request),
+		FailedFulfilments: make([]go_ooo_types.FailedFulfilmentInfo, 0, len(failed)),
+	}
+
+	for _, f := range failed {
+		res.FailedFulfilments = append(res.FailedFulfilments, go_ooo_types.FailedFulfilmentInfo{
+			TxHash:     f.GetTxHash(),
+			GasUsed:    f.GetGasUsed(),
+			GasPrice:   f.GetGasPrice(),
+			FailReason: f.GetFailReason(),
+			CreatedAt:  f.CreatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ListPendingJobs returns the pending job queue, oldest first, with the time spent in the
+// current status and since the request was received
+func (s *Service) ListPendingJobs(c echo.Context) error {
+	jobs, err := s.db.GetPendingJobs()
+	if err != nil {
+		logger.Error("service", "ListPendingJobs", "db.GetPendingJobs", err.Error())
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	now := time.Now()
+	res := make([]go_ooo_types.PendingJob, 0, len(jobs))
+	for _, j := range jobs {
+		res = append(res, go_ooo_types.PendingJob{
+			RequestInfo:      requestInfo(j),
+			StatusAgeSeconds: int64(now.Sub(j.UpdatedAt).Seconds()),
+			AgeSeconds:       int64(now.Sub(j.CreatedAt).Seconds()),
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
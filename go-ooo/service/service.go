@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
 	"github.com/labstack/echo/v4"
 	"time"
 
@@ -11,7 +12,9 @@ import (
 	"go-ooo/logger"
 	"go-ooo/ooo_api"
 	"go-ooo/ooo_router"
+	"go-ooo/signer"
 	go_ooo_types "go-ooo/types"
+	"go-ooo/utils/tlsconfig"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -26,8 +29,16 @@ type Service struct {
 	cfg               *config.Config
 	jobTicker         *time.Ticker // periodic jobTicker
 	updatePairsTicker *time.Ticker
+	blockTimeTicker   *time.Ticker
+	feeAdjustTicker   *time.Ticker // nil unless fee_adjust.enabled
+	gasBackfillTicker *time.Ticker
+	gasBackfillDone   chan struct{} // closed by Stop
 	oooRouterService  *chain.OoORouterService
 
+	// serves requests sent to the previous provider address after a key rotation
+	retiringRouterService *chain.OoORouterService
+	retiringDrained       bool
+
 	echoService *echo.Echo
 	oooApi      *ooo_api.OOOApi
 
@@ -39,9 +50,10 @@ type Service struct {
 	analyticsTasksResp chan go_ooo_types.AnalyticsTaskResponse
 
 	authToken string
+	tlsConfig *tls.Config // nil if the HTTP API is served without TLS
 }
 
-func NewService(ctx context.Context, cfg *config.Config, oraclePrivateKey []byte,
+func NewService(ctx context.Context, cfg *config.Config, oracleSigner signer.Signer,
 	db *database.DB, authToken string) (*Service, error) {
 
 	contractAddress := common.HexToAddress(cfg.Chain.ContractAddress)
@@ -83,12 +95,29 @@ func NewService(ctx context.Context, cfg *config.Config, oraclePrivateKey []byte
 	}
 
 	logger.Info("service", "NewService", "", "init ooo router service")
-	oooRouterService, err := chain.NewOoORouter(ctx, cfg, client, oooRouterInstance, contractAddress, oraclePrivateKey, db, oooApi)
+	oooRouterService, err := chain.NewOoORouter(ctx, cfg, client, oooRouterInstance, contractAddress, oracleSigner, db, oooApi)
 
 	if err != nil {
 		return nil, err
 	}
 
+	var tlsConfig *tls.Config
+	if cfg.Serve.TlsCert != "" {
+		tlsConfig, err = tlsconfig.ServerConfig(cfg.Serve.TlsCert, cfg.Serve.TlsKey, cfg.Serve.ClientCa)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var feeAdjustTicker *time.Ticker
+	if cfg.FeeAdjust.Enabled {
+		logger.InfoWithFields("service", "NewService", "", "automatic fee adjustment enabled", logger.Fields{
+			"mode":          cfg.FeeAdjust.Mode,
+			"interval_mins": cfg.FeeAdjust.IntervalMins,
+		})
+		feeAdjustTicker = time.NewTicker(time.Minute * time.Duration(cfg.FeeAdjust.IntervalMins))
+	}
+
 	return &Service{
 		ctx:              ctx,
 		cfg:              cfg,
@@ -99,6 +128,10 @@ func NewService(ctx context.Context, cfg *config.Config, oraclePrivateKey []byte
 		// https://stackoverflow.com/questions/16903348/scheduled-polling-task-in-go
 		jobTicker:          time.NewTicker(time.Second * pollInterval),
 		updatePairsTicker:  time.NewTicker(time.Minute * 30),
+		blockTimeTicker:    time.NewTicker(time.Minute * 5),
+		feeAdjustTicker:    feeAdjustTicker,
+		gasBackfillTicker:  time.NewTicker(time.Minute * 5),
+		gasBackfillDone:    make(chan struct{}),
 		oooRouterService:   oooRouterService,
 		adminTasks:         make(chan go_ooo_types.AdminTask),
 		adminTasksResp:     make(chan go_ooo_types.AdminTaskResponse),
@@ -107,11 +140,42 @@ func NewService(ctx context.Context, cfg *config.Config, oraclePrivateKey []byte
 		echoService:        echo.New(),
 		oooApi:             oooApi,
 		authToken:          authToken,
+		tlsConfig:          tlsConfig,
 	}, nil
 }
 
+// SetDryRun enables dry-run mode. Requests are priced, but fulfilment Txs are not sent
+func (s *Service) SetDryRun(dryRun bool) {
+	s.oooRouterService.SetDryRun(dryRun)
+	if s.retiringRouterService != nil {
+		s.retiringRouterService.SetDryRun(dryRun)
+	}
+}
+
+// SetRetiringSigner continues to serve requests sent to a previous provider address after the
+// provider key has been rotated, until they have been drained
+func (s *Service) SetRetiringSigner(retiringSigner signer.Signer) error {
+	logger.InfoWithFields("service", "SetRetiringSigner", "", "init retiring ooo router service", logger.Fields{
+		"address": retiringSigner.Address().Hex(),
+	})
+
+	retiringRouterService, err := chain.NewOoORouter(s.ctx, s.cfg, s.client, s.contractInstance, s.contractAddress,
+		retiringSigner, s.db, s.oooApi)
+	if err != nil {
+		return err
+	}
+
+	retiringRouterService.SetDryRun(s.oooRouterService.IsDryRun())
+	s.retiringRouterService = retiringRouterService
+	return nil
+}
+
 func (s *Service) Run() {
 
+	if s.oooRouterService.IsDryRun() {
+		logger.Warn("service", "Run", "", "running in dry-run mode. Fulfilment txs will NOT be sent")
+	}
+
 	go func(s *Service) {
 		s.initEcho()
 	}(s)
@@ -120,10 +184,16 @@ func (s *Service) Run() {
 		s.initPrometheus()
 	}(s)
 
-	// update supported pairs from the Finchains API
+	// update supported pairs from the Finchains API, and record the xFUND price
 	go func(s *Service) {
+		s.oooApi.UpdateChainBlockTimes()
 		s.oooApi.UpdateSupportedPairs()
 		s.oooApi.UpdateDexPairs()
+		s.oooApi.UpdateXfundPrice()
+	}(s)
+
+	go func(s *Service) {
+		s.runGasBackfill()
 	}(s)
 
 	// pick up from the last block we know about to process
@@ -136,21 +206,44 @@ func (s *Service) Run() {
 		s.oooRouterService.RunEventWatchers()
 	}(s)
 
+	if s.retiringRouterService != nil {
+		s.retiringRouterService.GetHistoricalEvents()
+
+		go func(s *Service) {
+			s.retiringRouterService.RunEventWatchers()
+		}(s)
+	}
+
+	// a nil channel is never selected, so fee adjustment only runs if enabled
+	var feeAdjustC <-chan time.Time
+	if s.feeAdjustTicker != nil {
+		feeAdjustC = s.feeAdjustTicker.C
+	}
+
 	for {
 		select {
 		case <-s.jobTicker.C:
 			s.oooRouterService.ProcessPendingJobQueue()
+			s.processRetiringJobQueue()
+			s.updateAdminAuditOutcomes()
 		case <-s.updatePairsTicker.C:
 			go func(s *Service) {
 				s.oooApi.UpdateSupportedPairs()
 				s.oooApi.UpdateDexPairs()
+				s.oooApi.UpdateXfundPrice()
 			}(s)
+		case <-s.blockTimeTicker.C:
+			go func(s *Service) {
+				s.oooApi.UpdateChainBlockTimes()
+			}(s)
+		case <-feeAdjustC:
+			s.adjustFee()
 		case t := <-s.analyticsTasks:
 			s.analyticsTasksResp <- s.ProcessAnalyticsTask(t)
 		case t := <-s.adminTasks:
 			// At any time we can process a request to add a new admin task
 			// such as changing fees etc.
-			s.adminTasksResp <- s.oooRouterService.ProcessAdminTask(t)
+			s.adminTasksResp <- s.processAdminTask(t)
 		}
 	}
 }
@@ -163,9 +256,26 @@ func (s *Service) Stop() {
 	logger.Info("service", "Stop", "", "shutting down updatePairsTicker")
 	s.updatePairsTicker.Stop()
 
+	logger.Info("service", "Stop", "", "shutting down blockTimeTicker")
+	s.blockTimeTicker.Stop()
+
+	logger.Info("service", "Stop", "", "shutting down gasBackfillTicker")
+	s.gasBackfillTicker.Stop()
+	close(s.gasBackfillDone)
+
+	if s.feeAdjustTicker != nil {
+		logger.Info("service", "Stop", "", "shutting down feeAdjustTicker")
+		s.feeAdjustTicker.Stop()
+	}
+
 	logger.Info("service", "Stop", "", "shutting down oooRouterService")
 	s.oooRouterService.Shutdown()
 
+	if s.retiringRouterService != nil {
+		logger.Info("service", "Stop", "", "shutting down retiringRouterService")
+		s.retiringRouterService.Shutdown()
+	}
+
 	logger.Info("service", "Stop", "", "shutting down echo")
 	err := s.echoService.Shutdown(s.ctx)
 
@@ -179,3 +289,33 @@ func (s *Service) Stop() {
 		logger.Error("service", "Stop", "closing echo", err.Error())
 	}
 }
+
+// processRetiringJobQueue processes pending jobs for the retiring provider address, and logs
+// once there are none left
+func (s *Service) processRetiringJobQueue() {
+	if s.retiringRouterService == nil {
+		return
+	}
+
+	s.retiringRouterService.ProcessPendingJobQueue()
+
+	address := s.retiringRouterService.GetProviderAddress().Hex()
+	pending, err := s.db.GetPendingJobsForProvider(address)
+	if err != nil {
+		logger.Error("service", "processRetiringJobQueue", "get pending jobs", err.Error())
+		return
+	}
+
+	if len(pending) > 0 {
+		s.retiringDrained = false
+		return
+	}
+
+	if !s.retiringDrained {
+		logger.InfoWithFields("service", "processRetiringJobQueue", "", "no pending requests for retiring provider. "+
+			"Run 'go-ooo rotate-provider finish' once consumers are using the new provider", logger.Fields{
+			"address": address,
+		})
+		s.retiringDrained = true
+	}
+}
@@ -0,0 +1,90 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ClefSigner delegates signing to an external signer speaking Clef's external API, e.g.
+// Clef, or a remote or KMS backed signer implementing the account_* JSON-RPC methods
+type ClefSigner struct {
+	ext     *external.ExternalSigner
+	client  *rpc.Client // for account_signData, since ExternalSigner.SignText does not check the signature length
+	account accounts.Account
+}
+
+// NewClefSigner connects to the external signer at endpoint, which may be an IPC path or
+// HTTP URL. If address is empty, the first account returned by the signer is used
+func NewClefSigner(endpoint string, address string) (*ClefSigner, error) {
+	ext, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	accs := ext.Accounts()
+	if len(accs) == 0 {
+		return nil, errors.New(fmt.Sprintf("no accounts available from signer %s", endpoint))
+	}
+
+	account := accs[0]
+	if address != "" {
+		if !common.IsHexAddress(address) {
+			return nil, errors.New(fmt.Sprintf("invalid signer address %s", address))
+		}
+		account = accounts.Account{Address: common.HexToAddress(address), URL: ext.URL()}
+		if !ext.Contains(account) {
+			return nil, errors.New(fmt.Sprintf("account %s not available from signer %s", address, endpoint))
+		}
+	}
+
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClefSigner{
+		ext:     ext,
+		client:  client,
+		account: account,
+	}, nil
+}
+
+func (c *ClefSigner) Address() common.Address {
+	return c.account.Address
+}
+
+func (c *ClefSigner) SignTx(tx *types.Transaction, chainId *big.Int) (*types.Transaction, error) {
+	signed, err := c.ext.SignTx(c.account, tx, chainId)
+	if err != nil {
+		return nil, err
+	}
+	if signed == nil {
+		return nil, errors.New("signer returned no transaction")
+	}
+	return signed, nil
+}
+
+func (c *ClefSigner) SignText(text []byte) ([]byte, error) {
+	var sig hexutil.Bytes
+	address := common.NewMixedcaseAddress(c.account.Address)
+	err := c.client.Call(&sig, "account_signData", accounts.MimetypeTextPlain, &address, hexutil.Encode(text))
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != 65 {
+		return nil, errors.New(fmt.Sprintf("invalid signature length %d", len(sig)))
+	}
+	// Clef returns V as 27 or 28
+	if sig[64] == 27 || sig[64] == 28 {
+		sig[64] -= 27
+	}
+	return sig, nil
+}
@@ -0,0 +1,44 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	"go-ooo/utils"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// KeystoreSigner signs using a private key decrypted from the go-ooo keystore
+type KeystoreSigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewKeystoreSigner returns a Signer for the hex encoded private key
+func NewKeystoreSigner(privateKey string) (*KeystoreSigner, error) {
+	key, err := crypto.HexToECDSA(utils.RemoveHexPrefix(privateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeystoreSigner{
+		key:     key,
+		address: crypto.PubkeyToAddress(key.PublicKey),
+	}, nil
+}
+
+func (k *KeystoreSigner) Address() common.Address {
+	return k.address
+}
+
+func (k *KeystoreSigner) SignTx(tx *types.Transaction, chainId *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainId), k.key)
+}
+
+func (k *KeystoreSigner) SignText(text []byte) ([]byte, error) {
+	return crypto.Sign(accounts.TextHash(text), k.key)
+}
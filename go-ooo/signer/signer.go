@@ -0,0 +1,36 @@
+package signer
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Signer signs transactions and fulfilment messages for the oracle's provider address.
+// Implementations may hold the key in-process, or delegate signing to an external signer
+// such as Clef, so that the private key is never held by go-ooo
+type Signer interface {
+	// Address returns the provider address the Signer signs for
+	Address() common.Address
+	// SignTx signs the transaction for the given chain ID
+	SignTx(tx *types.Transaction, chainId *big.Int) (*types.Transaction, error)
+	// SignText signs the "\x19Ethereum Signed Message" hash of text. The returned
+	// signature is in [R || S || V] format, where V is 0 or 1, as returned by crypto.Sign
+	SignText(text []byte) ([]byte, error)
+}
+
+// NewTransactor returns TransactOpts which sign transactions using the Signer
+func NewTransactor(s Signer, chainId *big.Int) *bind.TransactOpts {
+	from := s.Address()
+	return &bind.TransactOpts{
+		From: from,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, bind.ErrNotAuthorized
+			}
+			return s.SignTx(tx, chainId)
+		},
+	}
+}
@@ -0,0 +1,191 @@
+package signer_test
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"go-ooo/signer"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+)
+
+const testPrivate = "0x646f1ce2fdad0e6deeeb5c7e8e5543bdde65e86029e2fd9fc169899c440a7913"
+
+var testChainId = big.NewInt(1337)
+
+// mockClef implements the subset of Clef's external API used by the ClefSigner
+type mockClef struct {
+	key      *ecdsa.PrivateKey
+	sigBytes int // if set, signatures are truncated to sigBytes
+}
+
+type mockSignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+func (m *mockClef) Version() (string, error) {
+	return "7.0.0", nil
+}
+
+func (m *mockClef) List() ([]common.Address, error) {
+	return []common.Address{crypto.PubkeyToAddress(m.key.PublicKey)}, nil
+}
+
+func (m *mockClef) SignTransaction(args apitypes.SendTxArgs) (*mockSignTxResult, error) {
+	if args.From.Address() != crypto.PubkeyToAddress(m.key.PublicKey) {
+		return nil, errors.New("unknown account")
+	}
+	tx, err := args.ToTransaction()
+	if err != nil {
+		return nil, err
+	}
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(args.ChainID.ToInt()), m.key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &mockSignTxResult{Raw: raw, Tx: signed}, nil
+}
+
+func (m *mockClef) SignData(contentType string, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if contentType != accounts.MimetypeTextPlain {
+		return nil, errors.New("unsupported content type")
+	}
+	sig, err := crypto.Sign(accounts.TextHash(data), m.key)
+	if err != nil {
+		return nil, err
+	}
+	// Clef returns V in 27/28 form
+	sig[64] += 27
+	if m.sigBytes > 0 {
+		sig = sig[:m.sigBytes]
+	}
+	return sig, nil
+}
+
+func startMockClef(t *testing.T, key *ecdsa.PrivateKey) string {
+	return startMockClefWith(t, &mockClef{key: key})
+}
+
+func startMockClefWith(t *testing.T, clef *mockClef) string {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("account", clef))
+
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		httpSrv.Close()
+		srv.Stop()
+	})
+
+	return httpSrv.URL
+}
+
+func testTx() *types.Transaction {
+	to := common.HexToAddress("0x5b1869D9A4C187F2EAa108f3062412ecf0526b24")
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(1000000000),
+		Gas:      500000,
+		To:       &to,
+		Value:    big.NewInt(0),
+		Data:     []byte{0x01, 0x02},
+	})
+}
+
+func requireSigner(t *testing.T, s signer.Signer, address common.Address) {
+	require.Equal(t, address, s.Address())
+
+	hash := crypto.Keccak256([]byte("request"))
+	sig, err := s.SignText(hash)
+	require.NoError(t, err)
+	require.Len(t, sig, 65)
+	require.Contains(t, []byte{0, 1}, sig[64])
+
+	pub, err := crypto.SigToPub(accounts.TextHash(hash), sig)
+	require.NoError(t, err)
+	require.Equal(t, address, crypto.PubkeyToAddress(*pub))
+
+	signed, err := s.SignTx(testTx(), testChainId)
+	require.NoError(t, err)
+	sender, err := types.Sender(types.LatestSignerForChainID(testChainId), signed)
+	require.NoError(t, err)
+	require.Equal(t, address, sender)
+
+	opts := signer.NewTransactor(s, testChainId)
+	require.Equal(t, address, opts.From)
+	_, err = opts.Signer(common.HexToAddress("0x01"), testTx())
+	require.ErrorIs(t, err, bind.ErrNotAuthorized)
+}
+
+func TestKeystoreSigner(t *testing.T) {
+	key, err := crypto.HexToECDSA(testPrivate[2:])
+	require.NoError(t, err)
+
+	s, err := signer.NewKeystoreSigner(testPrivate)
+	require.NoError(t, err)
+
+	requireSigner(t, s, crypto.PubkeyToAddress(key.PublicKey))
+
+	_, err = signer.NewKeystoreSigner("0xnotakey")
+	require.Error(t, err)
+}
+
+func TestClefSigner(t *testing.T) {
+	key, err := crypto.HexToECDSA(testPrivate[2:])
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	url := startMockClef(t, key)
+
+	s, err := signer.NewClefSigner(url, "")
+	require.NoError(t, err)
+	requireSigner(t, s, address)
+
+	s, err = signer.NewClefSigner(url, address.Hex())
+	require.NoError(t, err)
+	require.Equal(t, address, s.Address())
+
+	// fulfilment signatures are identical to those produced in-process
+	ks, err := signer.NewKeystoreSigner(testPrivate)
+	require.NoError(t, err)
+	hash := crypto.Keccak256([]byte("request"))
+	clefSig, err := s.SignText(hash)
+	require.NoError(t, err)
+	ksSig, err := ks.SignText(hash)
+	require.NoError(t, err)
+	require.Equal(t, ksSig, clefSig)
+
+	_, err = signer.NewClefSigner(url, "0x0000000000000000000000000000000000000001")
+	require.Error(t, err)
+}
+
+func TestClefSignerShortSignature(t *testing.T) {
+	key, err := crypto.HexToECDSA(testPrivate[2:])
+	require.NoError(t, err)
+
+	for _, length := range []int{64, 10} {
+		url := startMockClefWith(t, &mockClef{key: key, sigBytes: length})
+
+		s, err := signer.NewClefSigner(url, "")
+		require.NoError(t, err)
+
+		_, err = s.SignText(crypto.Keccak256([]byte("request")))
+		require.EqualError(t, err, fmt.Sprintf("invalid signature length %d", length))
+	}
+}
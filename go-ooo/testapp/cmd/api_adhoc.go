@@ -22,6 +22,7 @@ var apiAdhocCmd = &cobra.Command{
 		target := ep[1]
 
 		oooApi := createApi()
+		oooApi.UpdateChainBlockTimes()
 
 		start := time.Now()
 
@@ -1,9 +1,16 @@
 package types
 
+import "time"
+
 type AdminTask struct {
-	Task         string // register/withdraw/set_fee/set_granular_fee
+	Task         string // register/withdraw/set_fee/set_granular_fee/query_fee_config/list_pending_tokens/approve_token/revoke_token/show_provenance/dry_run_report/retry_request/refetch_request/abandon_request/fulfil_request
 	FeeOrAmount  uint64 // new fee or amount to withdraw
-	ToOrConsumer string // address withdrawing to, or contract address for granular fee
+	ToOrConsumer string // address withdrawing to, contract address for granular fee, comma separated consumers for query_fee_config, or token contract address
+	Chain        string // DEX chain for token tasks, e.g. eth
+	RequestId    string // request ID for request tasks
+	Price        string // operator supplied price for fulfil_request
+	Reason       string // reason for abandon_request
+	TokenId      string `json:"-"` // ID of the API token used to submit the task. Set by the service
 }
 
 type AdminTaskResponse struct {
@@ -11,6 +18,14 @@ type AdminTaskResponse struct {
 	Success bool
 	Result  string
 	Error   string
+	TxHash  string // hash of the Tx sent by the task, if any
+}
+
+// ProviderFees is a provider's min fee, and the granular fee for each consumer contract.
+// It is the result of query_fee_config, and the format of the fees file used by 'go-ooo fees'
+type ProviderFees struct {
+	MinFee       uint64            `json:"min_fee" yaml:"min_fee"`
+	GranularFees map[string]uint64 `json:"granular_fees" yaml:"granular_fees"`
 }
 
 type AnalyticsSimulationParams struct {
@@ -25,6 +40,11 @@ type AnalyticsTask struct {
 	Simulate         bool
 	SuggestFee       bool
 	SimulationParams AnalyticsSimulationParams
+	Report           string    // day/week/month to run a profitability report instead of analytics
+	ByConsumer       bool      // report on each consumer separately within each period
+	From             time.Time // start of the report. Default depends on the report period
+	To               time.Time // end of the report. Default now
+	Format           string    // json/csv. csv is only returned for reports
 }
 
 type SimValues struct {
@@ -51,9 +71,11 @@ type FloatStats struct {
 
 type EarningsStats struct {
 	CurrentXfundPriceEth float64 `json:"current_xfund_price_eth"`
+	XfundPriceSource     string  `json:"xfund_price_source"` // source of the current price, or "caller" if supplied in the task
 	TotalFeesEarnedXfund float64 `json:"total_fees_xfund"`
 	TotalFeesEarnedEth   float64 `json:"total_fees_eth"`
-	TotalCostsEth        float64 `json:"total_cost_eth"`
+	TotalCostsEth        float64 `json:"total_cost_eth"`     // includes failed Txs
+	FailedTxCostsEth     float64 `json:"failed_tx_cost_eth"` // gas spent on reverted and replaced Txs
 	ProfitLossEth        float64 `json:"profit_loss_eth"`
 }
 
@@ -65,13 +87,31 @@ type AnalyticsData struct {
 	MostGasUsedConsumer  string        `json:"most_gas_used_consumer,omitempty"`
 	LeastGasUsedConsumer string        `json:"least_gas_used_consumer,omitempty"`
 	NumberAnalysed       uint64        `json:"number_requests_analysed"`
+	NumberFailedTxs      uint64        `json:"number_failed_txs"`
 	SuggestedFee         float64       `json:"suggested_fee"`
 }
 
+// ProfitReportRow is one period, or one consumer within a period, of a profitability report.
+// Fees are converted to the chain's native currency at the xFUND price when each request was
+// fulfilled
+type ProfitReportRow struct {
+	Period             string    `json:"period"` // e.g. 2024-03-01, 2024-W09 or 2024-03
+	PeriodStart        time.Time `json:"period_start"`
+	Consumer           string    `json:"consumer,omitempty"`
+	Requests           uint64    `json:"requests"`
+	FeesXfund          float64   `json:"fees_xfund"`
+	FeesNative         float64   `json:"fees_native"`
+	GasCostNative      float64   `json:"gas_cost_native"`
+	FailedTxs          uint64    `json:"failed_txs"`
+	FailedTxCostNative float64   `json:"failed_tx_cost_native"`
+	NetProfitNative    float64   `json:"net_profit_native"`
+}
+
 type AnalyticsResult struct {
 	AnalyticsData
-	SimValues SimValues       `json:"simulation_values"`
-	Filters   AnalyticsFilter `json:"filters"`
+	SimValues SimValues         `json:"simulation_values"`
+	Filters   AnalyticsFilter   `json:"filters"`
+	Report    []ProfitReportRow `json:"report,omitempty"`
 }
 
 type AnalyticsTaskResponse struct {
@@ -88,3 +128,44 @@ type Prices struct {
 type CoinGeckoResponse struct {
 	Xfund Prices `json:"xfund"`
 }
+
+type RequestInfo struct {
+	RequestId                   string    `json:"request_id"`
+	Consumer                    string    `json:"consumer"`
+	Endpoint                    string    `json:"endpoint"`
+	IsAdhoc                     bool      `json:"is_adhoc"`
+	Fee                         uint64    `json:"fee"`
+	RequestBlockNumber          uint64    `json:"request_block_number"`
+	RequestTxHash               string    `json:"request_tx_hash"`
+	PriceResult                 string    `json:"price_result,omitempty"`
+	PriceSource                 string    `json:"price_source,omitempty"`
+	FulfillTxHash               string    `json:"fulfill_tx_hash,omitempty"`
+	FulfillConfirmedBlockNumber uint64    `json:"fulfill_confirmed_block_number,omitempty"`
+	FulfillGasUsed              uint64    `json:"fulfill_gas_used,omitempty"`
+	FulfillGasPrice             uint64    `json:"fulfill_gas_price,omitempty"`
+	FulfillmentAttempts         uint64    `json:"fulfillment_attempts"`
+	RequestStatus               string    `json:"request_status"`
+	JobStatus                   string    `json:"job_status"`
+	StatusReason                string    `json:"status_reason,omitempty"`
+	CreatedAt                   time.Time `json:"created_at"`
+	UpdatedAt                   time.Time `json:"updated_at"`
+}
+
+type FailedFulfilmentInfo struct {
+	TxHash     string    `json:"tx_hash"`
+	GasUsed    uint64    `json:"gas_used"`
+	GasPrice   uint64    `json:"gas_price"`
+	FailReason string    `json:"fail_reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type RequestDetail struct {
+	Request           RequestInfo            `json:"request"`
+	FailedFulfilments []FailedFulfilmentInfo `json:"failed_fulfilments"`
+}
+
+type PendingJob struct {
+	RequestInfo
+	StatusAgeSeconds int64 `json:"status_age_seconds"`
+	AgeSeconds       int64 `json:"age_seconds"`
+}
@@ -0,0 +1,185 @@
+package httprecorder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RecordEnv is the environment variable used to switch tests from replaying fixtures to
+// recording them from the live APIs, e.g. GO_OOO_RECORD=1 go test ./ooo_api/...
+const RecordEnv = "GO_OOO_RECORD"
+
+type Mode int
+
+const (
+	// ModeReplay serves responses from fixtures, and fails if no fixture exists
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the live API, and saves the responses as fixtures
+	ModeRecord
+)
+
+// Fixture is a single recorded request and response
+type Fixture struct {
+	Method   string `json:"method"`
+	Url      string `json:"url"`
+	Request  string `json:"request,omitempty"`
+	Status   int    `json:"status"`
+	Response string `json:"response"`
+}
+
+// Transport is an http.RoundTripper which records responses to, or replays responses from
+// fixture files in Dir. Fixtures are keyed by the request method, URL and body
+type Transport struct {
+	Dir  string
+	Mode Mode
+	// Real is used to send requests in ModeRecord. Defaults to http.DefaultTransport
+	Real http.RoundTripper
+	// Redact lists strings, e.g. API keys, removed from URLs before they are keyed or saved
+	Redact []string
+}
+
+var _ http.RoundTripper = &Transport{}
+
+// New returns a Transport for the fixtures in dir. The mode is ModeRecord if the
+// RecordEnv environment variable is set, otherwise ModeReplay
+func New(dir string, redact ...string) *Transport {
+	mode := ModeReplay
+	if os.Getenv(RecordEnv) != "" {
+		mode = ModeRecord
+	}
+
+	return &Transport{
+		Dir:    dir,
+		Mode:   mode,
+		Redact: redact,
+	}
+}
+
+// Client returns an http.Client using the Transport
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	url := t.redact(req.URL.String())
+	path := filepath.Join(t.Dir, FixtureName(req.Method, url, reqBody))
+
+	if t.Mode == ModeRecord {
+		return t.record(req, url, reqBody, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.New(fmt.Sprintf("no fixture for %s %s. Run with %s=1 to record", req.Method, url, RecordEnv))
+		}
+		return nil, err
+	}
+
+	var fixture Fixture
+	err = json.Unmarshal(data, &fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	return fixture.response(req), nil
+}
+
+func (t *Transport) record(req *http.Request, url string, reqBody []byte, path string) (*http.Response, error) {
+	real := t.Real
+	if real == nil {
+		real = http.DefaultTransport
+	}
+
+	resp, err := real.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := Fixture{
+		Method:   req.Method,
+		Url:      url,
+		Request:  string(reqBody),
+		Status:   resp.StatusCode,
+		Response: string(body),
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(t.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return fixture.response(req), nil
+}
+
+func (t *Transport) redact(url string) string {
+	for _, r := range t.Redact {
+		if r != "" {
+			url = strings.ReplaceAll(url, r, "REDACTED")
+		}
+	}
+	return url
+}
+
+func (f Fixture) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(strings.NewReader(f.Response)),
+		ContentLength: int64(len(f.Response)),
+		Request:       req,
+	}
+}
+
+// FixtureName returns the file name of the fixture for a request
+func FixtureName(method, url string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte(" "))
+	h.Write([]byte(url))
+	h.Write([]byte("\n"))
+	h.Write(body)
+
+	return fmt.Sprintf("%s_%s.json", strings.ToLower(method), hex.EncodeToString(h.Sum(nil))[:16])
+}
@@ -0,0 +1,68 @@
+package httprecorder_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-ooo/utils/httprecorder"
+)
+
+type staticTransport struct {
+	body  string
+	calls int
+}
+
+func (s *staticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.calls++
+	return &http.Response{
+		StatusCode: 200,
+		Status:     "200 OK",
+		Body:       ioutil.NopCloser(strings.NewReader(s.body)),
+		Header:     http.Header{},
+	}, nil
+}
+
+func post(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Post(url, "application/json", strings.NewReader(`{"query":"{}"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	real := &staticTransport{body: `{"data":{}}`}
+
+	recorder := &httprecorder.Transport{Dir: dir, Mode: httprecorder.ModeRecord, Real: real, Redact: []string{"secret"}}
+	require.Equal(t, `{"data":{}}`, post(t, recorder.Client(), "https://example.com/api/secret/subgraph"))
+	require.Equal(t, 1, real.calls)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret")
+
+	// replayed without calling the real transport
+	replay := &httprecorder.Transport{Dir: dir, Mode: httprecorder.ModeReplay, Real: real, Redact: []string{"secret"}}
+	require.Equal(t, `{"data":{}}`, post(t, replay.Client(), "https://example.com/api/secret/subgraph"))
+	require.Equal(t, 1, real.calls)
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+	replay := &httprecorder.Transport{Dir: t.TempDir(), Mode: httprecorder.ModeReplay}
+
+	_, err := replay.Client().Get("https://example.com/pairs")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no fixture for GET https://example.com/pairs")
+}
@@ -0,0 +1,131 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-ooo/utils/tlsconfig"
+)
+
+type testCert struct {
+	cert  *x509.Certificate
+	key   *ecdsa.PrivateKey
+	cFile string
+	kFile string
+}
+
+// newCert creates a certificate signed by parent, or self-signed if parent is nil, and
+// writes the PEM encoded certificate and key to dir
+func newCert(t *testing.T, dir, name string, parent *testCert, isCa bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCa,
+		BasicConstraintsValid: true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	tc := &testCert{
+		cert:  cert,
+		key:   key,
+		cFile: filepath.Join(dir, name+".crt"),
+		kFile: filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(tc.cFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(tc.kFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return tc
+}
+
+func TestMutualTls(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, dir, "ca", nil, true)
+	srv := newCert(t, dir, "server", ca, false)
+	client := newCert(t, dir, "client", ca, false)
+	otherCa := newCert(t, dir, "other-ca", nil, true)
+	otherClient := newCert(t, dir, "other-client", otherCa, false)
+
+	serverCfg, err := tlsconfig.ServerConfig(srv.cFile, srv.kFile, ca.cFile)
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.TLS = serverCfg
+	ts.StartTLS()
+	defer ts.Close()
+
+	get := func(cfg *tls.Config) error {
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		resp, err := c.Get(ts.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	clientCfg, err := tlsconfig.ClientConfig(ca.cFile, client.cFile, client.kFile)
+	require.NoError(t, err)
+	require.NoError(t, get(clientCfg))
+
+	// no client certificate
+	clientCfg, err = tlsconfig.ClientConfig(ca.cFile, "", "")
+	require.NoError(t, err)
+	require.Error(t, get(clientCfg))
+
+	// client certificate not signed by the client CA
+	clientCfg, err = tlsconfig.ClientConfig(ca.cFile, otherClient.cFile, otherClient.kFile)
+	require.NoError(t, err)
+	require.Error(t, get(clientCfg))
+
+	// server certificate not trusted
+	clientCfg, err = tlsconfig.ClientConfig(otherCa.cFile, client.cFile, client.kFile)
+	require.NoError(t, err)
+	require.Error(t, get(clientCfg))
+}
+
+func TestConfigErrors(t *testing.T) {
+	_, err := tlsconfig.ServerConfig("", "", "")
+	require.Error(t, err)
+
+	_, err = tlsconfig.ClientConfig("", "client.crt", "")
+	require.Error(t, err)
+
+	_, err = tlsconfig.ClientConfig(filepath.Join(t.TempDir(), "missing.pem"), "", "")
+	require.Error(t, err)
+}